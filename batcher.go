@@ -0,0 +1,423 @@
+package auditrail
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// BatcherOption is a function that configures a batcher.
+type BatcherOption func(options *batcherOptions)
+
+type batcherOptions struct {
+	maxEntries   int
+	maxBytes     int
+	maxLatency   time.Duration
+	timeout      time.Duration
+	dropHandling DropHandlerFunc
+}
+
+var defaultBatcherOptions = batcherOptions{
+	maxEntries:   100,
+	maxBytes:     1 << 20,
+	maxLatency:   time.Second,
+	timeout:      10 * time.Second,
+	dropHandling: func(*Entry, error) {},
+}
+
+var (
+	_ BatchLogger    = (*batcher)(nil)
+	_ ShutdownLogger = (*batcher)(nil)
+)
+
+type batcher struct {
+	dst          Logger
+	opts         batcherOptions
+	pending      []*Entry
+	pendingBytes int
+	buffered     int
+	flushed      int
+	stop         context.Context
+	cancel       context.CancelCauseFunc
+	timer        *time.Timer
+	generation   uint64
+	flushes      chan []*Entry
+	sending      sync.WaitGroup
+	flushing     sync.WaitGroup
+	closed       bool
+	closeChannel chan struct{}
+	mu           sync.RWMutex
+}
+
+// NewBatcher builds a new logger that accumulates entries and writes them to
+// the given destination in batches. A batch is written as soon as it reaches
+// the maximum number of entries or bytes, or when its oldest entry has waited
+// for the maximum latency. Pending entries are written when the batcher is
+// closed.
+//
+// When the destination is a [BatchLogger], each batch is written with a
+// single call to LogBatch; otherwise entries are written one by one. Entries
+// that could not be written are reported to the drop handler. See options for
+// configuration.
+func NewBatcher(dst Logger, options ...BatcherOption) Logger {
+	opts := defaultBatcherOptions
+	b := &batcher{
+		dst:          dst,
+		flushes:      make(chan []*Entry),
+		closeChannel: make(chan struct{}),
+	}
+
+	for _, option := range options {
+		option(&opts)
+	}
+
+	b.opts = opts
+	b.stop, b.cancel = context.WithCancelCause(context.Background())
+
+	b.flushing.Add(1)
+
+	go b.run()
+
+	return b
+}
+
+// Log adds the given log entry to the current batch. It blocks while a full
+// batch is handed over to the destination.
+func (b *batcher) Log(_ context.Context, entry *Entry) error {
+	b.mu.Lock()
+
+	if b.closed {
+		b.mu.Unlock()
+
+		return ErrTrailClosed
+	}
+
+	if len(b.pending) == 0 {
+		generation := b.generation
+		b.timer = time.AfterFunc(b.opts.maxLatency, func() { b.expire(generation) })
+	}
+
+	b.pending = append(b.pending, entry)
+	b.pendingBytes += entrySize(entry)
+	b.buffered++
+
+	if len(b.pending) < b.opts.maxEntries && (b.opts.maxBytes <= 0 || b.pendingBytes < b.opts.maxBytes) {
+		b.mu.Unlock()
+
+		return nil
+	}
+
+	batch := b.take()
+	b.sending.Add(1)
+	b.mu.Unlock()
+
+	b.flushes <- batch
+	b.sending.Done()
+
+	return nil
+}
+
+// LogBatch adds the given log entries to the current batch.
+func (b *batcher) LogBatch(ctx context.Context, entries []*Entry) []error {
+	var errs []error
+
+	for i, entry := range entries {
+		if err := b.Log(ctx, entry); err != nil {
+			errs = setBatchError(errs, len(entries), i, err)
+		}
+	}
+
+	return errs
+}
+
+// Close writes any pending entry and closes the destination logger.
+func (b *batcher) Close() error {
+	_, err := b.Shutdown(context.Background())
+
+	return err
+}
+
+// Shutdown writes pending entries until ctx is done. Then, the batches that
+// were not written are abandoned, and the write in progress is cancelled and
+// counted as abandoned, its entries being passed to the drop handler if it
+// fails. The destination logger is shut down within ctx once every batch was
+// written.
+func (b *batcher) Shutdown(ctx context.Context) (ShutdownReport, error) {
+	b.mu.Lock()
+	if b.closed {
+		b.mu.Unlock()
+
+		return ShutdownReport{}, nil
+	}
+
+	b.closed = true
+	batch := b.take()
+	flushed := b.flushed
+	b.mu.Unlock()
+
+	drained := make(chan struct{})
+
+	go func() {
+		b.sending.Wait()
+
+		if len(batch) > 0 {
+			select {
+			case b.flushes <- batch:
+			case <-b.stop.Done():
+				b.abandon(batch)
+			}
+		}
+
+		close(b.flushes)
+		b.flushing.Wait()
+		close(drained)
+	}()
+
+	select {
+	case <-drained:
+	case <-ctx.Done():
+		b.mu.RLock()
+		report := ShutdownReport{Flushed: b.flushed - flushed, Abandoned: b.buffered}
+		b.mu.RUnlock()
+
+		b.cancel(ctx.Err())
+
+		go func() {
+			<-drained
+			_ = b.dst.Close()
+			close(b.closeChannel)
+		}()
+
+		return report, fmt.Errorf("%w: batcher could not be drained before the deadline", ctx.Err())
+	}
+
+	defer close(b.closeChannel)
+
+	b.mu.RLock()
+	report := ShutdownReport{Flushed: b.flushed - flushed}
+	b.mu.RUnlock()
+
+	dst, err := Shutdown(ctx, b.dst)
+
+	return report.add(dst), err
+}
+
+func (b *batcher) Closed() <-chan struct{} {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+
+	return b.closeChannel
+}
+
+func (b *batcher) IsClosed() bool {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+
+	return b.closed
+}
+
+// expire hands over the batch of the given generation once its maximum
+// latency is reached, unless it was already handed over.
+func (b *batcher) expire(generation uint64) {
+	b.mu.Lock()
+
+	if b.closed || generation != b.generation || len(b.pending) == 0 {
+		b.mu.Unlock()
+
+		return
+	}
+
+	batch := b.take()
+	b.sending.Add(1)
+	b.mu.Unlock()
+
+	b.flushes <- batch
+	b.sending.Done()
+}
+
+// take returns the current batch and starts a new one. Must be called with the
+// lock held.
+func (b *batcher) take() []*Entry {
+	if b.timer != nil {
+		b.timer.Stop()
+		b.timer = nil
+	}
+
+	batch := b.pending
+	b.pending = nil
+	b.pendingBytes = 0
+	b.generation++
+
+	return batch
+}
+
+// run is the goroutine writing batches to the destination logger.
+func (b *batcher) run() {
+	defer b.flushing.Done()
+
+	for batch := range b.flushes {
+		if b.stop.Err() != nil {
+			b.abandon(batch)
+
+			continue
+		}
+
+		ctx, cancel := context.WithTimeout(b.stop, b.opts.timeout)
+		errs := logBatch(ctx, b.dst, batch)
+
+		cancel()
+
+		written := len(batch)
+
+		for i, err := range errs {
+			if err == nil {
+				continue
+			}
+
+			if b.stop.Err() != nil {
+				err = abandonedError(context.Cause(b.stop))
+			}
+
+			b.opts.dropHandling(batch[i], err)
+			written--
+		}
+
+		b.mu.Lock()
+		b.buffered -= len(batch)
+		b.flushed += written
+		b.mu.Unlock()
+	}
+}
+
+// abandon passes the entries of a batch that will not be written to the drop
+// handler, once shutting down was cancelled.
+func (b *batcher) abandon(batch []*Entry) {
+	err := abandonedError(context.Cause(b.stop))
+	for _, entry := range batch {
+		b.opts.dropHandling(entry, err)
+	}
+
+	b.mu.Lock()
+	b.buffered -= len(batch)
+	b.mu.Unlock()
+}
+
+// logBatch writes the given entries to the destination logger, using a single
+// call when it supports batches.
+func logBatch(ctx context.Context, dst Logger, entries []*Entry) []error {
+	if bl, ok := dst.(BatchLogger); ok {
+		return bl.LogBatch(ctx, entries)
+	}
+
+	var errs []error
+
+	for i, entry := range entries {
+		if err := dst.Log(ctx, entry); err != nil {
+			errs = setBatchError(errs, len(entries), i, err)
+		}
+	}
+
+	return errs
+}
+
+// batchErrors returns the errors of a batch of n entries all failing with the
+// given error.
+func batchErrors(n int, err error) []error {
+	return mergeBatchErrors(nil, n, err)
+}
+
+// setBatchError sets the error of the i-th entry of a batch of n entries,
+// allocating the errors slice if needed.
+func setBatchError(errs []error, n, i int, err error) []error {
+	if errs == nil {
+		errs = make([]error, n)
+	}
+
+	errs[i] = err
+
+	return errs
+}
+
+// mergeBatchErrors sets the given error for every entry of a batch of n
+// entries that has not failed yet.
+func mergeBatchErrors(errs []error, n int, err error) []error {
+	for i := 0; i < n; i++ {
+		if errs == nil || errs[i] == nil {
+			errs = setBatchError(errs, n, i, err)
+		}
+	}
+
+	return errs
+}
+
+// entrySize estimates the size in bytes of the given entry, as the length of
+// its JSON representation.
+func entrySize(entry *Entry) int {
+	b, err := json.Marshal(entry)
+	if err != nil {
+		return 0
+	}
+
+	return len(b)
+}
+
+// WithBatchMaxEntries sets the maximum number of entries of a batch. If n is
+// less than or equal to zero, it will be set to 100.
+func WithBatchMaxEntries(n int) BatcherOption {
+	return func(opts *batcherOptions) {
+		if n <= 0 {
+			n = 100
+		}
+
+		opts.maxEntries = n
+	}
+}
+
+// WithBatchMaxBytes sets the maximum size of a batch, estimated from the JSON
+// representation of its entries. If n is less than or equal to zero, batches
+// are not limited by size. Defaults to 1 MiB.
+func WithBatchMaxBytes(n int) BatcherOption {
+	return func(opts *batcherOptions) {
+		opts.maxBytes = n
+	}
+}
+
+// WithBatchMaxLatency sets the maximum amount of time an entry waits for its
+// batch to be written. If latency is less than or equal to zero, it will be
+// set to 1 second.
+func WithBatchMaxLatency(latency time.Duration) BatcherOption {
+	return func(opts *batcherOptions) {
+		if latency <= 0 {
+			latency = time.Second
+		}
+
+		opts.maxLatency = latency
+	}
+}
+
+// WithBatchTimeout controls the maximum amount of time the destination logger
+// is given to write a batch. If the timeout is less than or equal to zero, it
+// will be set to 10 seconds.
+func WithBatchTimeout(timeout time.Duration) BatcherOption {
+	return func(opts *batcherOptions) {
+		if timeout <= 0 {
+			timeout = 10 * time.Second
+		}
+
+		opts.timeout = timeout
+	}
+}
+
+// WithBatchDropHandler sets a function that will be called for every entry of
+// a batch that could not be written, with the error reported for it.
+func WithBatchDropHandler(handler DropHandlerFunc) BatcherOption {
+	return func(opts *batcherOptions) {
+		if handler == nil {
+			handler = func(*Entry, error) {}
+		}
+
+		opts.dropHandling = handler
+	}
+}
@@ -0,0 +1,188 @@
+package auditrail_test
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/botchris/go-auditrail"
+	"github.com/brianvoe/gofakeit/v6"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBatcher(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	t.Run("GIVEN a batcher limited by count WHEN logging entries THEN full batches are written at once", func(t *testing.T) {
+		dst := newBatchRecorder()
+		batcher := auditrail.NewBatcher(dst,
+			auditrail.WithBatchMaxEntries(10),
+			auditrail.WithBatchMaxLatency(time.Hour),
+		)
+
+		for i := 0; i < 25; i++ {
+			require.NoError(t, batcher.Log(ctx, newFakeEntry()))
+		}
+
+		require.Eventually(t, func() bool { return len(dst.Batches()) == 2 }, 5*time.Second, time.Millisecond)
+		require.Equal(t, []int{10, 10}, dst.Batches())
+
+		t.Run("AND closing the batcher THEN pending entries are flushed", func(t *testing.T) {
+			checkClose(t, ctx, batcher)
+
+			require.Equal(t, []int{10, 10, 5}, dst.Batches())
+			require.EqualValues(t, 25, dst.Size())
+		})
+	})
+
+	t.Run("GIVEN a batcher limited by bytes WHEN logging large entries THEN batches are cut by size", func(t *testing.T) {
+		dst := newBatchRecorder()
+		batcher := auditrail.NewBatcher(dst,
+			auditrail.WithBatchMaxBytes(2500),
+			auditrail.WithBatchMaxLatency(time.Hour),
+		)
+
+		for i := 0; i < 4; i++ {
+			entry := newFakeEntry().AppendDetails("blob", strings.Repeat("x", 1000))
+			require.NoError(t, batcher.Log(ctx, entry))
+		}
+
+		checkClose(t, ctx, batcher)
+		require.Equal(t, []int{3, 1}, dst.Batches())
+	})
+
+	t.Run("GIVEN a batcher limited by latency WHEN a batch is not full THEN it is written after the max latency", func(t *testing.T) {
+		dst := newBatchRecorder()
+		batcher := auditrail.NewBatcher(dst, auditrail.WithBatchMaxLatency(20*time.Millisecond))
+		defer checkClose(t, ctx, batcher)
+
+		require.NoError(t, batcher.Log(ctx, newFakeEntry()))
+		require.NoError(t, batcher.Log(ctx, newFakeEntry()))
+
+		require.Eventually(t, func() bool { return dst.Size() == 2 }, 5*time.Second, time.Millisecond)
+		require.Equal(t, []int{2}, dst.Batches())
+	})
+
+	t.Run("GIVEN a destination failing some entries WHEN flushing THEN failures are reported to the drop handler", func(t *testing.T) {
+		failing := newFakeEntry()
+		dst := newBatchRecorder()
+		dst.fail = func(e *auditrail.Entry) error {
+			if e == failing {
+				return errors.New("mapping error")
+			}
+
+			return nil
+		}
+
+		var dropped []*auditrail.Entry
+
+		batcher := auditrail.NewBatcher(dst, auditrail.WithBatchDropHandler(func(e *auditrail.Entry, err error) {
+			require.EqualError(t, err, "mapping error")
+			dropped = append(dropped, e)
+		}))
+
+		require.NoError(t, batcher.Log(ctx, newFakeEntry()))
+		require.NoError(t, batcher.Log(ctx, failing))
+		require.NoError(t, batcher.Log(ctx, newFakeEntry()))
+
+		checkClose(t, ctx, batcher)
+		require.Equal(t, []*auditrail.Entry{failing}, dropped)
+		require.EqualValues(t, 2, dst.Size())
+	})
+
+	t.Run("GIVEN a destination without batch support WHEN flushing THEN entries are written one by one", func(t *testing.T) {
+		dst := auditrail.NewMemoryLogger()
+		batcher := auditrail.NewBatcher(dst, auditrail.WithBatchMaxEntries(3))
+
+		for i := 0; i < 7; i++ {
+			require.NoError(t, batcher.Log(ctx, newFakeEntry()))
+		}
+
+		checkClose(t, ctx, batcher)
+		require.EqualValues(t, 7, dst.Size())
+	})
+
+	t.Run("GIVEN a stalled destination WHEN the shutdown deadline is reached THEN pending entries are abandoned", func(t *testing.T) {
+		var abandoned atomic.Int64
+
+		batcher := auditrail.NewBatcher(&stalled{Logger: auditrail.NewMemoryLogger()},
+			auditrail.WithBatchMaxEntries(2),
+			auditrail.WithBatchDropHandler(func(_ *auditrail.Entry, err error) {
+				require.ErrorIs(t, err, auditrail.ErrTrailClosed)
+				abandoned.Add(1)
+			}),
+		)
+
+		// the first batch is written, while the last entry is pending.
+		for i := 0; i < 3; i++ {
+			require.NoError(t, batcher.Log(ctx, newFakeEntry()))
+		}
+
+		deadline, cancelDeadline := context.WithTimeout(ctx, 20*time.Millisecond)
+		defer cancelDeadline()
+
+		report, err := auditrail.Shutdown(deadline, batcher)
+		require.ErrorIs(t, err, context.DeadlineExceeded)
+		require.Equal(t, auditrail.ShutdownReport{Abandoned: 3}, report)
+		require.Eventually(t, func() bool { return abandoned.Load() == 3 }, 5*time.Second, time.Millisecond)
+	})
+}
+
+func newFakeEntry() *auditrail.Entry {
+	return auditrail.NewEntry(gofakeit.Username(), gofakeit.VerbAction(), gofakeit.AppName())
+}
+
+// batchRecorder is a batch logger recording the size of every batch it
+// receives. Entries for which fail returns an error are not recorded.
+type batchRecorder struct {
+	*auditrail.MemoryLogger
+	fail    func(*auditrail.Entry) error
+	batches []int
+	mu      sync.Mutex
+}
+
+func newBatchRecorder() *batchRecorder {
+	return &batchRecorder{MemoryLogger: auditrail.NewMemoryLogger()}
+}
+
+func (r *batchRecorder) LogBatch(ctx context.Context, entries []*auditrail.Entry) []error {
+	r.mu.Lock()
+	r.batches = append(r.batches, len(entries))
+	r.mu.Unlock()
+
+	var errs []error
+
+	for i, e := range entries {
+		var err error
+
+		if r.fail != nil {
+			err = r.fail(e)
+		}
+
+		if err == nil {
+			err = r.MemoryLogger.Log(ctx, e)
+		}
+
+		if err != nil {
+			if errs == nil {
+				errs = make([]error, len(entries))
+			}
+
+			errs[i] = err
+		}
+	}
+
+	return errs
+}
+
+func (r *batchRecorder) Batches() []int {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	return append([]int(nil), r.batches...)
+}
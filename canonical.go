@@ -0,0 +1,205 @@
+package auditrail
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+	"unicode/utf16"
+	"unicode/utf8"
+)
+
+// canonicalTimeFormat is the fixed time format used for the occurrence time of
+// canonically encoded entries: UTC with nanosecond precision.
+const canonicalTimeFormat = "2006-01-02T15:04:05.000000000Z"
+
+// ErrEmptyEntry is returned when canonically encoding an entry holding no
+// data, such as the zero value of [Entry].
+var ErrEmptyEntry = errors.New("entry has no data")
+
+// CanonicalJSON returns the canonical JSON encoding of the entry as defined by
+// RFC 8785 (JSON Canonicalization Scheme): object keys are sorted, numbers are
+// serialized as IEEE 754 doubles, strings use minimal escaping and the
+// occurrence time is always written in UTC with nanosecond precision.
+//
+// Unlike [Entry.MarshalJSON], the output is byte-for-byte stable for
+// equivalent entries regardless of the Go types used for details, and it
+// survives a JSON round trip, which makes it suitable for hashing, signing and
+// deduplication.
+func (e *Entry) CanonicalJSON() ([]byte, error) {
+	if e == nil {
+		return nil, ErrEmptyEntry
+	}
+
+	return canonicalEntry(e.data)
+}
+
+// canonicalEntry returns the canonical encoding of the given entry data.
+func canonicalEntry(data *entryData) ([]byte, error) {
+	if data == nil {
+		return nil, ErrEmptyEntry
+	}
+
+	raw, err := json.Marshal(data)
+	if err != nil {
+		return nil, err
+	}
+
+	generic := make(map[string]interface{})
+	if err = json.Unmarshal(raw, &generic); err != nil {
+		return nil, err
+	}
+
+	generic["occurred_at"] = data.OccurredAt.UTC().Format(canonicalTimeFormat)
+
+	return appendCanonical(nil, generic)
+}
+
+// canonicalize returns the RFC 8785 canonical encoding of the given value,
+// taken from its regular JSON representation.
+func canonicalize(v interface{}) ([]byte, error) {
+	raw, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+
+	var generic interface{}
+	if err = json.Unmarshal(raw, &generic); err != nil {
+		return nil, err
+	}
+
+	return appendCanonical(nil, generic)
+}
+
+// appendCanonical appends the canonical encoding of the given generic JSON
+// value, as produced by json.Unmarshal, to buf.
+func appendCanonical(buf []byte, v interface{}) ([]byte, error) {
+	switch value := v.(type) {
+	case nil:
+		return append(buf, "null"...), nil
+	case bool:
+		return strconv.AppendBool(buf, value), nil
+	case float64:
+		return appendCanonicalNumber(buf, value)
+	case string:
+		return appendCanonicalString(buf, value), nil
+	case []interface{}:
+		buf = append(buf, '[')
+
+		for i, item := range value {
+			if i > 0 {
+				buf = append(buf, ',')
+			}
+
+			var err error
+			if buf, err = appendCanonical(buf, item); err != nil {
+				return nil, err
+			}
+		}
+
+		return append(buf, ']'), nil
+	case map[string]interface{}:
+		keys := make([]string, 0, len(value))
+		for k := range value {
+			keys = append(keys, k)
+		}
+
+		// keys are sorted by their UTF-16 code units.
+		sort.Slice(keys, func(i, j int) bool {
+			return lessUTF16(keys[i], keys[j])
+		})
+
+		buf = append(buf, '{')
+
+		for i, k := range keys {
+			if i > 0 {
+				buf = append(buf, ',')
+			}
+
+			buf = appendCanonicalString(buf, k)
+			buf = append(buf, ':')
+
+			var err error
+			if buf, err = appendCanonical(buf, value[k]); err != nil {
+				return nil, err
+			}
+		}
+
+		return append(buf, '}'), nil
+	default:
+		return nil, fmt.Errorf("unsupported canonical json type %T", v)
+	}
+}
+
+// appendCanonicalNumber appends the given number serialized as ECMAScript's
+// Number.prototype.toString does, which is what encoding/json implements for
+// float64 except for negative zero.
+func appendCanonicalNumber(buf []byte, f float64) ([]byte, error) {
+	if math.IsNaN(f) || math.IsInf(f, 0) {
+		return nil, fmt.Errorf("unsupported canonical json number %v", f)
+	}
+
+	if f == 0 {
+		return append(buf, '0'), nil
+	}
+
+	b, err := json.Marshal(f)
+	if err != nil {
+		return nil, err
+	}
+
+	return append(buf, b...), nil
+}
+
+// appendCanonicalString appends the given string as a JSON string, escaping
+// only quotation marks, reverse solidi and control characters.
+func appendCanonicalString(buf []byte, s string) []byte {
+	const hex = "0123456789abcdef"
+
+	buf = append(buf, '"')
+
+	for i := 0; i < len(s); {
+		r, size := utf8.DecodeRuneInString(s[i:])
+
+		switch {
+		case r == '"':
+			buf = append(buf, '\\', '"')
+		case r == '\\':
+			buf = append(buf, '\\', '\\')
+		case r == '\b':
+			buf = append(buf, '\\', 'b')
+		case r == '\f':
+			buf = append(buf, '\\', 'f')
+		case r == '\n':
+			buf = append(buf, '\\', 'n')
+		case r == '\r':
+			buf = append(buf, '\\', 'r')
+		case r == '\t':
+			buf = append(buf, '\\', 't')
+		case r < 0x20:
+			buf = append(buf, '\\', 'u', '0', '0', hex[r>>4], hex[r&0xf])
+		default:
+			buf = append(buf, s[i:i+size]...)
+		}
+
+		i += size
+	}
+
+	return append(buf, '"')
+}
+
+// lessUTF16 reports whether a sorts before b when compared as arrays of UTF-16
+// code units.
+func lessUTF16(a, b string) bool {
+	ua, ub := utf16.Encode([]rune(a)), utf16.Encode([]rune(b))
+
+	for i := 0; i < len(ua) && i < len(ub); i++ {
+		if ua[i] != ub[i] {
+			return ua[i] < ub[i]
+		}
+	}
+
+	return len(ua) < len(ub)
+}
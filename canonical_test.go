@@ -0,0 +1,132 @@
+package auditrail_test
+
+import (
+	"context"
+	"encoding/json"
+	"math"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/botchris/go-auditrail"
+	"github.com/botchris/go-auditrail/httpd"
+	"github.com/brianvoe/gofakeit/v6"
+	"github.com/stretchr/testify/require"
+)
+
+func TestEntryCanonicalJSON(t *testing.T) {
+	t.Run("GIVEN an entry with the RFC 8785 sample as details WHEN encoding canonically THEN output matches the RFC", func(t *testing.T) {
+		sample := `{
+			"numbers": [333333333.33333329, 1E30, 4.50, 2e-3, 0.000000000000000000000000001],
+			"string": "\u20ac$\u000F\u000aA'\u0042\u0022\u005c\\\"\/",
+			"literals": [null, true, false]
+		}`
+
+		details := make(map[string]interface{})
+		require.NoError(t, json.Unmarshal([]byte(sample), &details))
+
+		entry := auditrail.NewEntry("john", "order_create", "orders")
+		for k, v := range details {
+			entry.AppendDetails(k, v)
+		}
+
+		b, err := entry.CanonicalJSON()
+		require.NoError(t, err)
+
+		expected := `"details":{"literals":[null,true,false],"numbers":[333333333.3333333,1e+30,4.5,0.002,1e-27],` +
+			`"string":"€$\u000f\nA'B\"\\\\\"/"}`
+		require.Contains(t, string(b), expected)
+	})
+
+	t.Run("GIVEN equivalent entries built with different types and time zones", func(t *testing.T) {
+		at := time.Date(2026, 10, 17, 10, 30, 0, 120000000, time.UTC)
+		zone := time.FixedZone("UTC+2", 2*60*60)
+
+		first := auditrail.NewEntry("john", "order_create", "orders").
+			WithIdempotency("id-1").
+			WithOccurredAt(at).
+			AppendDetails("amount", 10).
+			AppendDetails("ratio", math.Copysign(0, -1)).
+			AppendDetails("http", httpd.Details{Method: "POST", URL: httpd.URL{Path: "/a<b>&c"}})
+
+		second := auditrail.NewEntry("john", "order_create", "orders").
+			WithIdempotency("id-1").
+			WithOccurredAt(at.In(zone)).
+			AppendDetails("ratio", 0).
+			AppendDetails("http", map[string]interface{}{
+				"url":         map[string]interface{}{"path": "/a<b>&c", "host": ""},
+				"method":      "POST",
+				"user_agent":  "",
+				"status_code": "",
+			}).
+			AppendDetails("amount", 10.0)
+
+		t.Run("WHEN encoding them canonically THEN outputs are identical", func(t *testing.T) {
+			a, err := first.CanonicalJSON()
+			require.NoError(t, err)
+
+			b, err := second.CanonicalJSON()
+			require.NoError(t, err)
+
+			require.Equal(t, string(a), string(b))
+			require.Contains(t, string(a), `"occurred_at":"2026-10-17T10:30:00.120000000Z"`)
+			require.Contains(t, string(a), `"path":"/a<b>&c"`)
+			require.Contains(t, string(a), `"ratio":0`)
+		})
+
+		t.Run("WHEN encoding after a JSON round trip THEN output is unchanged", func(t *testing.T) {
+			a, err := first.CanonicalJSON()
+			require.NoError(t, err)
+
+			raw, err := json.Marshal(first)
+			require.NoError(t, err)
+
+			decoded := &auditrail.Entry{}
+			require.NoError(t, json.Unmarshal(raw, decoded))
+
+			b, err := decoded.CanonicalJSON()
+			require.NoError(t, err)
+
+			require.Equal(t, string(a), string(b))
+		})
+	})
+
+	t.Run("GIVEN a file logger with a canonical encoder WHEN logging an entry THEN the canonical form is written", func(t *testing.T) {
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+
+		path := t.TempDir() + "/audit.log"
+		logger, err := auditrail.NewFilePathLogger(path, auditrail.WithFileEncoder(auditrail.NewCanonicalJSONCodec()))
+		require.NoError(t, err)
+
+		entry := auditrail.NewEntry(gofakeit.Username(), "order_create", "orders").
+			AppendDetails("zeta", 1).
+			AppendDetails("alpha", 2)
+
+		require.NoError(t, logger.Log(ctx, entry))
+		require.NoError(t, logger.Close())
+
+		b, err := os.ReadFile(path)
+		require.NoError(t, err)
+
+		expected, err := entry.CanonicalJSON()
+		require.NoError(t, err)
+		require.Equal(t, string(expected), strings.TrimSpace(string(b)))
+	})
+
+	t.Run("GIVEN an entry without data WHEN encoding canonically THEN an error is returned", func(t *testing.T) {
+		_, err := (&auditrail.Entry{}).CanonicalJSON()
+		require.ErrorIs(t, err, auditrail.ErrEmptyEntry)
+
+		t.Run("AND logging it through a hash chain THEN an error is returned", func(t *testing.T) {
+			ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+			defer cancel()
+
+			logger, err := auditrail.NewHashChain(auditrail.NewMemoryLogger())
+			require.NoError(t, err)
+
+			require.ErrorIs(t, logger.Log(ctx, &auditrail.Entry{}), auditrail.ErrEmptyEntry)
+		})
+	})
+}
@@ -0,0 +1,592 @@
+package auditrail
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+)
+
+const (
+	// checkpointDetailsKey is the detail key under which checkpoints are
+	// attached to checkpoint entries.
+	checkpointDetailsKey = "checkpoint"
+
+	// CheckpointActor is the actor of the entries emitted by a checkpointer.
+	CheckpointActor = "auditrail"
+
+	// CheckpointAction is the action of the entries emitted by a
+	// checkpointer.
+	CheckpointAction = "checkpoint"
+)
+
+// ErrEntryNotFound is returned when requesting a proof for an entry that did
+// not pass through a checkpointer.
+var ErrEntryNotFound = errors.New("entry not found")
+
+// CheckpointOption is a function that configures a checkpointer.
+type CheckpointOption func(options *checkpointOptions)
+
+// Checkpoint is a signed commitment to the state of the Merkle tree built by a
+// checkpointer. It is stored under the "checkpoint" detail of the entries
+// emitted by the checkpointer.
+type Checkpoint struct {
+	// TreeSize is the number of entries committed by the checkpoint.
+	TreeSize uint64 `json:"tree_size"`
+
+	// RootHash is the hex encoded Merkle root of the first TreeSize entries.
+	RootHash string `json:"root_hash"`
+
+	// From is the earliest occurrence time of the entries committed since
+	// the previous checkpoint.
+	From time.Time `json:"from"`
+
+	// To is the latest occurrence time of the entries committed since the
+	// previous checkpoint.
+	To time.Time `json:"to"`
+
+	// Signature signs the checkpoint.
+	Signature Signature `json:"signature"`
+}
+
+// InclusionProof proves that an entry is included in a checkpoint.
+type InclusionProof struct {
+	LeafIndex uint64   `json:"leaf_index"`
+	TreeSize  uint64   `json:"tree_size"`
+	Hashes    [][]byte `json:"hashes"`
+}
+
+type checkpointOptions struct {
+	every    int
+	interval time.Duration
+	timeout  time.Duration
+	module   string
+	store    CheckpointStore
+}
+
+var defaultCheckpointOptions = checkpointOptions{
+	every:    1000,
+	interval: time.Minute,
+	timeout:  10 * time.Second,
+	module:   "auditrail",
+}
+
+var _ ShutdownLogger = (*Checkpointer)(nil)
+
+// Checkpointer is a logger that batches the entries written to an underlying
+// logger into an append-only Merkle tree, and periodically writes a signed
+// [Checkpoint] of the tree to the same logger.
+//
+// Checkpoints are much cheaper than per entry signatures while still allowing
+// to prove that a given entry was in the trail at checkpoint time using
+// inclusion proofs, and that the trail was only appended to between two
+// checkpoints using consistency proofs.
+//
+// Leaves are computed from the entry as it reaches the checkpointer, so it
+// should sit beneath any decorator that enriches entries.
+//
+// The tree and the position of every entry in it are kept in a
+// [CheckpointStore], in memory by default. Use a persistent store such as
+// [NewFileCheckpointStore] so that checkpoints keep covering the trail
+// written before a restart. The store is not closed by the checkpointer, so
+// proofs can still be built after closing the checkpointer.
+//
+// This struct is safe for concurrent use.
+type Checkpointer struct {
+	dst     Logger
+	keys    KeyProvider
+	opts    checkpointOptions
+	tree    *MerkleTree
+	pending int
+	from    time.Time
+	to      time.Time
+	last    Checkpoint
+	stop    chan struct{}
+	stopped bool
+	mu      sync.Mutex
+	signing sync.Mutex
+	wg      sync.WaitGroup
+}
+
+// NewCheckpointer builds a new checkpointer writing to the given logger and
+// signing checkpoints with the active key of the given provider. The tree is
+// loaded from the configured store. See options for configuration.
+func NewCheckpointer(dst Logger, keys KeyProvider, options ...CheckpointOption) (*Checkpointer, error) {
+	opts := defaultCheckpointOptions
+
+	for _, option := range options {
+		option(&opts)
+	}
+
+	if opts.store == nil {
+		opts.store = NewMemoryCheckpointStore()
+	}
+
+	tree, err := LoadMerkleTree(opts.store)
+	if err != nil {
+		return nil, err
+	}
+
+	c := &Checkpointer{
+		dst:  dst,
+		keys: keys,
+		opts: opts,
+		tree: tree,
+		stop: make(chan struct{}),
+	}
+
+	c.wg.Add(1)
+
+	go c.run()
+
+	return c, nil
+}
+
+// Log writes the given entry to the underlying logger and appends it to the
+// Merkle tree. A checkpoint is emitted once enough entries are pending.
+//
+// The entry is written outside the lock, so writes to the underlying logger
+// run concurrently, and appended once written, so the tree only commits to
+// entries of the trail. If the entry is written but cannot be appended to the
+// tree, the returned error wraps [ErrPermanent], so the entry is not written
+// again.
+func (c *Checkpointer) Log(ctx context.Context, entry *Entry) error {
+	if entry == nil {
+		return c.dst.Log(ctx, entry)
+	}
+
+	leaf, err := LeafHash(entry)
+	if err != nil {
+		return fmt.Errorf("%w: could not hash entry", err)
+	}
+
+	if err = c.dst.Log(ctx, entry); err != nil {
+		return err
+	}
+
+	c.mu.Lock()
+
+	idx, err := c.tree.Append(leaf)
+	if err == nil {
+		err = c.opts.store.Index(entry.GetIdempotencyID(), idx)
+	}
+
+	if err != nil {
+		c.mu.Unlock()
+
+		return fmt.Errorf("%w: %w: entry was written but could not be added to the tree", ErrPermanent, err)
+	}
+
+	at := entry.GetOccurredAt()
+
+	if c.pending == 0 || at.Before(c.from) {
+		c.from = at
+	}
+
+	if c.pending == 0 || at.After(c.to) {
+		c.to = at
+	}
+
+	c.pending++
+	due := c.pending >= c.opts.every
+
+	c.mu.Unlock()
+
+	if due {
+		// the entry has already been written, a failed checkpoint will be
+		// retried on the next write or tick.
+		_, _ = c.checkpoint(ctx, c.opts.every)
+	}
+
+	return nil
+}
+
+// Checkpoint forces a checkpoint of the current tree to be written, even if
+// no entries were written since the last one.
+func (c *Checkpointer) Checkpoint(ctx context.Context) (Checkpoint, error) {
+	return c.checkpoint(ctx, 0)
+}
+
+// LastCheckpoint returns the last checkpoint successfully written, and whether
+// there is one.
+func (c *Checkpointer) LastCheckpoint() (Checkpoint, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return c.last, c.last.TreeSize > 0
+}
+
+// InclusionProof returns the proof that the entry with the given idempotency
+// ID is included in the tree of the given size, usually the size of a
+// checkpoint.
+func (c *Checkpointer) InclusionProof(idempotencyID string, treeSize uint64) (InclusionProof, error) {
+	idx, ok, err := c.opts.store.Find(idempotencyID)
+	if err != nil {
+		return InclusionProof{}, fmt.Errorf("%w: could not look up entry %s", err, idempotencyID)
+	}
+
+	if !ok {
+		return InclusionProof{}, fmt.Errorf("%w: %s", ErrEntryNotFound, idempotencyID)
+	}
+
+	hashes, err := c.tree.InclusionProof(idx, treeSize)
+	if err != nil {
+		return InclusionProof{}, err
+	}
+
+	return InclusionProof{
+		LeafIndex: idx,
+		TreeSize:  treeSize,
+		Hashes:    hashes,
+	}, nil
+}
+
+// ConsistencyProof returns the proof that the tree of the first size is a
+// prefix of the tree of the second size.
+func (c *Checkpointer) ConsistencyProof(first, second uint64) ([][]byte, error) {
+	return c.tree.ConsistencyProof(first, second)
+}
+
+// Close writes a final checkpoint if there are pending entries, within the
+// checkpoint timeout, and closes the underlying logger.
+func (c *Checkpointer) Close() error {
+	if !c.halt() {
+		return nil
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), c.opts.timeout)
+	defer cancel()
+
+	_, err := c.checkpoint(ctx, 1)
+
+	if cErr := c.dst.Close(); cErr != nil {
+		return cErr
+	}
+
+	return err
+}
+
+// Shutdown writes a final checkpoint if there are pending entries, and shuts
+// down the underlying logger, both within ctx. The checkpointer buffers no
+// entries, so the report is the one of the underlying logger.
+func (c *Checkpointer) Shutdown(ctx context.Context) (ShutdownReport, error) {
+	if !c.halt() {
+		return ShutdownReport{}, nil
+	}
+
+	_, err := c.checkpoint(ctx, 1)
+
+	report, sErr := Shutdown(ctx, c.dst)
+	if sErr != nil {
+		return report, sErr
+	}
+
+	return report, err
+}
+
+// halt stops emitting checkpoints on interval ticks, returning false if it was
+// already stopped.
+func (c *Checkpointer) halt() bool {
+	c.mu.Lock()
+
+	if c.stopped {
+		c.mu.Unlock()
+
+		return false
+	}
+
+	c.stopped = true
+	close(c.stop)
+	c.mu.Unlock()
+	c.wg.Wait()
+
+	return true
+}
+
+func (c *Checkpointer) Closed() <-chan struct{} {
+	return c.dst.Closed()
+}
+
+func (c *Checkpointer) IsClosed() bool {
+	return c.dst.IsClosed()
+}
+
+// run emits a checkpoint on every interval tick if there are pending entries.
+func (c *Checkpointer) run() {
+	defer c.wg.Done()
+
+	ticker := time.NewTicker(c.opts.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-c.stop:
+			return
+		case <-ticker.C:
+			ctx, cancel := context.WithTimeout(context.Background(), c.opts.timeout)
+			_, _ = c.checkpoint(ctx, 1)
+
+			cancel()
+		}
+	}
+}
+
+// checkpoint signs and writes a checkpoint of the current tree if at least
+// the given number of entries are pending, returning the last checkpoint
+// otherwise. Signing and writing happen outside the lock, so entries keep
+// being logged meanwhile; checkpoints are serialized so they are written in
+// order.
+func (c *Checkpointer) checkpoint(ctx context.Context, pending int) (Checkpoint, error) {
+	c.signing.Lock()
+	defer c.signing.Unlock()
+
+	c.mu.Lock()
+
+	if c.pending < pending {
+		last := c.last
+		c.mu.Unlock()
+
+		return last, nil
+	}
+
+	size := c.tree.Size()
+	committed, from, to := c.pending, c.from, c.to
+
+	// entries logged while signing are pending for the next checkpoint, and
+	// the committed ones are restored if the checkpoint fails.
+	c.pending = 0
+	c.mu.Unlock()
+
+	cp, err := c.sign(ctx, size, committed, from, to)
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if err != nil {
+		if committed > 0 {
+			if c.pending == 0 || from.Before(c.from) {
+				c.from = from
+			}
+
+			if c.pending == 0 || to.After(c.to) {
+				c.to = to
+			}
+		}
+
+		c.pending += committed
+
+		return Checkpoint{}, err
+	}
+
+	c.last = cp
+
+	return cp, nil
+}
+
+// sign signs and writes a checkpoint of the first size leaves of the tree,
+// committing the given number of entries which occurred between from and to.
+func (c *Checkpointer) sign(ctx context.Context, size uint64, committed int, from, to time.Time) (Checkpoint, error) {
+	if err := c.opts.store.Sync(); err != nil {
+		return Checkpoint{}, err
+	}
+
+	signer, err := c.keys.Signer()
+	if err != nil {
+		return Checkpoint{}, fmt.Errorf("%w: could not get signing key", err)
+	}
+
+	root, err := c.tree.Root(size)
+	if err != nil {
+		return Checkpoint{}, err
+	}
+
+	cp := Checkpoint{
+		TreeSize: size,
+		RootHash: hex.EncodeToString(root),
+		Signature: Signature{
+			KeyID:     signer.KeyID(),
+			Algorithm: signer.Algorithm(),
+		},
+	}
+
+	if committed > 0 {
+		cp.From, cp.To = from, to
+	}
+
+	message, err := canonicalize(cp)
+	if err != nil {
+		return Checkpoint{}, err
+	}
+
+	value, err := signer.Sign(message)
+	if err != nil {
+		return Checkpoint{}, fmt.Errorf("%w: could not sign checkpoint", err)
+	}
+
+	cp.Signature.Value = base64.StdEncoding.EncodeToString(value)
+
+	entry := NewEntry(CheckpointActor, CheckpointAction, c.opts.module).
+		WithOccurredAt(time.Now()).
+		AppendDetails(checkpointDetailsKey, cp)
+
+	if err = c.dst.Log(ctx, entry); err != nil {
+		return Checkpoint{}, err
+	}
+
+	return cp, nil
+}
+
+// Verify checks the signature of the checkpoint against the keys of the given
+// provider. The returned error wraps the same errors as [Verifier.Verify].
+func (cp Checkpoint) Verify(keys KeyProvider) error {
+	key, err := keys.PublicKey(cp.Signature.KeyID)
+	if err != nil {
+		return err
+	}
+
+	value, err := base64.StdEncoding.DecodeString(cp.Signature.Value)
+	if err != nil {
+		return fmt.Errorf("%w: malformed signature value", ErrInvalidSignature)
+	}
+
+	unsigned := cp
+	unsigned.Signature.Value = ""
+
+	message, err := canonicalize(unsigned)
+	if err != nil {
+		return err
+	}
+
+	if err = verifySignature(key, cp.Signature.Algorithm, message, value); err != nil {
+		return err
+	}
+
+	if key.Revoked {
+		return fmt.Errorf("%w: %s", ErrRevokedKey, cp.Signature.KeyID)
+	}
+
+	return nil
+}
+
+// VerifyInclusion checks that the given proof proves the inclusion of the
+// given entry in the checkpoint. It does not verify the checkpoint signature.
+func (cp Checkpoint) VerifyInclusion(entry *Entry, proof InclusionProof) error {
+	if proof.TreeSize != cp.TreeSize {
+		return fmt.Errorf("%w: proof is for tree size %d, checkpoint has size %d",
+			ErrInvalidProof, proof.TreeSize, cp.TreeSize)
+	}
+
+	root, err := hex.DecodeString(cp.RootHash)
+	if err != nil {
+		return fmt.Errorf("%w: malformed root hash", ErrInvalidProof)
+	}
+
+	leaf, err := LeafHash(entry)
+	if err != nil {
+		return err
+	}
+
+	return VerifyInclusion(leaf, proof.LeafIndex, proof.TreeSize, proof.Hashes, root)
+}
+
+// CheckpointFromEntry extracts the checkpoint carried by the given entry, as
+// written by a checkpointer or read back from a sink, and reports whether the
+// entry is a checkpoint entry.
+func CheckpointFromEntry(entry *Entry) (Checkpoint, bool) {
+	if entry == nil || entry.data == nil || entry.GetAction() != CheckpointAction {
+		return Checkpoint{}, false
+	}
+
+	switch raw := entry.data.Details[checkpointDetailsKey].(type) {
+	case nil:
+		return Checkpoint{}, false
+	case Checkpoint:
+		return raw, true
+	default:
+		cp := Checkpoint{}
+
+		encoded, err := json.Marshal(raw)
+		if err != nil {
+			return Checkpoint{}, false
+		}
+
+		if err = json.Unmarshal(encoded, &cp); err != nil {
+			return Checkpoint{}, false
+		}
+
+		return cp, true
+	}
+}
+
+// LeafHash returns the Merkle leaf hash of the given entry, computed over its
+// canonical encoding without integrity metadata.
+func LeafHash(entry *Entry) ([]byte, error) {
+	canonical, err := entry.canonicalWith("", nil)
+	if err != nil {
+		return nil, err
+	}
+
+	return HashLeaf(canonical), nil
+}
+
+// WithCheckpointEvery sets the number of entries after which a checkpoint is
+// emitted. If n is less than or equal to zero, it will be set to 1000.
+func WithCheckpointEvery(n int) CheckpointOption {
+	return func(opts *checkpointOptions) {
+		if n <= 0 {
+			n = 1000
+		}
+
+		opts.every = n
+	}
+}
+
+// WithCheckpointInterval sets the maximum amount of time pending entries wait
+// for a checkpoint. If interval is less than or equal to zero, it will be set
+// to one minute.
+func WithCheckpointInterval(interval time.Duration) CheckpointOption {
+	return func(opts *checkpointOptions) {
+		if interval <= 0 {
+			interval = time.Minute
+		}
+
+		opts.interval = interval
+	}
+}
+
+// WithCheckpointTimeout sets the maximum amount of time given to write the
+// checkpoints emitted on interval ticks and on close. If timeout is less than
+// or equal to zero, it will be set to 10 seconds.
+func WithCheckpointTimeout(timeout time.Duration) CheckpointOption {
+	return func(opts *checkpointOptions) {
+		if timeout <= 0 {
+			timeout = 10 * time.Second
+		}
+
+		opts.timeout = timeout
+	}
+}
+
+// WithCheckpointStore sets the store of the tree. If store is nil, the tree is
+// kept in memory.
+func WithCheckpointStore(store CheckpointStore) CheckpointOption {
+	return func(opts *checkpointOptions) {
+		opts.store = store
+	}
+}
+
+// WithCheckpointModule sets the module of the emitted checkpoint entries. If
+// module is empty, it will be set to "auditrail".
+func WithCheckpointModule(module string) CheckpointOption {
+	return func(opts *checkpointOptions) {
+		if module == "" {
+			module = "auditrail"
+		}
+
+		opts.module = module
+	}
+}
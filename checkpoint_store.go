@@ -0,0 +1,277 @@
+package auditrail
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/sha256"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sync"
+)
+
+const (
+	// checkpointLeavesFile is the file of a file checkpoint store holding the
+	// leaf hashes, one after another.
+	checkpointLeavesFile = "leaves"
+
+	// checkpointIndexFile is the file of a file checkpoint store holding the
+	// leaf positions of entries, one JSON document per line.
+	checkpointIndexFile = "index"
+)
+
+// CheckpointStore persists the Merkle tree built by a [Checkpointer], along
+// with the position of the leaf of every entry, so that checkpoints keep
+// covering the earlier trail after a restart.
+//
+// All methods should be goroutine safe.
+type CheckpointStore interface {
+	MerkleStore
+
+	// Index records the position of the leaf of the entry with the given
+	// idempotency ID.
+	Index(idempotencyID string, index uint64) error
+
+	// Find returns the position of the first leaf recorded for the entry
+	// with the given idempotency ID, and whether there is one.
+	Find(idempotencyID string) (uint64, bool, error)
+
+	// Sync makes the stored leaves durable. It is called before signing every
+	// checkpoint, so checkpointed trees survive crashes.
+	Sync() error
+
+	// Close releases the resources held by the store. It is not called by
+	// the checkpointer, which does not own its store.
+	Close() error
+}
+
+type memoryCheckpointStore struct {
+	MerkleStore
+	index map[string]uint64
+	mu    sync.RWMutex
+}
+
+// NewMemoryCheckpointStore returns a [CheckpointStore] that keeps the tree and
+// the index of entries in memory, so they grow for the life of the process
+// and are lost on restart. Useful for testing.
+func NewMemoryCheckpointStore() CheckpointStore {
+	return &memoryCheckpointStore{
+		MerkleStore: NewMemoryMerkleStore(),
+		index:       make(map[string]uint64),
+	}
+}
+
+func (s *memoryCheckpointStore) Index(idempotencyID string, index uint64) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.index[idempotencyID]; !ok {
+		s.index[idempotencyID] = index
+	}
+
+	return nil
+}
+
+func (s *memoryCheckpointStore) Find(idempotencyID string) (uint64, bool, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	index, ok := s.index[idempotencyID]
+
+	return index, ok, nil
+}
+
+func (s *memoryCheckpointStore) Sync() error {
+	return nil
+}
+
+func (s *memoryCheckpointStore) Close() error {
+	return nil
+}
+
+type fileCheckpointStore struct {
+	leaves *os.File
+	index  *os.File
+	mu     sync.RWMutex
+}
+
+// checkpointIndexRecord is a line of the index file of a file checkpoint
+// store.
+type checkpointIndexRecord struct {
+	ID    string `json:"id"`
+	Index uint64 `json:"index"`
+}
+
+// NewFileCheckpointStore returns a [CheckpointStore] that persists the tree and
+// the index of entries in files of the given directory, which is created if
+// needed. Leaf hashes must be SHA-256 hashes, as computed by [HashLeaf].
+//
+// Records torn by a crash are discarded when the store is opened. The files
+// are kept open until the store is closed. Looking up an entry scans the index
+// file, so proofs are cheap to keep but slower to build for long trails.
+func NewFileCheckpointStore(dir string) (CheckpointStore, error) {
+	if err := os.MkdirAll(dir, 0o750); err != nil {
+		return nil, fmt.Errorf("%w: could not create checkpoint store directory", err)
+	}
+
+	leavesPath := filepath.Join(dir, checkpointLeavesFile)
+	indexPath := filepath.Join(dir, checkpointIndexFile)
+
+	if err := repairCheckpointStore(leavesPath, indexPath); err != nil {
+		return nil, err
+	}
+
+	leaves, err := os.OpenFile(leavesPath, os.O_APPEND|os.O_CREATE|os.O_RDWR, 0o600)
+	if err != nil {
+		return nil, fmt.Errorf("%w: could not open merkle leaves", err)
+	}
+
+	index, err := os.OpenFile(indexPath, os.O_APPEND|os.O_CREATE|os.O_RDWR, 0o600)
+	if err != nil {
+		_ = leaves.Close()
+
+		return nil, fmt.Errorf("%w: could not open checkpoint index", err)
+	}
+
+	return &fileCheckpointStore{leaves: leaves, index: index}, nil
+}
+
+func (s *fileCheckpointStore) Append(leafHash []byte) error {
+	if len(leafHash) != sha256.Size {
+		return fmt.Errorf("invalid leaf hash of %d bytes", len(leafHash))
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	_, err := s.leaves.Write(leafHash)
+
+	return err
+}
+
+func (s *fileCheckpointStore) Leaves(from, to uint64) ([][]byte, error) {
+	if from > to {
+		return nil, fmt.Errorf("invalid leaf range [%d, %d)", from, to)
+	}
+
+	leaves := make([][]byte, 0, to-from)
+	if from == to {
+		return leaves, nil
+	}
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	buf := make([]byte, (to-from)*sha256.Size)
+	if _, err := s.leaves.ReadAt(buf, int64(from*sha256.Size)); err != nil {
+		return nil, fmt.Errorf("%w: could not read merkle leaves [%d, %d)", err, from, to)
+	}
+
+	for off := 0; off < len(buf); off += sha256.Size {
+		leaves = append(leaves, buf[off:off+sha256.Size:off+sha256.Size])
+	}
+
+	return leaves, nil
+}
+
+func (s *fileCheckpointStore) Size() (uint64, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	info, err := s.leaves.Stat()
+	if err != nil {
+		return 0, err
+	}
+
+	return uint64(info.Size()) / sha256.Size, nil
+}
+
+func (s *fileCheckpointStore) Index(idempotencyID string, index uint64) error {
+	line, err := json.Marshal(checkpointIndexRecord{ID: idempotencyID, Index: index})
+	if err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	_, err = s.index.Write(append(line, '\n'))
+
+	return err
+}
+
+func (s *fileCheckpointStore) Find(idempotencyID string) (uint64, bool, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	info, err := s.index.Stat()
+	if err != nil {
+		return 0, false, err
+	}
+
+	scanner := bufio.NewScanner(io.NewSectionReader(s.index, 0, info.Size()))
+	scanner.Buffer(make([]byte, 0, 64*1024), 1<<20)
+
+	for scanner.Scan() {
+		record := checkpointIndexRecord{}
+		if err = json.Unmarshal(scanner.Bytes(), &record); err != nil {
+			continue
+		}
+
+		if record.ID == idempotencyID {
+			return record.Index, true, nil
+		}
+	}
+
+	return 0, false, scanner.Err()
+}
+
+func (s *fileCheckpointStore) Sync() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, fd := range []*os.File{s.leaves, s.index} {
+		if err := fd.Sync(); err != nil {
+			return fmt.Errorf("%w: could not sync checkpoint store", err)
+		}
+	}
+
+	return nil
+}
+
+func (s *fileCheckpointStore) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return errors.Join(s.leaves.Close(), s.index.Close())
+}
+
+// repairCheckpointStore discards the records torn by a crash at the end of
+// the files of a file checkpoint store.
+func repairCheckpointStore(leavesPath, indexPath string) error {
+	if info, err := os.Stat(leavesPath); err == nil && info.Size()%sha256.Size != 0 {
+		if err = os.Truncate(leavesPath, info.Size()-info.Size()%sha256.Size); err != nil {
+			return fmt.Errorf("%w: could not repair merkle leaves", err)
+		}
+	}
+
+	b, err := os.ReadFile(indexPath)
+	if os.IsNotExist(err) {
+		return nil
+	}
+
+	if err != nil {
+		return fmt.Errorf("%w: could not repair checkpoint index", err)
+	}
+
+	if end := bytes.LastIndexByte(b, '\n') + 1; end != len(b) {
+		if err = os.Truncate(indexPath, int64(end)); err != nil {
+			return fmt.Errorf("%w: could not repair checkpoint index", err)
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,303 @@
+package auditrail_test
+
+import (
+	"context"
+	"crypto/ed25519"
+	"crypto/rand"
+	"encoding/hex"
+	"testing"
+	"time"
+
+	"github.com/botchris/go-auditrail"
+	"github.com/brianvoe/gofakeit/v6"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCheckpointer(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	t.Run("GIVEN a checkpointer emitting a checkpoint every 4 entries", func(t *testing.T) {
+		_, key, err := ed25519.GenerateKey(rand.Reader)
+		require.NoError(t, err)
+
+		ring := auditrail.NewKeyRing()
+		require.NoError(t, ring.Rotate(auditrail.NewEd25519Signer("key-1", key)))
+
+		dst := auditrail.NewMemoryLogger()
+		cp, err := auditrail.NewCheckpointer(dst, ring,
+			auditrail.WithCheckpointEvery(4),
+			auditrail.WithCheckpointInterval(time.Hour),
+		)
+		require.NoError(t, err)
+
+		t.Run("WHEN logging 10 entries AND closing the checkpointer", func(t *testing.T) {
+			entries := make([]*auditrail.Entry, 0, 10)
+
+			for i := 0; i < 10; i++ {
+				entry := auditrail.NewEntry(gofakeit.Username(), gofakeit.VerbAction(), gofakeit.AppName())
+				entries = append(entries, entry)
+
+				require.NoError(t, cp.Log(ctx, entry))
+			}
+
+			require.NoError(t, cp.Close())
+
+			checkpoints := make(map[uint64]auditrail.Checkpoint)
+
+			for _, entry := range dst.Trail() {
+				if c, ok := auditrail.CheckpointFromEntry(entry); ok {
+					checkpoints[c.TreeSize] = c
+				}
+			}
+
+			t.Run("THEN signed checkpoints are written at sizes 4, 8 and 10", func(t *testing.T) {
+				require.Len(t, checkpoints, 3)
+				require.Equal(t, 13, dst.Size())
+
+				for _, size := range []uint64{4, 8, 10} {
+					require.Contains(t, checkpoints, size)
+					require.NoError(t, checkpoints[size].Verify(ring))
+				}
+
+				last, ok := cp.LastCheckpoint()
+				require.True(t, ok)
+				require.EqualValues(t, 10, last.TreeSize)
+				require.Equal(t, entries[8].GetOccurredAt().UnixNano(), last.From.UnixNano())
+			})
+
+			t.Run("THEN each entry can be proven to be included in later checkpoints", func(t *testing.T) {
+				for i, entry := range entries {
+					for _, size := range []uint64{4, 8, 10} {
+						proof, pErr := cp.InclusionProof(entry.GetIdempotencyID(), size)
+						if uint64(i) >= size {
+							require.Error(t, pErr)
+
+							continue
+						}
+
+						require.NoError(t, pErr)
+						require.NoError(t, checkpoints[size].VerifyInclusion(entry, proof))
+					}
+				}
+			})
+
+			t.Run("THEN a modified entry cannot be proven", func(t *testing.T) {
+				proof, pErr := cp.InclusionProof(entries[1].GetIdempotencyID(), 8)
+				require.NoError(t, pErr)
+
+				entries[1].WithAuthMethod("forged")
+				require.ErrorIs(t, checkpoints[8].VerifyInclusion(entries[1], proof), auditrail.ErrInvalidProof)
+			})
+
+			t.Run("THEN checkpoints are consistent with each other", func(t *testing.T) {
+				sizes := []uint64{4, 8, 10}
+
+				for i := 0; i < len(sizes)-1; i++ {
+					first, second := checkpoints[sizes[i]], checkpoints[sizes[i+1]]
+
+					proof, pErr := cp.ConsistencyProof(first.TreeSize, second.TreeSize)
+					require.NoError(t, pErr)
+
+					require.NoError(t, auditrail.VerifyConsistency(
+						first.TreeSize,
+						second.TreeSize,
+						mustHex(t, first.RootHash),
+						mustHex(t, second.RootHash),
+						proof,
+					))
+				}
+			})
+
+			t.Run("THEN an unknown entry cannot be proven", func(t *testing.T) {
+				_, pErr := cp.InclusionProof(gofakeit.UUID(), 10)
+				require.ErrorIs(t, pErr, auditrail.ErrEntryNotFound)
+			})
+		})
+	})
+
+	t.Run("GIVEN a checkpointer with a short interval WHEN logging a single entry THEN a checkpoint is emitted", func(t *testing.T) {
+		_, key, err := ed25519.GenerateKey(rand.Reader)
+		require.NoError(t, err)
+
+		ring := auditrail.NewKeyRing()
+		require.NoError(t, ring.Rotate(auditrail.NewEd25519Signer("key-1", key)))
+
+		cp, err := auditrail.NewCheckpointer(auditrail.NewMemoryLogger(), ring,
+			auditrail.WithCheckpointEvery(100),
+			auditrail.WithCheckpointInterval(10*time.Millisecond),
+		)
+		require.NoError(t, err)
+
+		require.NoError(t, cp.Log(ctx, auditrail.NewEntry(gofakeit.Username(), gofakeit.VerbAction(), gofakeit.AppName())))
+
+		require.Eventually(t, func() bool {
+			last, ok := cp.LastCheckpoint()
+
+			return ok && last.TreeSize == 1
+		}, time.Second, 5*time.Millisecond)
+
+		checkClose(t, ctx, cp)
+	})
+
+	t.Run("GIVEN a slow underlying logger WHEN logging entries concurrently THEN they are written concurrently", func(t *testing.T) {
+		_, key, err := ed25519.GenerateKey(rand.Reader)
+		require.NoError(t, err)
+
+		ring := auditrail.NewKeyRing()
+		require.NoError(t, ring.Rotate(auditrail.NewEd25519Signer("key-1", key)))
+
+		dst := auditrail.NewMemoryLogger()
+		stuck := &blocked{Logger: dst, release: make(chan struct{}), received: make(chan struct{}, 2)}
+
+		cp, err := auditrail.NewCheckpointer(stuck, ring, auditrail.WithCheckpointInterval(time.Hour))
+		require.NoError(t, err)
+
+		done := make(chan error, 2)
+
+		for i := 0; i < 2; i++ {
+			go func() { done <- cp.Log(ctx, newFakeEntry()) }()
+		}
+
+		for i := 0; i < 2; i++ {
+			select {
+			case <-stuck.received:
+			case <-time.After(time.Second):
+				require.FailNow(t, "writes were serialized")
+			}
+		}
+
+		close(stuck.release)
+		require.NoError(t, <-done)
+		require.NoError(t, <-done)
+
+		last, err := cp.Checkpoint(ctx)
+		require.NoError(t, err)
+		require.EqualValues(t, 2, last.TreeSize)
+
+		checkClose(t, ctx, cp)
+	})
+
+	t.Run("GIVEN a checkpointer writing to a queue WHEN shutting it down THEN the final checkpoint is flushed", func(t *testing.T) {
+		_, key, err := ed25519.GenerateKey(rand.Reader)
+		require.NoError(t, err)
+
+		ring := auditrail.NewKeyRing()
+		require.NoError(t, ring.Rotate(auditrail.NewEd25519Signer("key-1", key)))
+
+		dst := auditrail.NewMemoryLogger()
+		cp, err := auditrail.NewCheckpointer(auditrail.NewQueue(&delayed{Logger: dst, delay: time.Millisecond}), ring,
+			auditrail.WithCheckpointInterval(time.Hour),
+		)
+		require.NoError(t, err)
+
+		for i := 0; i < 3; i++ {
+			require.NoError(t, cp.Log(ctx, newFakeEntry()))
+		}
+
+		report, err := auditrail.Shutdown(ctx, cp)
+		require.NoError(t, err)
+		require.Positive(t, report.Flushed)
+		require.Zero(t, report.Abandoned)
+		require.Equal(t, 4, dst.Size())
+
+		last, ok := cp.LastCheckpoint()
+		require.True(t, ok)
+		require.EqualValues(t, 3, last.TreeSize)
+		require.True(t, cp.IsClosed())
+	})
+
+	t.Run("GIVEN a checkpointer with a file store WHEN restarting it THEN checkpoints cover the entries logged before the restart", func(t *testing.T) {
+		_, key, err := ed25519.GenerateKey(rand.Reader)
+		require.NoError(t, err)
+
+		ring := auditrail.NewKeyRing()
+		require.NoError(t, ring.Rotate(auditrail.NewEd25519Signer("key-1", key)))
+
+		dir := t.TempDir()
+		entries := make([]*auditrail.Entry, 0, 6)
+
+		var last auditrail.Checkpoint
+
+		for run := 0; run < 2; run++ {
+			store, sErr := auditrail.NewFileCheckpointStore(dir)
+			require.NoError(t, sErr)
+
+			cp, cErr := auditrail.NewCheckpointer(auditrail.NewMemoryLogger(), ring,
+				auditrail.WithCheckpointStore(store),
+				auditrail.WithCheckpointInterval(time.Hour),
+			)
+			require.NoError(t, cErr)
+
+			for i := 0; i < 3; i++ {
+				entry := newFakeEntry()
+				entries = append(entries, entry)
+
+				require.NoError(t, cp.Log(ctx, entry))
+			}
+
+			require.NoError(t, cp.Close())
+
+			var ok bool
+
+			last, ok = cp.LastCheckpoint()
+			require.True(t, ok)
+
+			proof, pErr := cp.InclusionProof(entries[0].GetIdempotencyID(), last.TreeSize)
+			require.NoError(t, pErr)
+			require.NoError(t, last.VerifyInclusion(entries[0], proof))
+			require.NoError(t, store.Close())
+		}
+
+		require.EqualValues(t, 6, last.TreeSize)
+	})
+}
+
+func TestMerkleTreeProofs(t *testing.T) {
+	tree := auditrail.NewMerkleTree()
+	leaves := make([][]byte, 0, 33)
+
+	for i := 0; i < 33; i++ {
+		leaf := auditrail.HashLeaf([]byte(gofakeit.UUID()))
+		leaves = append(leaves, leaf)
+
+		_, err := tree.Append(leaf)
+		require.NoError(t, err)
+	}
+
+	for size := uint64(1); size <= tree.Size(); size++ {
+		root, err := tree.Root(size)
+		require.NoError(t, err)
+
+		for index := uint64(0); index < size; index++ {
+			proof, pErr := tree.InclusionProof(index, size)
+			require.NoError(t, pErr)
+			require.NoError(t, auditrail.VerifyInclusion(leaves[index], index, size, proof, root))
+
+			other := (index + 1) % size
+			if other != index {
+				require.ErrorIs(t, auditrail.VerifyInclusion(leaves[other], index, size, proof, root), auditrail.ErrInvalidProof)
+			}
+		}
+
+		for first := uint64(1); first <= size; first++ {
+			firstRoot, rErr := tree.Root(first)
+			require.NoError(t, rErr)
+
+			proof, pErr := tree.ConsistencyProof(first, size)
+			require.NoError(t, pErr)
+			require.NoError(t, auditrail.VerifyConsistency(first, size, firstRoot, root, proof))
+
+			if first < size {
+				require.ErrorIs(t, auditrail.VerifyConsistency(first, size, root, root, proof), auditrail.ErrInvalidProof)
+			}
+		}
+	}
+}
+
+func mustHex(t *testing.T, s string) []byte {
+	b, err := hex.DecodeString(s)
+	require.NoError(t, err)
+
+	return b
+}
@@ -0,0 +1,108 @@
+package auditrail
+
+import (
+	"encoding/json"
+	"strings"
+)
+
+// Encoder encodes entries into the payload written by a logger.
+//
+// All methods should be goroutine safe.
+type Encoder interface {
+	// Encode returns the encoded representation of the given entry.
+	Encode(*Entry) ([]byte, error)
+}
+
+// Decoder decodes entries from the payload written by a logger.
+//
+// All methods should be goroutine safe.
+type Decoder interface {
+	// Decode returns the entry represented by the given payload.
+	Decode([]byte) (*Entry, error)
+}
+
+// Codec is an [Encoder] and [Decoder] pair for a given format, so that
+// consumers can decode exactly what producers encoded.
+type Codec interface {
+	Encoder
+	Decoder
+
+	// ContentType returns the media type of the encoded payloads.
+	ContentType() string
+}
+
+// EncoderFunc is an adapter to allow the use of ordinary functions as
+// encoders.
+type EncoderFunc func(*Entry) ([]byte, error)
+
+// Encode calls f(entry).
+func (f EncoderFunc) Encode(entry *Entry) ([]byte, error) {
+	return f(entry)
+}
+
+type jsonCodec struct {
+	canonical bool
+}
+
+// NewJSONCodec returns a [Codec] that encodes entries using
+// [Entry.MarshalJSON]. This is the default encoder of every logger.
+func NewJSONCodec() Codec {
+	return jsonCodec{}
+}
+
+// NewCanonicalJSONCodec returns a [Codec] that encodes entries using
+// [Entry.CanonicalJSON], producing stable bytes for hashing, signing and
+// deduplication downstream.
+func NewCanonicalJSONCodec() Codec {
+	return jsonCodec{canonical: true}
+}
+
+func (c jsonCodec) Encode(entry *Entry) ([]byte, error) {
+	if c.canonical {
+		return entry.CanonicalJSON()
+	}
+
+	return json.Marshal(entry)
+}
+
+func (c jsonCodec) Decode(data []byte) (*Entry, error) {
+	entry := &Entry{}
+	if err := json.Unmarshal(data, entry); err != nil {
+		return nil, err
+	}
+
+	return entry, nil
+}
+
+func (c jsonCodec) ContentType() string {
+	return "application/json"
+}
+
+// isBinaryEncoder reports whether the given encoder produces binary payloads
+// that cannot be delimited by newlines. Encoders that do not declare a content
+// type are assumed to produce text.
+func isBinaryEncoder(encoder Encoder) bool {
+	typed, ok := encoder.(interface{ ContentType() string })
+	if !ok {
+		return false
+	}
+
+	ct := typed.ContentType()
+
+	return !strings.HasPrefix(ct, "text/") && !strings.HasSuffix(ct, "json")
+}
+
+// encodeRecord encodes the given entry into a record of a stream, followed by
+// a newline unless the encoder is binary.
+func encodeRecord(encoder Encoder, entry *Entry) ([]byte, error) {
+	record, err := encoder.Encode(entry)
+	if err != nil {
+		return nil, err
+	}
+
+	if !isBinaryEncoder(encoder) {
+		record = append(record, '\n')
+	}
+
+	return record, nil
+}
@@ -0,0 +1,55 @@
+package auditrail
+
+import (
+	"reflect"
+
+	"github.com/fxamacker/cbor/v2"
+)
+
+type cborCodec struct {
+	enc cbor.EncMode
+	dec cbor.DecMode
+}
+
+// NewCBORCodec returns a [Codec] that encodes entries as CBOR maps (RFC 8949),
+// using the same field names as the JSON representation and tagged RFC 3339
+// timestamps.
+func NewCBORCodec() Codec {
+	enc, err := cbor.EncOptions{
+		Sort:    cbor.SortCanonical,
+		Time:    cbor.TimeRFC3339Nano,
+		TimeTag: cbor.EncTagRequired,
+	}.EncMode()
+	if err != nil {
+		panic(err) // options are static, this can only be a programming error.
+	}
+
+	dec, err := cbor.DecOptions{
+		DefaultMapType: reflect.TypeOf(map[string]interface{}(nil)),
+	}.DecMode()
+	if err != nil {
+		panic(err)
+	}
+
+	return cborCodec{
+		enc: enc,
+		dec: dec,
+	}
+}
+
+func (c cborCodec) Encode(entry *Entry) ([]byte, error) {
+	return c.enc.Marshal(entry.data)
+}
+
+func (c cborCodec) Decode(data []byte) (*Entry, error) {
+	entry := &Entry{data: &entryData{}}
+	if err := c.dec.Unmarshal(data, entry.data); err != nil {
+		return nil, err
+	}
+
+	return entry, nil
+}
+
+func (c cborCodec) ContentType() string {
+	return "application/cbor"
+}
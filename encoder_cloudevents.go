@@ -0,0 +1,315 @@
+package auditrail
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+const (
+	// cloudEventsSpecVersion is the version of the CloudEvents specification
+	// implemented by the CloudEvents codec.
+	cloudEventsSpecVersion = "1.0"
+
+	// cloudEventsHeaderPrefix prefixes the attribute headers of binary mode
+	// HTTP messages.
+	cloudEventsHeaderPrefix = "ce-"
+)
+
+// CloudEventsOption is a function that configures a CloudEvents codec.
+type CloudEventsOption func(options *CloudEventsCodec)
+
+// cloudEventsAttributes is the representation of an entry as CloudEvents
+// context attributes. Entry fields without a standard attribute are carried as
+// extension attributes.
+type cloudEventsAttributes struct {
+	SpecVersion     string `json:"specversion"`
+	ID              string `json:"id"`
+	Source          string `json:"source"`
+	Type            string `json:"type"`
+	Time            string `json:"time,omitempty"`
+	DataContentType string `json:"datacontenttype,omitempty"`
+	Actor           string `json:"actor,omitempty"`
+	CorrelationID   string `json:"correlationid,omitempty"`
+	CausationID     string `json:"causationid,omitempty"`
+	AuthMethod      string `json:"authmethod,omitempty"`
+}
+
+// cloudEvent is a CloudEvent in structured content mode.
+type cloudEvent struct {
+	cloudEventsAttributes
+	Data map[string]interface{} `json:"data,omitempty"`
+}
+
+var _ Codec = (*CloudEventsCodec)(nil)
+
+// CloudEventsCodec encodes entries as CloudEvents 1.0 events, mapping entry
+// fields to event attributes as follows:
+//
+//   - IdempotencyID to id.
+//   - Module to source.
+//   - Action to type.
+//   - OccurredAt to time.
+//   - Actor, CorrelationID, CausationID and AuthMethod to the actor,
+//     correlationid, causationid and authmethod extension attributes.
+//   - Details to the event data, as a JSON object.
+//
+// As a [Codec], events are encoded in structured content mode, so it can be
+// used with any logger that accepts an encoder, such as [NewKinesisLogger].
+// Use [CloudEventsCodec.EncodeBinary] and [CloudEventsCodec.DecodeBinary] for
+// the binary content mode of the HTTP protocol binding.
+type CloudEventsCodec struct {
+	sourcePrefix string
+	typePrefix   string
+}
+
+// NewCloudEventsCodec returns a new CloudEvents codec. See options for
+// configuration.
+func NewCloudEventsCodec(options ...CloudEventsOption) *CloudEventsCodec {
+	c := &CloudEventsCodec{}
+
+	for _, option := range options {
+		option(c)
+	}
+
+	return c
+}
+
+// Encode encodes the given entry as a CloudEvent in structured content mode.
+func (c *CloudEventsCodec) Encode(entry *Entry) ([]byte, error) {
+	data, err := c.data(entry)
+	if err != nil {
+		return nil, err
+	}
+
+	return json.Marshal(cloudEvent{
+		cloudEventsAttributes: c.attributes(entry, data != nil),
+		Data:                  data,
+	})
+}
+
+// Decode decodes a CloudEvent in structured content mode into an entry.
+func (c *CloudEventsCodec) Decode(payload []byte) (*Entry, error) {
+	event := cloudEvent{}
+	if err := json.Unmarshal(payload, &event); err != nil {
+		return nil, err
+	}
+
+	return c.entry(event.cloudEventsAttributes, event.Data)
+}
+
+// ContentType returns the media type of structured content mode events.
+func (c *CloudEventsCodec) ContentType() string {
+	return "application/cloudevents+json"
+}
+
+// EncodeBinary encodes the given entry as a CloudEvent in binary content mode
+// of the HTTP protocol binding, returning the headers carrying the event
+// attributes and the body carrying the event data.
+func (c *CloudEventsCodec) EncodeBinary(entry *Entry) (http.Header, []byte, error) {
+	data, err := c.data(entry)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	attrs := c.attributes(entry, true)
+	header := http.Header{}
+
+	for name, value := range attrs.headers() {
+		if value != "" {
+			header.Set(cloudEventsHeaderPrefix+name, percentEncode(value))
+		}
+	}
+
+	header.Set("Content-Type", attrs.DataContentType)
+
+	if data == nil {
+		return header, []byte("{}"), nil
+	}
+
+	body, err := json.Marshal(data)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return header, body, nil
+}
+
+// DecodeBinary decodes a CloudEvent in binary content mode of the HTTP
+// protocol binding into an entry.
+func (c *CloudEventsCodec) DecodeBinary(header http.Header, body []byte) (*Entry, error) {
+	attrs := cloudEventsAttributes{}
+
+	for name, field := range attrs.fields() {
+		value, err := url.PathUnescape(header.Get(cloudEventsHeaderPrefix + name))
+		if err != nil {
+			return nil, fmt.Errorf("%w: invalid %s attribute", err, name)
+		}
+
+		*field = value
+	}
+
+	var data map[string]interface{}
+
+	if len(body) > 0 {
+		if err := json.Unmarshal(body, &data); err != nil {
+			return nil, fmt.Errorf("%w: event data must be a JSON object", err)
+		}
+	}
+
+	return c.entry(attrs, data)
+}
+
+// attributes returns the event attributes of the given entry.
+func (c *CloudEventsCodec) attributes(entry *Entry, hasData bool) cloudEventsAttributes {
+	attrs := cloudEventsAttributes{
+		SpecVersion:   cloudEventsSpecVersion,
+		ID:            entry.GetIdempotencyID(),
+		Source:        c.sourcePrefix + entry.GetModule(),
+		Type:          c.typePrefix + entry.GetAction(),
+		Actor:         entry.GetActor(),
+		CorrelationID: entry.GetCorrelationID(),
+		CausationID:   entry.GetCausationID(),
+		AuthMethod:    entry.GetAuthMethod(),
+	}
+
+	if at := entry.GetOccurredAt(); !at.IsZero() {
+		attrs.Time = at.UTC().Format(time.RFC3339Nano)
+	}
+
+	if hasData {
+		attrs.DataContentType = "application/json"
+	}
+
+	return attrs
+}
+
+// data returns the event data of the given entry, going through the JSON
+// representation of its details.
+func (c *CloudEventsCodec) data(entry *Entry) (map[string]interface{}, error) {
+	details := entry.GetDetails()
+	if len(details) == 0 {
+		return nil, nil
+	}
+
+	raw, err := json.Marshal(details)
+	if err != nil {
+		return nil, err
+	}
+
+	data := make(map[string]interface{})
+	if err = json.Unmarshal(raw, &data); err != nil {
+		return nil, err
+	}
+
+	return data, nil
+}
+
+// entry builds an entry from the given event attributes and data.
+func (c *CloudEventsCodec) entry(attrs cloudEventsAttributes, data map[string]interface{}) (*Entry, error) {
+	if attrs.SpecVersion != cloudEventsSpecVersion {
+		return nil, fmt.Errorf("unsupported cloudevents specversion %q", attrs.SpecVersion)
+	}
+
+	if attrs.ID == "" || attrs.Source == "" || attrs.Type == "" {
+		return nil, fmt.Errorf("cloudevent is missing required attributes")
+	}
+
+	entry := NewEntry(
+		attrs.Actor,
+		strings.TrimPrefix(attrs.Type, c.typePrefix),
+		strings.TrimPrefix(attrs.Source, c.sourcePrefix),
+	).
+		WithIdempotency(attrs.ID).
+		WithCorrelation(attrs.CorrelationID).
+		WithCausation(attrs.CausationID).
+		WithAuthMethod(attrs.AuthMethod).
+		WithOccurredAt(time.Time{})
+
+	if attrs.Time != "" {
+		at, err := time.Parse(time.RFC3339Nano, attrs.Time)
+		if err != nil {
+			return nil, fmt.Errorf("%w: invalid time attribute", err)
+		}
+
+		entry.WithOccurredAt(at)
+	}
+
+	for k, v := range data {
+		entry.AppendDetails(k, v)
+	}
+
+	return entry, nil
+}
+
+// headers returns the attributes indexed by their name.
+func (a cloudEventsAttributes) headers() map[string]string {
+	out := make(map[string]string)
+
+	for name, field := range a.fields() {
+		out[name] = *field
+	}
+
+	return out
+}
+
+// fields returns pointers to the attributes indexed by their name.
+func (a *cloudEventsAttributes) fields() map[string]*string {
+	return map[string]*string{
+		"specversion":   &a.SpecVersion,
+		"id":            &a.ID,
+		"source":        &a.Source,
+		"type":          &a.Type,
+		"time":          &a.Time,
+		"actor":         &a.Actor,
+		"correlationid": &a.CorrelationID,
+		"causationid":   &a.CausationID,
+		"authmethod":    &a.AuthMethod,
+	}
+}
+
+// percentEncode encodes the given header value as required by the CloudEvents
+// HTTP protocol binding: space, double quote, percent and any character
+// outside the printable ASCII range are percent encoded.
+func percentEncode(s string) string {
+	const hex = "0123456789ABCDEF"
+
+	b := strings.Builder{}
+
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+
+		if c <= ' ' || c > '~' || c == '"' || c == '%' {
+			b.WriteByte('%')
+			b.WriteByte(hex[c>>4])
+			b.WriteByte(hex[c&0xf])
+
+			continue
+		}
+
+		b.WriteByte(c)
+	}
+
+	return b.String()
+}
+
+// WithCloudEventsSourcePrefix sets a prefix prepended to the module of the
+// entries to build the source attribute, e.g. "urn:example:audit:". The prefix
+// is stripped when decoding events.
+func WithCloudEventsSourcePrefix(prefix string) CloudEventsOption {
+	return func(options *CloudEventsCodec) {
+		options.sourcePrefix = prefix
+	}
+}
+
+// WithCloudEventsTypePrefix sets a prefix prepended to the action of the
+// entries to build the type attribute, e.g. "com.example.audit.". The prefix
+// is stripped when decoding events.
+func WithCloudEventsTypePrefix(prefix string) CloudEventsOption {
+	return func(options *CloudEventsCodec) {
+		options.typePrefix = prefix
+	}
+}
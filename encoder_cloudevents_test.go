@@ -0,0 +1,97 @@
+package auditrail_test
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/botchris/go-auditrail"
+	"github.com/brianvoe/gofakeit/v6"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCloudEventsCodec(t *testing.T) {
+	codec := auditrail.NewCloudEventsCodec(
+		auditrail.WithCloudEventsSourcePrefix("urn:example:"),
+		auditrail.WithCloudEventsTypePrefix("com.example.audit."),
+	)
+
+	entry := auditrail.NewEntry("john doe", "order_create", "orders").
+		WithCorrelation(gofakeit.UUID()).
+		WithCausation(gofakeit.UUID()).
+		WithOccurredAt(time.Date(2026, 10, 17, 10, 30, 0, 0, time.UTC)).
+		AppendDetails("amount", 125.5)
+
+	t.Run("GIVEN an entry WHEN encoding in structured mode THEN a spec compliant event is produced", func(t *testing.T) {
+		b, err := codec.Encode(entry)
+		require.NoError(t, err)
+
+		event := make(map[string]interface{})
+		require.NoError(t, json.Unmarshal(b, &event))
+
+		require.Equal(t, "1.0", event["specversion"])
+		require.Equal(t, entry.GetIdempotencyID(), event["id"])
+		require.Equal(t, "urn:example:orders", event["source"])
+		require.Equal(t, "com.example.audit.order_create", event["type"])
+		require.Equal(t, "2026-10-17T10:30:00Z", event["time"])
+		require.Equal(t, "john doe", event["actor"])
+		require.Equal(t, entry.GetCorrelationID(), event["correlationid"])
+		require.Equal(t, entry.GetCausationID(), event["causationid"])
+		require.Equal(t, map[string]interface{}{"amount": 125.5}, event["data"])
+
+		t.Run("AND decoding it THEN the entry is restored", func(t *testing.T) {
+			decoded, dErr := codec.Decode(b)
+			require.NoError(t, dErr)
+			requireSameEntry(t, entry, decoded)
+		})
+	})
+
+	t.Run("GIVEN an entry WHEN encoding in binary mode THEN attributes are sent as headers", func(t *testing.T) {
+		header, body, err := codec.EncodeBinary(entry)
+		require.NoError(t, err)
+
+		require.Equal(t, "1.0", header.Get("ce-specversion"))
+		require.Equal(t, entry.GetIdempotencyID(), header.Get("ce-id"))
+		require.Equal(t, "urn:example:orders", header.Get("ce-source"))
+		require.Equal(t, "john%20doe", header.Get("ce-actor"))
+		require.Equal(t, "application/json", header.Get("Content-Type"))
+		require.JSONEq(t, `{"amount":125.5}`, string(body))
+
+		t.Run("AND decoding it THEN the entry is restored", func(t *testing.T) {
+			decoded, dErr := codec.DecodeBinary(header, body)
+			require.NoError(t, dErr)
+			requireSameEntry(t, entry, decoded)
+		})
+	})
+
+	t.Run("GIVEN an event without required attributes WHEN decoding THEN it fails", func(t *testing.T) {
+		_, err := codec.Decode([]byte(`{"specversion":"1.0","source":"orders","type":"order_create"}`))
+		require.Error(t, err)
+	})
+
+	t.Run("GIVEN a kinesis logger with a cloudevents codec WHEN logging THEN records are structured events", func(t *testing.T) {
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+
+		api := &mockKinesisAPI{}
+		logger, err := auditrail.NewKinesisLogger(api, gofakeit.UUID(), auditrail.WithKinesisEncoder(codec))
+		require.NoError(t, err)
+		require.NoError(t, logger.Log(ctx, entry))
+		require.Len(t, api.putCalls, 1)
+
+		decoded, err := codec.Decode(api.putCalls[0].Data)
+		require.NoError(t, err)
+		requireSameEntry(t, entry, decoded)
+	})
+}
+
+func requireSameEntry(t *testing.T, expected, actual *auditrail.Entry) {
+	a, err := expected.CanonicalJSON()
+	require.NoError(t, err)
+
+	b, err := actual.CanonicalJSON()
+	require.NoError(t, err)
+
+	require.Equal(t, string(a), string(b))
+}
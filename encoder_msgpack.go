@@ -0,0 +1,43 @@
+package auditrail
+
+import (
+	"bytes"
+
+	"github.com/vmihailenco/msgpack/v5"
+)
+
+type msgpackCodec struct{}
+
+// NewMsgpackCodec returns a [Codec] that encodes entries as MessagePack maps,
+// using the same field names as the JSON representation.
+func NewMsgpackCodec() Codec {
+	return msgpackCodec{}
+}
+
+func (c msgpackCodec) Encode(entry *Entry) ([]byte, error) {
+	buf := &bytes.Buffer{}
+	enc := msgpack.NewEncoder(buf)
+	enc.SetCustomStructTag("json")
+
+	if err := enc.Encode(entry.data); err != nil {
+		return nil, err
+	}
+
+	return buf.Bytes(), nil
+}
+
+func (c msgpackCodec) Decode(data []byte) (*Entry, error) {
+	dec := msgpack.NewDecoder(bytes.NewReader(data))
+	dec.SetCustomStructTag("json")
+
+	entry := &Entry{data: &entryData{}}
+	if err := dec.Decode(entry.data); err != nil {
+		return nil, err
+	}
+
+	return entry, nil
+}
+
+func (c msgpackCodec) ContentType() string {
+	return "application/msgpack"
+}
@@ -0,0 +1,183 @@
+package auditrail
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"google.golang.org/protobuf/encoding/protowire"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/structpb"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+// Field numbers of the auditrail.v1.Entry message, see
+// protos/auditrail/v1/entry.proto.
+const (
+	protoIdempotencyID protowire.Number = iota + 1
+	protoActor
+	protoAction
+	protoModule
+	protoCorrelationID
+	protoCausationID
+	protoAuthMethod
+	protoDetails
+	protoOccurredAt
+)
+
+type protobufCodec struct{}
+
+// NewProtobufCodec returns a [Codec] that encodes entries as Protocol Buffers
+// messages of type auditrail.v1.Entry, whose definition is published in
+// protos/auditrail/v1/entry.proto so consumers can generate their own
+// bindings.
+//
+// Details are encoded as a google.protobuf.Struct, so they are subject to the
+// same restrictions as JSON values.
+func NewProtobufCodec() Codec {
+	return protobufCodec{}
+}
+
+func (c protobufCodec) Encode(entry *Entry) ([]byte, error) {
+	d := entry.data
+	b := make([]byte, 0, 256)
+
+	for _, f := range []struct {
+		num   protowire.Number
+		value string
+	}{
+		{protoIdempotencyID, d.IdempotencyID},
+		{protoActor, d.Actor},
+		{protoAction, d.Action},
+		{protoModule, d.Module},
+		{protoCorrelationID, d.CorrelationID},
+		{protoCausationID, d.CausationID},
+		{protoAuthMethod, d.AuthMethod},
+	} {
+		if f.value != "" {
+			b = protowire.AppendTag(b, f.num, protowire.BytesType)
+			b = protowire.AppendString(b, f.value)
+		}
+	}
+
+	opts := proto.MarshalOptions{Deterministic: true}
+
+	if len(d.Details) > 0 {
+		details, err := detailsStruct(d.Details)
+		if err != nil {
+			return nil, err
+		}
+
+		raw, err := opts.Marshal(details)
+		if err != nil {
+			return nil, err
+		}
+
+		b = protowire.AppendTag(b, protoDetails, protowire.BytesType)
+		b = protowire.AppendBytes(b, raw)
+	}
+
+	raw, err := opts.Marshal(timestamppb.New(d.OccurredAt))
+	if err != nil {
+		return nil, err
+	}
+
+	b = protowire.AppendTag(b, protoOccurredAt, protowire.BytesType)
+	b = protowire.AppendBytes(b, raw)
+
+	return b, nil
+}
+
+func (c protobufCodec) Decode(data []byte) (*Entry, error) {
+	d := &entryData{}
+
+	for len(data) > 0 {
+		num, typ, n := protowire.ConsumeTag(data)
+		if n < 0 {
+			return nil, protowire.ParseError(n)
+		}
+
+		data = data[n:]
+
+		if typ != protowire.BytesType || num > protoOccurredAt {
+			// unknown fields are skipped for forward compatibility.
+			n = protowire.ConsumeFieldValue(num, typ, data)
+			if n < 0 {
+				return nil, protowire.ParseError(n)
+			}
+
+			data = data[n:]
+
+			continue
+		}
+
+		value, n := protowire.ConsumeBytes(data)
+		if n < 0 {
+			return nil, protowire.ParseError(n)
+		}
+
+		data = data[n:]
+
+		if err := d.setProtoField(num, value); err != nil {
+			return nil, err
+		}
+	}
+
+	return &Entry{data: d}, nil
+}
+
+func (c protobufCodec) ContentType() string {
+	return "application/x-protobuf"
+}
+
+// setProtoField sets the field with the given number from its encoded value.
+func (d *entryData) setProtoField(num protowire.Number, value []byte) error {
+	switch num {
+	case protoIdempotencyID:
+		d.IdempotencyID = string(value)
+	case protoActor:
+		d.Actor = string(value)
+	case protoAction:
+		d.Action = string(value)
+	case protoModule:
+		d.Module = string(value)
+	case protoCorrelationID:
+		d.CorrelationID = string(value)
+	case protoCausationID:
+		d.CausationID = string(value)
+	case protoAuthMethod:
+		d.AuthMethod = string(value)
+	case protoDetails:
+		details := &structpb.Struct{}
+		if err := proto.Unmarshal(value, details); err != nil {
+			return fmt.Errorf("%w: invalid details", err)
+		}
+
+		d.Details = details.AsMap()
+	case protoOccurredAt:
+		ts := &timestamppb.Timestamp{}
+		if err := proto.Unmarshal(value, ts); err != nil {
+			return fmt.Errorf("%w: invalid occurred_at", err)
+		}
+
+		d.OccurredAt = ts.AsTime()
+	}
+
+	return nil
+}
+
+// detailsStruct converts the given details into a protobuf struct, going
+// through their JSON representation so that any JSON serializable value is
+// supported.
+func detailsStruct(details map[string]interface{}) (*structpb.Struct, error) {
+	raw, err := json.Marshal(details)
+	if err != nil {
+		return nil, err
+	}
+
+	generic := make(map[string]interface{})
+	if err = json.Unmarshal(raw, &generic); err != nil {
+		return nil, err
+	}
+
+	return structpb.NewStruct(generic)
+}
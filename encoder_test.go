@@ -0,0 +1,86 @@
+package auditrail_test
+
+import (
+	"context"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/botchris/go-auditrail"
+	"github.com/botchris/go-auditrail/httpd"
+	"github.com/brianvoe/gofakeit/v6"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCodecs(t *testing.T) {
+	codecs := map[string]auditrail.Codec{
+		"json":           auditrail.NewJSONCodec(),
+		"canonical json": auditrail.NewCanonicalJSONCodec(),
+		"msgpack":        auditrail.NewMsgpackCodec(),
+		"cbor":           auditrail.NewCBORCodec(),
+		"protobuf":       auditrail.NewProtobufCodec(),
+	}
+
+	for name, codec := range codecs {
+		t.Run("GIVEN a "+name+" codec WHEN encoding and decoding an entry THEN the entry is preserved", func(t *testing.T) {
+			entry := auditrail.NewEntry(gofakeit.Username(), "order_create", "orders").
+				WithCorrelation(gofakeit.UUID()).
+				WithCausation(gofakeit.UUID()).
+				WithAuthMethod("password").
+				AppendDetails("amount", 125).
+				AppendDetails("ratio", 0.25).
+				AppendDetails("tags", []string{"a", "b"}).
+				AppendDetails("http", httpd.Details{
+					Method:     "POST",
+					StatusCode: "201",
+					URL:        httpd.URL{Host: "api.example.com", Path: "/orders"},
+				})
+
+			b, err := codec.Encode(entry)
+			require.NoError(t, err)
+			require.NotEmpty(t, b)
+			require.NotEmpty(t, codec.ContentType())
+
+			decoded, err := codec.Decode(b)
+			require.NoError(t, err)
+
+			require.Equal(t, entry.GetIdempotencyID(), decoded.GetIdempotencyID())
+			require.Equal(t, entry.GetActor(), decoded.GetActor())
+			require.Equal(t, entry.GetCorrelationID(), decoded.GetCorrelationID())
+			require.Equal(t, entry.GetCausationID(), decoded.GetCausationID())
+			require.Equal(t, entry.GetAuthMethod(), decoded.GetAuthMethod())
+			require.True(t, entry.GetOccurredAt().Equal(decoded.GetOccurredAt()))
+
+			expected, err := entry.CanonicalJSON()
+			require.NoError(t, err)
+
+			actual, err := decoded.CanonicalJSON()
+			require.NoError(t, err)
+
+			require.Equal(t, string(expected), string(actual))
+		})
+	}
+
+	t.Run("GIVEN a kinesis logger with a protobuf codec WHEN logging entries THEN records decode symmetrically", func(t *testing.T) {
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+
+		api := &mockKinesisAPI{}
+		codec := auditrail.NewProtobufCodec()
+		logger, err := auditrail.NewKinesisLogger(api, gofakeit.UUID(), auditrail.WithKinesisEncoder(codec))
+		require.NoError(t, err)
+
+		entry := auditrail.NewEntry(gofakeit.Username(), gofakeit.VerbAction(), gofakeit.AppName())
+		require.NoError(t, logger.Log(ctx, entry))
+		require.Len(t, api.putCalls, 1)
+
+		decoded, err := codec.Decode(api.putCalls[0].Data)
+		require.NoError(t, err)
+		require.Equal(t, entry.GetIdempotencyID(), decoded.GetIdempotencyID())
+	})
+
+	t.Run("GIVEN a binary codec WHEN building a file logger THEN it is rejected", func(t *testing.T) {
+		_, err := auditrail.NewFileLogger(os.Stdout, auditrail.WithFileEncoder(auditrail.NewCBORCodec()))
+		require.Error(t, err)
+	})
+}
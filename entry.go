@@ -7,6 +7,11 @@ import (
 	"github.com/google/uuid"
 )
 
+// integrityDetails are the detail keys reserved for integrity metadata. They
+// are stripped from the canonical form of an entry so that integrity
+// decorators can be stacked in any order without invalidating each other.
+var integrityDetails = []string{chainDetailsKey, signatureDetailsKey}
+
 // Entry represents an audit log event.
 //
 // This struct is not safe for concurrent write access.
@@ -178,3 +183,29 @@ func (e *Entry) MarshalJSON() ([]byte, error) {
 func (e *Entry) UnmarshalJSON(data []byte) error {
 	return json.Unmarshal(data, &e.data)
 }
+
+// canonicalWith returns the canonical encoding of the entry, without any
+// integrity metadata, and with the given value attached as a detail under the
+// given key. If value is nil, no detail is attached.
+func (e *Entry) canonicalWith(key string, value interface{}) ([]byte, error) {
+	if e == nil || e.data == nil {
+		return nil, ErrEmptyEntry
+	}
+
+	data := *e.data
+	data.Details = make(map[string]interface{}, len(e.data.Details)+1)
+
+	for k, v := range e.data.Details {
+		data.Details[k] = v
+	}
+
+	for _, k := range integrityDetails {
+		delete(data.Details, k)
+	}
+
+	if value != nil {
+		data.Details[key] = value
+	}
+
+	return canonicalEntry(&data)
+}
@@ -0,0 +1,47 @@
+package auditrail
+
+import (
+	"crypto/md5"
+	"encoding/hex"
+)
+
+// KeyFunc returns the key of the record of an entry, which determines the
+// partition or shard it is written to by streaming loggers such as the Kafka
+// and Kinesis ones.
+type KeyFunc func(entry *Entry) string
+
+// KeyByModule keys records by module. It keeps the entries of a module
+// ordered, but a busy module turns its partition into a hot partition.
+func KeyByModule(entry *Entry) string {
+	return entry.GetModule()
+}
+
+// KeyByActor keys records by actor, falling back to the module for entries
+// without actor.
+func KeyByActor(entry *Entry) string {
+	if actor := entry.GetActor(); actor != "" {
+		return actor
+	}
+
+	return entry.GetModule()
+}
+
+// KeyByCorrelationID keys records by correlation ID, so entries of the same
+// flow are written to the same partition. Entries without correlation ID are
+// spread using their idempotency ID.
+func KeyByCorrelationID(entry *Entry) string {
+	if id := entry.GetCorrelationID(); id != "" {
+		return id
+	}
+
+	return KeyByHashedIdempotencyID(entry)
+}
+
+// KeyByHashedIdempotencyID spreads records evenly among partitions using the
+// MD5 digest of the idempotency ID of entries as key. Entries are not ordered
+// across records.
+func KeyByHashedIdempotencyID(entry *Entry) string {
+	sum := md5.Sum([]byte(entry.GetIdempotencyID()))
+
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,470 @@
+package auditrail
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"sync"
+)
+
+// chainDetailsKey is the detail key under which chain links are attached.
+const chainDetailsKey = "chain"
+
+var (
+	// ErrChainGap is reported when one or more entries are missing from a
+	// hash chain.
+	ErrChainGap = errors.New("hash chain gap")
+
+	// ErrChainReordered is reported when entries of a hash chain are not in
+	// sequence order.
+	ErrChainReordered = errors.New("hash chain reordered")
+
+	// ErrChainTampered is reported when an entry of a hash chain was modified,
+	// replaced or inserted.
+	ErrChainTampered = errors.New("hash chain tampered")
+)
+
+// HashChainOption is a function that configures a hash chain.
+type HashChainOption func(options *hashChainOptions)
+
+// ChainLink is the chaining information attached to every entry that passes
+// through a hash chain. It is stored under the "chain" detail of the entry.
+type ChainLink struct {
+	// Sequence is the position of the entry in the chain, starting at 1.
+	Sequence uint64 `json:"sequence"`
+
+	// PrevHash is the hash of the previous entry in the chain, empty for the
+	// first entry.
+	PrevHash string `json:"prev_hash"`
+
+	// Hash is the SHA-256 hash of the canonical encoding of the entry,
+	// including its sequence number and previous hash.
+	Hash string `json:"hash,omitempty"`
+}
+
+// ChainHead describes the last entry appended to a hash chain.
+type ChainHead struct {
+	Sequence uint64 `json:"sequence"`
+	Hash     string `json:"hash"`
+}
+
+// ChainStore persists the head of a hash chain so that a chain can be
+// continued after a restart.
+//
+// All methods should be goroutine safe.
+type ChainStore interface {
+	// Load returns the last persisted head, or a zero head if the chain has
+	// not been started yet.
+	Load() (ChainHead, error)
+
+	// Save persists the given head.
+	Save(ChainHead) error
+}
+
+// ChainError describes a violation found while verifying a hash chain.
+type ChainError struct {
+	// Line is the line number (starting at 1) where the violation was found.
+	Line int
+
+	// Sequence is the sequence number recorded in the offending line.
+	Sequence uint64
+
+	// Err is one of ErrChainGap, ErrChainReordered or ErrChainTampered.
+	Err error
+}
+
+func (e *ChainError) Error() string {
+	return fmt.Sprintf("%s: line %d, sequence %d", e.Err, e.Line, e.Sequence)
+}
+
+func (e *ChainError) Unwrap() error {
+	return e.Err
+}
+
+type hashChainOptions struct {
+	store        ChainStore
+	errorHandler func(error)
+}
+
+type hashChain struct {
+	dst          Logger
+	store        ChainStore
+	errorHandler func(error)
+	head         ChainHead
+	mu           sync.Mutex
+}
+
+// NewHashChain builds a new logger that makes the trail written to the given
+// logger tamper-evident.
+//
+// Every entry is stamped with a [ChainLink] holding a sequence number, the
+// hash of the previous entry and its own hash over a canonical encoding of the
+// entry. After each successful write the chain head is saved to the configured
+// [ChainStore], so a new hash chain built on the same store continues where
+// the previous one stopped. As the entry is already written by then, failures
+// to save the head are reported to the error handler instead of the caller,
+// which could otherwise log the entry again. Use [VerifyChain] to check a
+// written trail.
+//
+// Entries are chained in the order they reach this logger, so it should sit
+// beneath any queue (or the queue should use a single worker) to keep the
+// written order identical to the chain order.
+func NewHashChain(dst Logger, options ...HashChainOption) (Logger, error) {
+	opts := hashChainOptions{
+		store:        NewMemoryChainStore(),
+		errorHandler: func(error) {},
+	}
+
+	for _, option := range options {
+		option(&opts)
+	}
+
+	head, err := opts.store.Load()
+	if err != nil {
+		return nil, fmt.Errorf("%w: could not load chain head", err)
+	}
+
+	return &hashChain{
+		dst:          dst,
+		store:        opts.store,
+		errorHandler: opts.errorHandler,
+		head:         head,
+	}, nil
+}
+
+// Log stamps the given entry with the next chain link and writes it to the
+// underlying logger. The chain only advances if the write succeeds.
+func (c *hashChain) Log(ctx context.Context, entry *Entry) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if entry == nil {
+		return c.dst.Log(ctx, entry)
+	}
+
+	link := ChainLink{
+		Sequence: c.head.Sequence + 1,
+		PrevHash: c.head.Hash,
+	}
+
+	hash, err := entry.chainHash(link)
+	if err != nil {
+		return fmt.Errorf("%w: could not hash entry", err)
+	}
+
+	link.Hash = hash
+	entry.AppendDetails(chainDetailsKey, link)
+
+	if err = c.dst.Log(ctx, entry); err != nil {
+		return err
+	}
+
+	// the entry has been written, so the chain must advance even if the head
+	// could not be persisted.
+	c.head = ChainHead{Sequence: link.Sequence, Hash: link.Hash}
+
+	if err = c.store.Save(c.head); err != nil {
+		c.errorHandler(fmt.Errorf("%w: could not persist chain head %d", err, c.head.Sequence))
+	}
+
+	return nil
+}
+
+func (c *hashChain) Close() error {
+	return c.dst.Close()
+}
+
+// Shutdown shuts down the destination logger within ctx.
+func (c *hashChain) Shutdown(ctx context.Context) (ShutdownReport, error) {
+	return Shutdown(ctx, c.dst)
+}
+
+func (c *hashChain) Closed() <-chan struct{} {
+	return c.dst.Closed()
+}
+
+func (c *hashChain) IsClosed() bool {
+	return c.dst.IsClosed()
+}
+
+// WithHashChainStore sets the store used to persist the chain head. If store
+// is nil, an in-memory store is used and the chain restarts on every run.
+func WithHashChainStore(store ChainStore) HashChainOption {
+	return func(opts *hashChainOptions) {
+		if store == nil {
+			store = NewMemoryChainStore()
+		}
+
+		opts.store = store
+	}
+}
+
+// WithHashChainErrorHandler sets a function called when the chain head could
+// not be saved to the store after an entry was written. The chain keeps
+// advancing in memory, so the next successful save catches up.
+func WithHashChainErrorHandler(handler func(error)) HashChainOption {
+	return func(opts *hashChainOptions) {
+		if handler == nil {
+			handler = func(error) {}
+		}
+
+		opts.errorHandler = handler
+	}
+}
+
+// VerifyChain reads a trail of JSON entries separated by newlines, as written
+// by [NewFileLogger], and checks that it forms an unbroken hash chain starting
+// at the first sequence number.
+//
+// It returns the head of the verified chain; comparing it with the head saved
+// in the [ChainStore] also detects entries removed from the end of the trail.
+// If the chain is not intact, the returned error joins one [ChainError] per
+// violation, which can be inspected with errors.Is against [ErrChainGap],
+// [ErrChainReordered] and [ErrChainTampered].
+func VerifyChain(r io.Reader) (ChainHead, error) {
+	links, errs, err := readChain(r)
+	if err != nil {
+		return ChainHead{}, err
+	}
+
+	present := make(map[uint64]struct{}, len(links))
+	for _, l := range links {
+		present[l.Sequence] = struct{}{}
+	}
+
+	prev := lineLink{}
+
+	for _, l := range links {
+		switch expected := prev.Sequence + 1; {
+		case l.Sequence == expected && l.PrevHash != prev.Hash:
+			errs = append(errs, &ChainError{Line: l.line, Sequence: l.Sequence, Err: ErrChainTampered})
+		case l.Sequence < expected:
+			errs = append(errs, &ChainError{Line: l.line, Sequence: l.Sequence, Err: ErrChainReordered})
+		case l.Sequence > expected:
+			cause := ErrChainGap
+
+			// missing entries found elsewhere in the trail were moved, not
+			// removed.
+			if containsWithin(present, expected, l.Sequence) {
+				cause = ErrChainReordered
+			}
+
+			errs = append(errs, &ChainError{Line: l.line, Sequence: l.Sequence, Err: cause})
+		}
+
+		prev = l
+	}
+
+	return ChainHead{Sequence: prev.Sequence, Hash: prev.Hash}, errors.Join(errs...)
+}
+
+// containsWithin reports whether the given set holds any sequence within the
+// range [lo, hi).
+func containsWithin(set map[uint64]struct{}, lo, hi uint64) bool {
+	if hi-lo > uint64(len(set)) {
+		for s := range set {
+			if s >= lo && s < hi {
+				return true
+			}
+		}
+
+		return false
+	}
+
+	for s := lo; s < hi; s++ {
+		if _, ok := set[s]; ok {
+			return true
+		}
+	}
+
+	return false
+}
+
+// lineLink is a chain link read from a given line of a trail.
+type lineLink struct {
+	ChainLink
+	line int
+}
+
+// readChain reads the chain links of every entry in the given trail, along
+// with the violations found in individual entries.
+func readChain(r io.Reader) ([]lineLink, []error, error) {
+	reader := bufio.NewReader(r)
+	links := make([]lineLink, 0)
+	errs := make([]error, 0)
+
+	for n := 1; ; n++ {
+		line, err := reader.ReadBytes('\n')
+		if err != nil && !errors.Is(err, io.EOF) {
+			return nil, nil, fmt.Errorf("%w: could not read trail", err)
+		}
+
+		if line = bytes.TrimSpace(line); len(line) > 0 {
+			link, ok, parsed := parseChainLine(line)
+			if !ok {
+				errs = append(errs, &ChainError{Line: n, Sequence: link.Sequence, Err: ErrChainTampered})
+			}
+
+			// lines without a link cannot be placed in the chain, so they
+			// are skipped instead of breaking the order of the next links.
+			if parsed {
+				links = append(links, lineLink{ChainLink: link, line: n})
+			}
+		}
+
+		if errors.Is(err, io.EOF) {
+			return links, errs, nil
+		}
+	}
+}
+
+// parseChainLine extracts the chain link of the given encoded entry, and
+// reports whether the link matches the content of the entry and whether the
+// link could be parsed at all.
+func parseChainLine(line []byte) (ChainLink, bool, bool) {
+	entry := &Entry{}
+	if err := json.Unmarshal(line, entry); err != nil || entry.data == nil {
+		return ChainLink{}, false, false
+	}
+
+	raw, ok := entry.data.Details[chainDetailsKey]
+	if !ok {
+		return ChainLink{}, false, false
+	}
+
+	link := ChainLink{}
+
+	encoded, err := json.Marshal(raw)
+	if err != nil {
+		return ChainLink{}, false, false
+	}
+
+	if err = json.Unmarshal(encoded, &link); err != nil {
+		return ChainLink{}, false, false
+	}
+
+	hash, err := entry.chainHash(ChainLink{Sequence: link.Sequence, PrevHash: link.PrevHash})
+	if err != nil {
+		return link, false, true
+	}
+
+	return link, hash == link.Hash, true
+}
+
+// chainHash computes the hash of the entry when attached to the given link.
+func (e *Entry) chainHash(link ChainLink) (string, error) {
+	link.Hash = ""
+
+	canonical, err := e.canonicalWith(chainDetailsKey, link)
+	if err != nil {
+		return "", err
+	}
+
+	sum := sha256.Sum256(canonical)
+
+	return hex.EncodeToString(sum[:]), nil
+}
+
+type memoryChainStore struct {
+	head ChainHead
+	mu   sync.RWMutex
+}
+
+// NewMemoryChainStore returns a [ChainStore] that keeps the chain head in
+// memory. Useful for testing.
+func NewMemoryChainStore() ChainStore {
+	return &memoryChainStore{}
+}
+
+func (s *memoryChainStore) Load() (ChainHead, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	return s.head, nil
+}
+
+func (s *memoryChainStore) Save(head ChainHead) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.head = head
+
+	return nil
+}
+
+type fileChainStore struct {
+	path string
+	mu   sync.Mutex
+}
+
+// NewFileChainStore returns a [ChainStore] that persists the chain head as a
+// JSON document in the file at the given path.
+//
+// The file is replaced atomically on every save. If the file does not exist,
+// the chain starts from scratch.
+func NewFileChainStore(path string) ChainStore {
+	return &fileChainStore{path: path}
+}
+
+func (s *fileChainStore) Load() (ChainHead, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	b, err := os.ReadFile(s.path)
+	if os.IsNotExist(err) {
+		return ChainHead{}, nil
+	}
+
+	if err != nil {
+		return ChainHead{}, err
+	}
+
+	head := ChainHead{}
+	if err = json.Unmarshal(b, &head); err != nil {
+		return ChainHead{}, fmt.Errorf("%w: invalid chain head file", err)
+	}
+
+	return head, nil
+}
+
+func (s *fileChainStore) Save(head ChainHead) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	b, err := json.Marshal(head)
+	if err != nil {
+		return err
+	}
+
+	tmp := s.path + ".tmp"
+
+	fd, err := os.OpenFile(tmp, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, 0644)
+	if err != nil {
+		return err
+	}
+
+	if _, err = fd.Write(b); err != nil {
+		fd.Close()
+
+		return err
+	}
+
+	if err = fd.Sync(); err != nil {
+		fd.Close()
+
+		return err
+	}
+
+	if err = fd.Close(); err != nil {
+		return err
+	}
+
+	return os.Rename(tmp, s.path)
+}
@@ -0,0 +1,176 @@
+package auditrail_test
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/botchris/go-auditrail"
+	"github.com/botchris/go-auditrail/httpd"
+	"github.com/brianvoe/gofakeit/v6"
+	"github.com/stretchr/testify/require"
+)
+
+func TestHashChain(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	t.Run("GIVEN a hash chained file logger with a persistent chain store", func(t *testing.T) {
+		dir := t.TempDir()
+		path := dir + "/audit.log"
+		store := auditrail.NewFileChainStore(dir + "/audit.head")
+
+		logChained(t, ctx, path, store, 5)
+
+		t.Run("WHEN restarting the logger and logging more entries", func(t *testing.T) {
+			logChained(t, ctx, path, store, 5)
+
+			t.Run("THEN the whole trail forms a valid chain", func(t *testing.T) {
+				fd, err := os.Open(path)
+				require.NoError(t, err)
+
+				defer fd.Close()
+
+				head, vErr := auditrail.VerifyChain(fd)
+				require.NoError(t, vErr)
+
+				saved, lErr := store.Load()
+				require.NoError(t, lErr)
+				require.EqualValues(t, 10, head.Sequence)
+				require.Equal(t, saved, head)
+			})
+		})
+
+		t.Run("WHEN an entry is modified THEN verification reports tampering", func(t *testing.T) {
+			lines := readLines(t, path)
+			lines[3] = strings.Replace(lines[3], "order_create", "order_delete", 1)
+
+			_, err := auditrail.VerifyChain(strings.NewReader(strings.Join(lines, "\n")))
+			require.ErrorIs(t, err, auditrail.ErrChainTampered)
+			require.NotErrorIs(t, err, auditrail.ErrChainGap)
+
+			var chainErr *auditrail.ChainError
+
+			require.True(t, errors.As(err, &chainErr))
+			require.Equal(t, 4, chainErr.Line)
+		})
+
+		t.Run("WHEN a line is garbled THEN only that line is reported", func(t *testing.T) {
+			lines := readLines(t, path)
+			lines[3] = "{not an entry"
+
+			_, err := auditrail.VerifyChain(strings.NewReader(strings.Join(lines, "\n")))
+			require.ErrorIs(t, err, auditrail.ErrChainTampered)
+			require.NotErrorIs(t, err, auditrail.ErrChainReordered)
+
+			joined, ok := err.(interface{ Unwrap() []error })
+			require.True(t, ok)
+
+			// the garbled line, and the gap it leaves before the next link.
+			require.Len(t, joined.Unwrap(), 2)
+		})
+
+		t.Run("WHEN an entry is removed THEN verification reports a gap", func(t *testing.T) {
+			lines := readLines(t, path)
+			lines = append(lines[:4], lines[5:]...)
+
+			_, err := auditrail.VerifyChain(strings.NewReader(strings.Join(lines, "\n")))
+			require.ErrorIs(t, err, auditrail.ErrChainGap)
+			require.NotErrorIs(t, err, auditrail.ErrChainTampered)
+		})
+
+		t.Run("WHEN two entries are swapped THEN verification reports a reordering", func(t *testing.T) {
+			lines := readLines(t, path)
+			lines[2], lines[6] = lines[6], lines[2]
+
+			_, err := auditrail.VerifyChain(strings.NewReader(strings.Join(lines, "\n")))
+			require.ErrorIs(t, err, auditrail.ErrChainReordered)
+			require.NotErrorIs(t, err, auditrail.ErrChainGap)
+		})
+	})
+
+	t.Run("GIVEN a hash chained memory logger WHEN logging entries THEN each entry links to the previous one", func(t *testing.T) {
+		dst := auditrail.NewMemoryLogger()
+		logger, err := auditrail.NewHashChain(dst)
+		require.NoError(t, err)
+
+		first := auditrail.NewEntry(gofakeit.Username(), gofakeit.VerbAction(), gofakeit.AppName())
+		second := auditrail.NewEntry(gofakeit.Username(), gofakeit.VerbAction(), gofakeit.AppName())
+
+		require.NoError(t, logger.Log(ctx, first))
+		require.NoError(t, logger.Log(ctx, second))
+
+		l1, ok := first.GetDetails()["chain"].(auditrail.ChainLink)
+		require.True(t, ok)
+
+		l2, ok := second.GetDetails()["chain"].(auditrail.ChainLink)
+		require.True(t, ok)
+
+		require.EqualValues(t, 1, l1.Sequence)
+		require.Empty(t, l1.PrevHash)
+		require.EqualValues(t, 2, l2.Sequence)
+		require.Equal(t, l1.Hash, l2.PrevHash)
+		require.NotEqual(t, l1.Hash, l2.Hash)
+	})
+
+	t.Run("GIVEN a chain store failing to save WHEN logging an entry THEN the failure is reported out of band", func(t *testing.T) {
+		dst := auditrail.NewMemoryLogger()
+
+		var reported []error
+
+		logger, err := auditrail.NewHashChain(dst,
+			auditrail.WithHashChainStore(&failingChainStore{ChainStore: auditrail.NewMemoryChainStore(), err: errors.New("disk full")}),
+			auditrail.WithHashChainErrorHandler(func(err error) { reported = append(reported, err) }),
+		)
+		require.NoError(t, err)
+
+		entry := newFakeEntry()
+		require.NoError(t, logger.Log(ctx, entry), "written entries must not be logged again")
+		require.True(t, dst.Has(entry.GetIdempotencyID()))
+		require.Len(t, reported, 1)
+		require.ErrorContains(t, reported[0], "disk full")
+	})
+}
+
+func logChained(t *testing.T, ctx context.Context, path string, store auditrail.ChainStore, n int) {
+	fl, err := auditrail.NewFilePathLogger(path)
+	require.NoError(t, err)
+
+	logger, err := auditrail.NewHashChain(fl, auditrail.WithHashChainStore(store))
+	require.NoError(t, err)
+
+	for i := 0; i < n; i++ {
+		entry := auditrail.NewEntry(gofakeit.UUID(), "order_create", "ordering_service").
+			AppendDetails("http", httpd.Details{
+				Method:     "POST",
+				StatusCode: "201",
+				URL:        httpd.URL{Host: "api.example.com", Path: "/orders"},
+			}).
+			AppendDetails("amount", gofakeit.Price(1, 1000))
+
+		require.NoError(t, logger.Log(ctx, entry))
+	}
+
+	require.NoError(t, logger.Close())
+}
+
+func readLines(t *testing.T, path string) []string {
+	b, err := os.ReadFile(path)
+	require.NoError(t, err)
+
+	return strings.Split(string(bytes.TrimSpace(b)), "\n")
+}
+
+// failingChainStore is a chain store whose saves fail with the given error.
+type failingChainStore struct {
+	auditrail.ChainStore
+	err error
+}
+
+func (s *failingChainStore) Save(auditrail.ChainHead) error {
+	return s.err
+}
@@ -8,6 +8,15 @@ import (
 // ErrTrailClosed is returned when the queue is closed.
 var ErrTrailClosed = fmt.Errorf("trail is closed")
 
+// ErrRetryable is wrapped by errors caused by transient conditions, such as
+// network failures, that are expected to succeed when retried.
+var ErrRetryable = fmt.Errorf("retryable error")
+
+// ErrPermanent is wrapped by errors that will fail again when retried, such as
+// a document rejected by the destination. The retryer drops entries failing
+// with such errors instead of retrying them.
+var ErrPermanent = fmt.Errorf("permanent error")
+
 // Logger trail logger to which audit logs are written.
 type Logger interface {
 	// Log writes the given log entry to the audit log, returning an error
@@ -23,3 +32,83 @@ type Logger interface {
 	// IsClosed returns true if the logger is closed.
 	IsClosed() bool
 }
+
+// BatchLogger is implemented by loggers able to write many entries with a
+// single call to their destination, such as a bulk or batch API.
+type BatchLogger interface {
+	Logger
+
+	// LogBatch writes the given entries to the audit log. The returned slice
+	// is nil when every entry was written, otherwise it has the same length
+	// as entries and holds the error of each entry that could not be written,
+	// or nil for the ones that were.
+	LogBatch(context.Context, []*Entry) []error
+}
+
+// ShutdownLogger is implemented by loggers able to close within a deadline,
+// such as loggers buffering entries for asynchronous processing.
+type ShutdownLogger interface {
+	Logger
+
+	// Shutdown closes the logger like Close, writing as many buffered entries
+	// as possible until ctx is done. Entries that could not be written by
+	// then are abandoned and passed to the drop handler of the logger, along
+	// with an error wrapping [ErrTrailClosed]. The returned error wraps the
+	// error of ctx when the deadline was reached.
+	Shutdown(context.Context) (ShutdownReport, error)
+}
+
+// ShutdownReport reports the entries handled while shutting down a logger and
+// the loggers it writes to.
+type ShutdownReport struct {
+	// Flushed is the number of buffered entries written while shutting down.
+	// Entries buffered by many loggers of a chain are counted by each of them.
+	Flushed int
+
+	// Abandoned is the number of buffered entries that could not be written
+	// before the deadline.
+	Abandoned int
+}
+
+// add returns the sum of both reports.
+func (r ShutdownReport) add(other ShutdownReport) ShutdownReport {
+	return ShutdownReport{
+		Flushed:   r.Flushed + other.Flushed,
+		Abandoned: r.Abandoned + other.Abandoned,
+	}
+}
+
+// Shutdown shuts down the given logger within ctx. Loggers implementing
+// [ShutdownLogger] are shut down with their Shutdown method; any other logger
+// is closed with Close, which keeps running in the background if ctx is done
+// first, in which case its buffered entries cannot be reported.
+func Shutdown(ctx context.Context, logger Logger) (ShutdownReport, error) {
+	if sl, ok := logger.(ShutdownLogger); ok {
+		return sl.Shutdown(ctx)
+	}
+
+	if ctx.Done() == nil {
+		return ShutdownReport{}, logger.Close()
+	}
+
+	closed := make(chan error, 1)
+
+	go func() { closed <- logger.Close() }()
+
+	select {
+	case err := <-closed:
+		return ShutdownReport{}, err
+	case <-ctx.Done():
+		return ShutdownReport{}, fmt.Errorf("%w: logger could not close before the deadline", ctx.Err())
+	}
+}
+
+// abandonedError returns the error passed to drop handlers for the entries
+// abandoned while shutting down a logger, because of the given cause.
+func abandonedError(cause error) error {
+	if cause == nil {
+		return fmt.Errorf("%w: entry abandoned at shutdown", ErrTrailClosed)
+	}
+
+	return fmt.Errorf("%w: %w: entry abandoned at shutdown", ErrTrailClosed, cause)
+}
@@ -0,0 +1,135 @@
+package auditrail
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// defaultAWSBatchBackoff is the backoff between retries of the items reported
+// as failed by AWS batch requests.
+var defaultAWSBatchBackoff = ExponentialBackoffConfig{
+	Base:   100 * time.Millisecond,
+	Factor: 100 * time.Millisecond,
+	Max:    2 * time.Second,
+}
+
+// awsBatchItem is an item of an AWS batch request, such as a Kinesis record or
+// a SQS message, carrying one or many entries of a batch.
+type awsBatchItem interface {
+	// size returns the size the item counts against the request limits.
+	size() int
+
+	// batchEntries returns the indexes of the entries carried by the item.
+	batchEntries() []int
+}
+
+// awsBatchLimits are the limits of an AWS batch request.
+type awsBatchLimits struct {
+	items int
+	bytes int
+}
+
+// awsBatchRequest sends the given items in a single batch request, returning
+// the error of every item, nil if it was written, or an error if the request
+// failed as a whole.
+type awsBatchRequest[T awsBatchItem] func(ctx context.Context, items []T) ([]error, error)
+
+// awsBatchSender sends items using an AWS batch API, splitting them into
+// requests within limits and retrying the items reported as failed unless
+// their failure wraps [ErrPermanent].
+type awsBatchSender[T awsBatchItem] struct {
+	limits   awsBatchLimits
+	attempts int
+	backoff  ExponentialBackoffConfig
+	send     awsBatchRequest[T]
+}
+
+// sendAll sends the given items, setting in errs, a batch of n entries, the
+// error of every entry of the items that could not be written.
+func (s awsBatchSender[T]) sendAll(ctx context.Context, items []T, errs []error, n int) []error {
+	for len(items) > 0 {
+		size := awsCallSize(items, s.limits)
+		errs = s.sendRequest(ctx, items[:size], errs, n)
+		items = items[size:]
+	}
+
+	return errs
+}
+
+// sendRequest sends the given items in a single batch request, retrying the
+// items reported as failed.
+func (s awsBatchSender[T]) sendRequest(ctx context.Context, items []T, errs []error, n int) []error {
+	backoff := &exponentialBackoffStrategy{config: s.backoff}
+
+	for attempt := 1; ; attempt++ {
+		results, err := s.send(ctx, items)
+		if err != nil {
+			for _, item := range items {
+				errs = setAWSBatchError(errs, n, item, err)
+			}
+
+			return errs
+		}
+
+		failed := make([]T, 0, len(items))
+		reasons := make([]error, 0, len(items))
+
+		for i, item := range items {
+			err = results[i]
+
+			switch {
+			case err == nil:
+			case attempt < s.attempts && !errors.Is(err, ErrPermanent):
+				failed = append(failed, item)
+				reasons = append(reasons, err)
+			default:
+				errs = setAWSBatchError(errs, n, item, err)
+			}
+		}
+
+		if len(failed) == 0 {
+			return errs
+		}
+
+		backoff.Failure(nil, nil)
+
+		select {
+		case <-ctx.Done():
+			for i, item := range failed {
+				errs = setAWSBatchError(errs, n, item, fmt.Errorf("%w: %w", reasons[i], ctx.Err()))
+			}
+
+			return errs
+		case <-time.After(backoff.Proceed(nil)):
+		}
+
+		items = failed
+	}
+}
+
+// setAWSBatchError sets the given error for every entry of the given item.
+func setAWSBatchError(errs []error, n int, item awsBatchItem, err error) []error {
+	for _, e := range item.batchEntries() {
+		errs = setBatchError(errs, n, e, err)
+	}
+
+	return errs
+}
+
+// awsCallSize returns how many of the given items fit in a single batch
+// request within the given limits. It is at least one.
+func awsCallSize[T awsBatchItem](items []T, limits awsBatchLimits) int {
+	size := 0
+
+	for i, item := range items {
+		size += item.size()
+
+		if i == limits.items || (i > 0 && size > limits.bytes) {
+			return i
+		}
+	}
+
+	return len(items)
+}
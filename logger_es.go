@@ -1,29 +1,90 @@
 package auditrail
 
 import (
+	"bytes"
 	"context"
 	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"regexp"
 	"strings"
 	"sync"
+	"time"
 
 	"github.com/elastic/go-elasticsearch"
+	"github.com/elastic/go-elasticsearch/esapi"
 )
 
+// ElasticError is an error reported by ElasticSearch for a request or for an
+// item of a bulk request.
+//
+// Errors with a 429 or 5xx status wrap [ErrRetryable], any other error wraps
+// [ErrPermanent].
+type ElasticError struct {
+	// Status is the HTTP status code of the failure.
+	Status int
+
+	// Type is the type of the failure, such as "mapper_parsing_exception".
+	Type string
+
+	// Reason is a human-readable description of the failure.
+	Reason string
+}
+
+func (e *ElasticError) Error() string {
+	if e.Type == "" {
+		return fmt.Sprintf("elasticsearch: %d %s", e.Status, http.StatusText(e.Status))
+	}
+
+	return fmt.Sprintf("elasticsearch: %d %s: %s", e.Status, e.Type, e.Reason)
+}
+
+func (e *ElasticError) Unwrap() error {
+	if e.Status == http.StatusTooManyRequests || e.Status >= http.StatusInternalServerError {
+		return ErrRetryable
+	}
+
+	return ErrPermanent
+}
+
+// ElasticLoggerOption is a function that configures an ElasticSearch logger.
+type ElasticLoggerOption func(options *elasticLogger)
+
 type elasticLogger struct {
-	index        string
+	index        func(*Entry) string
 	client       *elasticsearch.Client
+	encoder      Encoder
+	dataStream   bool
 	closed       bool
 	closeChannel chan struct{}
 	mu           sync.RWMutex
 }
 
 // NewElasticLogger creates a new ElasticSearch logger.
-func NewElasticLogger(index string, client *elasticsearch.Client) Logger {
-	return &elasticLogger{
-		index:        index,
+//
+// Entries are written to the given index, unless [WithElasticIndexPattern] or
+// [WithElasticIndexFunc] are used to pick an index for each entry. Use
+// [WithElasticDataStream] when writing to data streams, and [BootstrapElastic]
+// to install the index template they rely on.
+func NewElasticLogger(index string, client *elasticsearch.Client, options ...ElasticLoggerOption) Logger {
+	return newElasticLogger(index, client, options...)
+}
+
+func newElasticLogger(index string, client *elasticsearch.Client, options ...ElasticLoggerOption) *elasticLogger {
+	e := &elasticLogger{
+		index:        func(*Entry) string { return index },
 		client:       client,
+		encoder:      NewJSONCodec(),
 		closeChannel: make(chan struct{}),
 	}
+
+	for _, option := range options {
+		option(e)
+	}
+
+	return e
 }
 
 func (e *elasticLogger) Log(ctx context.Context, entry *Entry) error {
@@ -35,21 +96,69 @@ func (e *elasticLogger) Log(ctx context.Context, entry *Entry) error {
 		return ErrTrailClosed
 	}
 
-	body, err := json.Marshal(entry)
+	body, err := e.document(entry)
 	if err != nil {
 		return err
 	}
 
-	_, err = e.client.Index(
-		e.index,
-		strings.NewReader(string(body)),
+	opts := []func(*esapi.IndexRequest){
 		e.client.Index.WithContext(ctx),
 		e.client.Index.WithDocumentID(entry.GetIdempotencyID()),
-	)
+	}
+
+	if e.dataStream {
+		// data streams are append-only and only accept create operations.
+		opts = append(opts, e.client.Index.WithOpType("create"))
+	}
+
+	res, err := e.client.Index(e.index(entry), bytes.NewReader(body), opts...)
+	if err != nil {
+		return fmt.Errorf("%w: %w: could not index entry", ErrRetryable, err)
+	}
+
+	err = elasticResponseError(res)
+
+	var esErr *ElasticError
+	if e.dataStream && errors.As(err, &esErr) && esErr.Status == http.StatusConflict {
+		// the entry was already written.
+		return nil
+	}
 
 	return err
 }
 
+// document encodes the given entry as a single line JSON document. Documents
+// written to data streams get the @timestamp field they require.
+func (e *elasticLogger) document(entry *Entry) ([]byte, error) {
+	doc, err := encodeDocument(e.encoder, entry)
+	if err != nil {
+		return nil, err
+	}
+
+	if !e.dataStream {
+		return doc, nil
+	}
+
+	if len(doc) < 2 || doc[0] != '{' {
+		return nil, fmt.Errorf("elastic encoder must produce JSON objects")
+	}
+
+	ts, err := json.Marshal(entry.GetOccurredAt().UTC().Format(time.RFC3339Nano))
+	if err != nil {
+		return nil, err
+	}
+
+	out := make([]byte, 0, len(doc)+len(ts)+16)
+	out = append(out, `{"@timestamp":`...)
+	out = append(out, ts...)
+
+	if len(doc) > 2 {
+		out = append(out, ',')
+	}
+
+	return append(out, doc[1:]...), nil
+}
+
 func (e *elasticLogger) Close() error {
 	e.mu.Lock()
 	defer e.mu.Unlock()
@@ -78,3 +187,119 @@ func (e *elasticLogger) IsClosed() bool {
 
 	return e.closed
 }
+
+// encodeDocument encodes the given entry as a single line JSON document.
+func encodeDocument(encoder Encoder, entry *Entry) ([]byte, error) {
+	doc, err := encoder.Encode(entry)
+	if err != nil {
+		return nil, err
+	}
+
+	compact := &bytes.Buffer{}
+	if err = json.Compact(compact, doc); err != nil {
+		return nil, err
+	}
+
+	return compact.Bytes(), nil
+}
+
+// elasticResponseError consumes the given response and returns an
+// [ElasticError] if it reports a failure.
+func elasticResponseError(res *esapi.Response) error {
+	defer res.Body.Close()
+
+	if !res.IsError() {
+		_, _ = io.Copy(io.Discard, res.Body)
+
+		return nil
+	}
+
+	var body struct {
+		Error json.RawMessage `json:"error"`
+	}
+
+	failure := elasticFailure{}
+
+	if err := json.NewDecoder(res.Body).Decode(&body); err == nil {
+		// the error is either an object or, on older versions, a string.
+		if json.Unmarshal(body.Error, &failure) != nil {
+			_ = json.Unmarshal(body.Error, &failure.Reason)
+		}
+	}
+
+	return &ElasticError{Status: res.StatusCode, Type: failure.Type, Reason: failure.Reason}
+}
+
+// elasticFailure is the error object of ElasticSearch responses.
+type elasticFailure struct {
+	Type   string `json:"type"`
+	Reason string `json:"reason"`
+}
+
+// WithElasticEncoder sets the encoder used to build the indexed documents. The
+// encoder must produce JSON documents. If encoder is nil, entries are encoded
+// using [Entry.MarshalJSON].
+func WithElasticEncoder(encoder Encoder) ElasticLoggerOption {
+	return func(options *elasticLogger) {
+		if encoder == nil {
+			encoder = NewJSONCodec()
+		}
+
+		options.encoder = encoder
+	}
+}
+
+// WithElasticIndexPattern writes each entry to the index resulting from
+// expanding the given pattern, for example "audit-{module}-{yyyy}.{MM}" writes
+// entries of the orders module that occurred in October 2026 to the
+// audit-orders-2026.10 index.
+//
+// Supported placeholders are {module} and {action}, which are lowercased as
+// required by index names, and {yyyy}, {MM} and {dd}, which are taken from
+// the time the entry occurred at, in UTC.
+func WithElasticIndexPattern(pattern string) ElasticLoggerOption {
+	return func(options *elasticLogger) {
+		options.index = func(entry *Entry) string {
+			return expandElasticIndex(pattern, entry)
+		}
+	}
+}
+
+// WithElasticIndexFunc sets a function picking the index of each entry. If fn
+// is nil, the index given to the constructor is used.
+func WithElasticIndexFunc(fn func(*Entry) string) ElasticLoggerOption {
+	return func(options *elasticLogger) {
+		if fn != nil {
+			options.index = fn
+		}
+	}
+}
+
+// WithElasticDataStream states that entries are written to data streams.
+// Entries are then written using create operations, already written entries
+// are ignored, and documents get the @timestamp field required by data
+// streams, holding the time the entry occurred at.
+func WithElasticDataStream() ElasticLoggerOption {
+	return func(options *elasticLogger) {
+		options.dataStream = true
+	}
+}
+
+// elasticIndexInvalidChars matches the characters not allowed in index names.
+var elasticIndexInvalidChars = regexp.MustCompile(`[\\/*?"<>| ,#:]+`)
+
+// expandElasticIndex expands the placeholders of the given index pattern.
+func expandElasticIndex(pattern string, entry *Entry) string {
+	at := entry.GetOccurredAt().UTC()
+	name := func(s string) string {
+		return elasticIndexInvalidChars.ReplaceAllString(strings.ToLower(s), "_")
+	}
+
+	return strings.NewReplacer(
+		"{module}", name(entry.GetModule()),
+		"{action}", name(entry.GetAction()),
+		"{yyyy}", at.Format("2006"),
+		"{MM}", at.Format("01"),
+		"{dd}", at.Format("02"),
+	).Replace(pattern)
+}
@@ -0,0 +1,297 @@
+package auditrail
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+
+	"github.com/elastic/go-elasticsearch"
+	"github.com/elastic/go-elasticsearch/esapi"
+)
+
+// ElasticBootstrapConfig describes the resources installed by
+// [BootstrapElastic].
+type ElasticBootstrapConfig struct {
+	// Name of the index template, lifecycle policy and ingest pipeline.
+	// Defaults to "auditrail".
+	Name string
+
+	// IndexPatterns matched by the index template. Defaults to Name followed
+	// by "-*".
+	IndexPatterns []string
+
+	// DataStream makes the index template create data streams, rolled over by
+	// the lifecycle policy, instead of regular indices.
+	DataStream bool
+
+	// RolloverMaxAge and RolloverMaxSize control when the backing indices of
+	// data streams are rolled over. Default to "30d" and "50gb".
+	RolloverMaxAge  string
+	RolloverMaxSize string
+
+	// DeleteAfter sets how long indices are retained before being deleted by
+	// the lifecycle policy, e.g. "365d". Indices are never deleted if empty.
+	DeleteAfter string
+
+	// GeoIP enriches entries carrying a client IP address but no GeoIP details
+	// using the geoip processor of the ingest pipeline. See
+	// [github.com/botchris/go-auditrail/networkd.IPResolver].
+	GeoIP bool
+
+	// Shards and Replicas of the created indices. Cluster defaults are used
+	// when zero.
+	Shards   int
+	Replicas int
+}
+
+// BootstrapElastic installs in ElasticSearch the resources used to store
+// audit entries: an index lifecycle (ILM) policy, an ingest pipeline and an
+// index template with a strict mapping of entries, including the details
+// added by the httpd and networkd decorators. Existing resources with the same
+// name are replaced.
+//
+// The ingest pipeline is set as default pipeline of the indices; it converts
+// GeoIP locations into geo points and, when enabled, enriches entries using
+// the geoip processor.
+func BootstrapElastic(ctx context.Context, client *elasticsearch.Client, config ElasticBootstrapConfig) error {
+	if config.Name == "" {
+		config.Name = "auditrail"
+	}
+
+	if len(config.IndexPatterns) == 0 {
+		config.IndexPatterns = []string{config.Name + "-*"}
+	}
+
+	if config.RolloverMaxAge == "" {
+		config.RolloverMaxAge = "30d"
+	}
+
+	if config.RolloverMaxSize == "" {
+		config.RolloverMaxSize = "50gb"
+	}
+
+	name := url.PathEscape(config.Name)
+
+	for _, r := range []struct {
+		path string
+		body interface{}
+	}{
+		{"/_ilm/policy/" + name, elasticLifecyclePolicy(config)},
+		{"/_ingest/pipeline/" + name, elasticIngestPipeline(config)},
+		{"/_index_template/" + name, elasticIndexTemplate(config)},
+	} {
+		if err := elasticPut(ctx, client, r.path, r.body); err != nil {
+			return fmt.Errorf("%w: could not install %s", err, r.path)
+		}
+	}
+
+	return nil
+}
+
+// elasticPut sends a PUT request with the given JSON body.
+func elasticPut(ctx context.Context, client *elasticsearch.Client, path string, body interface{}) error {
+	raw, err := json.Marshal(body)
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPut, path, bytes.NewReader(raw))
+	if err != nil {
+		return err
+	}
+
+	req.Header.Set("Content-Type", "application/json")
+
+	res, err := client.Transport.Perform(req)
+	if err != nil {
+		return fmt.Errorf("%w: %w", ErrRetryable, err)
+	}
+
+	return elasticResponseError(&esapi.Response{StatusCode: res.StatusCode, Header: res.Header, Body: res.Body})
+}
+
+func elasticLifecyclePolicy(config ElasticBootstrapConfig) map[string]interface{} {
+	phases := map[string]interface{}{}
+
+	if config.DataStream {
+		phases["hot"] = map[string]interface{}{
+			"actions": map[string]interface{}{
+				"rollover": map[string]string{
+					"max_age":  config.RolloverMaxAge,
+					"max_size": config.RolloverMaxSize,
+				},
+			},
+		}
+	}
+
+	if config.DeleteAfter != "" {
+		phases["delete"] = map[string]interface{}{
+			"min_age": config.DeleteAfter,
+			"actions": map[string]interface{}{"delete": map[string]interface{}{}},
+		}
+	}
+
+	return map[string]interface{}{
+		"policy": map[string]interface{}{"phases": phases},
+	}
+}
+
+func elasticIngestPipeline(config ElasticBootstrapConfig) map[string]interface{} {
+	processors := make([]interface{}, 0, 3)
+
+	if config.GeoIP {
+		processors = append(processors,
+			map[string]interface{}{
+				"geoip": map[string]interface{}{
+					"if":             "ctx.details?.client?.client?.ip != null && ctx.details.client.client.geoip == null",
+					"field":          "details.client.client.ip",
+					"target_field":   "_geoip",
+					"ignore_missing": true,
+					"properties": []string{
+						"continent_name", "country_iso_code", "country_name", "region_iso_code",
+						"region_name", "city_name", "location", "timezone",
+					},
+				},
+			},
+			map[string]interface{}{
+				"script": map[string]interface{}{
+					"description": "maps the geoip processor output to networkd.GeoIP",
+					"if":          "ctx._geoip != null",
+					"lang":        "painless",
+					"source": `def g = ctx.remove('_geoip');
+ctx.details.client.client.geoip = [
+  'continent': ['name': g.continent_name],
+  'country': ['code': g.country_iso_code, 'name': g.country_name],
+  'subdivision': ['code': g.region_iso_code, 'name': g.region_name],
+  'city': ['name': g.city_name],
+  'location': g.location,
+  'timezone': g.timezone
+];`,
+				},
+			},
+		)
+	}
+
+	processors = append(processors, map[string]interface{}{
+		"script": map[string]interface{}{
+			"description": "converts networkd.Location into a geo point",
+			"if":          "ctx.details?.client?.client?.geoip?.location?.latitude != null",
+			"lang":        "painless",
+			"source": `def l = ctx.details.client.client.geoip.location;
+ctx.details.client.client.geoip.location = ['lat': l.latitude, 'lon': l.longitude];`,
+		},
+	})
+
+	return map[string]interface{}{
+		"description": "Prepares auditrail entries for indexing",
+		"processors":  processors,
+	}
+}
+
+func elasticIndexTemplate(config ElasticBootstrapConfig) map[string]interface{} {
+	settings := map[string]interface{}{
+		"index.lifecycle.name":   config.Name,
+		"index.default_pipeline": config.Name,
+	}
+
+	if config.Shards > 0 {
+		settings["index.number_of_shards"] = config.Shards
+	}
+
+	if config.Replicas > 0 {
+		settings["index.number_of_replicas"] = config.Replicas
+	}
+
+	template := map[string]interface{}{
+		"index_patterns": config.IndexPatterns,
+		"priority":       200,
+		"template": map[string]interface{}{
+			"settings": settings,
+			"mappings": elasticMappings(),
+		},
+		"_meta": map[string]string{"managed_by": "auditrail"},
+	}
+
+	if config.DataStream {
+		template["data_stream"] = map[string]interface{}{}
+	}
+
+	return template
+}
+
+// elasticMappings returns the strict mapping of entries. Details other than
+// the ones added by the httpd and networkd decorators are kept in the source
+// but not indexed.
+func elasticMappings() map[string]interface{} {
+	keyword := map[string]string{"type": "keyword"}
+	named := func() map[string]interface{} {
+		return map[string]interface{}{
+			"properties": map[string]interface{}{"code": keyword, "name": keyword},
+		}
+	}
+
+	return map[string]interface{}{
+		"dynamic": "strict",
+		"properties": map[string]interface{}{
+			"@timestamp":     map[string]string{"type": "date"},
+			"idempotency_id": keyword,
+			"actor":          keyword,
+			"action":         keyword,
+			"module":         keyword,
+			"correlation_id": keyword,
+			"causation_id":   keyword,
+			"auth_method":    keyword,
+			"occurred_at":    map[string]string{"type": "date_nanos"},
+			"details": map[string]interface{}{
+				"type":    "object",
+				"dynamic": false,
+				"properties": map[string]interface{}{
+					"http": map[string]interface{}{
+						"properties": map[string]interface{}{
+							"method":      keyword,
+							"status_code": keyword,
+							"user_agent": map[string]interface{}{
+								"type":   "text",
+								"fields": map[string]interface{}{"keyword": map[string]interface{}{"type": "keyword", "ignore_above": 512}},
+							},
+							"url": map[string]interface{}{
+								"properties": map[string]interface{}{"host": keyword, "path": keyword},
+							},
+						},
+					},
+					"client": map[string]interface{}{
+						"properties": map[string]interface{}{
+							"client": map[string]interface{}{
+								"properties": map[string]interface{}{
+									"ip": map[string]string{"type": "ip"},
+									"geoip": map[string]interface{}{
+										"properties": map[string]interface{}{
+											"as": map[string]interface{}{
+												"properties": map[string]interface{}{
+													"domain": keyword,
+													"name":   keyword,
+													"number": keyword,
+													"route":  keyword,
+													"type":   keyword,
+												},
+											},
+											"continent":   named(),
+											"country":     named(),
+											"city":        named(),
+											"subdivision": named(),
+											"location":    map[string]string{"type": "geo_point"},
+											"timezone":    keyword,
+										},
+									},
+								},
+							},
+						},
+					},
+				},
+			},
+		},
+	}
+}
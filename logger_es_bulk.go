@@ -0,0 +1,172 @@
+package auditrail
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+
+	"github.com/elastic/go-elasticsearch"
+)
+
+// elasticBulkItem is the result of an item of a bulk request.
+type elasticBulkItem struct {
+	Status int             `json:"status"`
+	Error  *elasticFailure `json:"error,omitempty"`
+}
+
+// elasticBulkResponse is the response of a bulk request.
+type elasticBulkResponse struct {
+	Errors bool                         `json:"errors"`
+	Items  []map[string]elasticBulkItem `json:"items"`
+}
+
+var _ BatchLogger = (*elasticBulkLogger)(nil)
+
+type elasticBulkLogger struct {
+	*elasticLogger
+}
+
+// NewElasticBulkLogger creates a new ElasticSearch logger that writes entries
+// using the _bulk API. Combine it with [NewBatcher] or [NewQueue] so entries
+// are sent in batches.
+//
+// Each entry is written with a create operation using its idempotency ID as
+// document ID, so entries that were already indexed, which ElasticSearch
+// reports as version conflicts, are considered successfully written. Any
+// other failure is reported per entry as an [ElasticError].
+func NewElasticBulkLogger(index string, client *elasticsearch.Client, options ...ElasticLoggerOption) BatchLogger {
+	return &elasticBulkLogger{elasticLogger: newElasticLogger(index, client, options...)}
+}
+
+func (e *elasticBulkLogger) Log(ctx context.Context, entry *Entry) error {
+	if errs := e.LogBatch(ctx, []*Entry{entry}); errs != nil {
+		return errs[0]
+	}
+
+	return nil
+}
+
+func (e *elasticBulkLogger) LogBatch(ctx context.Context, entries []*Entry) []error {
+	if e.IsClosed() {
+		return batchErrors(len(entries), ErrTrailClosed)
+	}
+
+	if len(entries) == 0 {
+		return nil
+	}
+
+	body, errs := elasticBulkBody(entries, e.index, e.document)
+	if body.Len() == 0 {
+		return errs
+	}
+
+	res, err := e.client.Bulk(
+		bytes.NewReader(body.Bytes()),
+		e.client.Bulk.WithContext(ctx),
+	)
+	if err != nil {
+		return mergeBatchErrors(errs, len(entries), fmt.Errorf("%w: %w: could not send bulk request", ErrRetryable, err))
+	}
+
+	if res.IsError() {
+		return mergeBatchErrors(errs, len(entries), elasticResponseError(res))
+	}
+
+	defer res.Body.Close()
+
+	return elasticBulkResults(res.Body, errs, len(entries))
+}
+
+// elasticBulkBody builds the body of a bulk request creating the given
+// entries. Entries that cannot be encoded are left out and reported in the
+// returned errors.
+func elasticBulkBody(entries []*Entry, index func(*Entry) string, document func(*Entry) ([]byte, error)) (*bytes.Buffer, []error) {
+	body := &bytes.Buffer{}
+
+	var errs []error
+
+	for i, entry := range entries {
+		doc, err := document(entry)
+		if err != nil {
+			errs = setBatchError(errs, len(entries), i, fmt.Errorf("%w: %w: could not encode entry", ErrPermanent, err))
+
+			continue
+		}
+
+		action, _ := json.Marshal(map[string]interface{}{
+			"create": map[string]string{
+				"_index": index(entry),
+				"_id":    entry.GetIdempotencyID(),
+			},
+		})
+
+		body.Write(action)
+		body.WriteByte('\n')
+		body.Write(doc)
+		body.WriteByte('\n')
+	}
+
+	return body, errs
+}
+
+// elasticBulkResults reads the response of a bulk request for n entries and
+// returns the error of each entry. Entries that already failed, as reported
+// by errs, were left out of the request.
+func elasticBulkResults(r io.Reader, errs []error, n int) []error {
+	result := elasticBulkResponse{}
+	if err := json.NewDecoder(r).Decode(&result); err != nil {
+		return mergeBatchErrors(errs, n, fmt.Errorf("%w: %w: invalid bulk response", ErrRetryable, err))
+	}
+
+	if !result.Errors {
+		return errs
+	}
+
+	// items are reported in the same order as the operations.
+	items := result.Items
+
+	for i := 0; i < n; i++ {
+		if errs != nil && errs[i] != nil {
+			continue
+		}
+
+		if len(items) == 0 {
+			errs = setBatchError(errs, n, i, fmt.Errorf("%w: missing bulk item result", ErrRetryable))
+
+			continue
+		}
+
+		item := items[0]["create"]
+		items = items[1:]
+
+		if err := item.err(); err != nil {
+			errs = setBatchError(errs, n, i, err)
+		}
+	}
+
+	return errs
+}
+
+// err returns the error of the item, if any. Version conflicts are reported
+// for documents that already exist, so they are not considered errors.
+func (i elasticBulkItem) err() error {
+	if i.Status == http.StatusConflict {
+		return nil
+	}
+
+	if i.Error == nil && i.Status < http.StatusMultipleChoices {
+		return nil
+	}
+
+	err := &ElasticError{Status: i.Status}
+
+	if i.Error != nil {
+		err.Type = i.Error.Type
+		err.Reason = i.Error.Reason
+	}
+
+	return err
+}
@@ -5,6 +5,7 @@ import (
 	"encoding/json"
 	"io"
 	"net/http"
+	"net/http/httptest"
 	"strings"
 	"testing"
 	"time"
@@ -81,3 +82,254 @@ type fakeTransport struct {
 func (t *fakeTransport) RoundTrip(req *http.Request) (*http.Response, error) {
 	return t.RoundTripFn(req)
 }
+
+func TestElasticBulkLogger(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	// the stand-in reports the status of each item from the actor of its
+	// entry, e.g. an entry logged by "409" is reported as a version conflict.
+	var requests []string
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/_bulk" {
+			w.WriteHeader(http.StatusNotFound)
+
+			return
+		}
+
+		raw, err := io.ReadAll(r.Body)
+		require.NoError(t, err)
+
+		requests = append(requests, string(raw))
+
+		if strings.Contains(string(raw), `"actor":"overloaded"`) {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			_, _ = w.Write([]byte(`{"error":{"type":"unavailable_shards_exception","reason":"no shards"},"status":503}`))
+
+			return
+		}
+
+		lines := strings.Split(strings.TrimSpace(string(raw)), "\n")
+		items := make([]map[string]interface{}, 0, len(lines)/2)
+		failed := false
+
+		for i := 0; i < len(lines); i += 2 {
+			var action map[string]map[string]string
+			require.NoError(t, json.Unmarshal([]byte(lines[i]), &action))
+			require.Contains(t, action, "create")
+
+			var doc map[string]interface{}
+			require.NoError(t, json.Unmarshal([]byte(lines[i+1]), &doc))
+			require.Equal(t, action["create"]["_id"], doc["idempotency_id"])
+
+			item := map[string]interface{}{"_index": action["create"]["_index"], "_id": action["create"]["_id"], "status": 201}
+
+			switch doc["actor"] {
+			case "409":
+				item["status"] = 409
+				item["error"] = map[string]string{"type": "version_conflict_engine_exception", "reason": "document already exists"}
+			case "400":
+				item["status"] = 400
+				item["error"] = map[string]string{"type": "mapper_parsing_exception", "reason": "failed to parse field [details]"}
+			case "429":
+				item["status"] = 429
+				item["error"] = map[string]string{"type": "es_rejected_execution_exception", "reason": "rejected execution"}
+			}
+
+			failed = failed || item["status"] != 201
+			items = append(items, map[string]interface{}{"create": item})
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(map[string]interface{}{"took": 3, "errors": failed, "items": items})
+	}))
+	defer srv.Close()
+
+	client, err := elasticsearch.NewClient(elasticsearch.Config{Addresses: []string{srv.URL}})
+	require.NoError(t, err)
+
+	logger := auditrail.NewElasticBulkLogger("audit", client)
+
+	t.Run("GIVEN a batch of entries WHEN logging them THEN a single bulk request is sent with create operations", func(t *testing.T) {
+		requests = nil
+		entries := []*auditrail.Entry{newFakeEntry(), newFakeEntry(), newFakeEntry()}
+
+		require.Nil(t, logger.LogBatch(ctx, entries))
+		require.Len(t, requests, 1)
+		require.Contains(t, requests[0], `{"create":{"_id":"`+entries[0].GetIdempotencyID()+`","_index":"audit"}}`)
+	})
+
+	t.Run("GIVEN items failing in a bulk request WHEN logging them THEN errors are reported per entry", func(t *testing.T) {
+		entries := []*auditrail.Entry{
+			auditrail.NewEntry("john", "order_create", "orders"),
+			auditrail.NewEntry("409", "order_create", "orders"),
+			auditrail.NewEntry("400", "order_create", "orders"),
+			auditrail.NewEntry("429", "order_create", "orders"),
+		}
+
+		errs := logger.LogBatch(ctx, entries)
+		require.Len(t, errs, 4)
+		require.NoError(t, errs[0])
+		require.NoError(t, errs[1], "version conflicts are duplicates")
+
+		var esErr *auditrail.ElasticError
+
+		require.ErrorAs(t, errs[2], &esErr)
+		require.Equal(t, "mapper_parsing_exception", esErr.Type)
+		require.ErrorIs(t, errs[2], auditrail.ErrPermanent)
+		require.ErrorIs(t, errs[3], auditrail.ErrRetryable)
+	})
+
+	t.Run("GIVEN a failing bulk request WHEN logging THEN every entry reports a retryable error", func(t *testing.T) {
+		errs := logger.LogBatch(ctx, []*auditrail.Entry{newFakeEntry(), auditrail.NewEntry("overloaded", "order_create", "orders")})
+		require.Len(t, errs, 2)
+
+		for _, err := range errs {
+			var esErr *auditrail.ElasticError
+
+			require.ErrorAs(t, err, &esErr)
+			require.Equal(t, http.StatusServiceUnavailable, esErr.Status)
+			require.ErrorIs(t, err, auditrail.ErrRetryable)
+		}
+	})
+
+	t.Run("GIVEN a retryer WHEN an entry is permanently rejected THEN it is dropped without retrying", func(t *testing.T) {
+		var dropped []error
+
+		retryer := auditrail.NewRetryer(
+			auditrail.NewElasticBulkLogger("audit", client),
+			auditrail.WithRetryDropHandler(func(_ *auditrail.Entry, err error) { dropped = append(dropped, err) }),
+		)
+		defer checkClose(t, ctx, retryer)
+
+		requests = nil
+
+		require.NoError(t, retryer.Log(ctx, auditrail.NewEntry("400", "order_create", "orders")))
+		require.Len(t, requests, 1)
+		require.Len(t, dropped, 1)
+		require.ErrorIs(t, dropped[0], auditrail.ErrPermanent)
+	})
+
+	t.Run("GIVEN a queue WHEN logging many entries THEN workers send them in bulk", func(t *testing.T) {
+		requests = nil
+
+		queue := auditrail.NewQueue(auditrail.NewElasticBulkLogger("audit", client), auditrail.WithQueueBatchSize(50))
+
+		for i := 0; i < 120; i++ {
+			require.NoError(t, queue.Log(ctx, newFakeEntry()))
+		}
+
+		checkClose(t, ctx, queue)
+		require.NotEmpty(t, requests)
+		require.Less(t, len(requests), 120)
+	})
+}
+
+func TestElasticIndices(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	type request struct {
+		method string
+		path   string
+		query  string
+		body   string
+	}
+
+	var requests []request
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		raw, err := io.ReadAll(r.Body)
+		require.NoError(t, err)
+
+		requests = append(requests, request{method: r.Method, path: r.URL.Path, query: r.URL.RawQuery, body: string(raw)})
+
+		w.Header().Set("Content-Type", "application/json")
+
+		if strings.HasSuffix(r.URL.Path, "/conflict") {
+			w.WriteHeader(http.StatusConflict)
+			_, _ = w.Write([]byte(`{"error":{"type":"version_conflict_engine_exception","reason":"document already exists"}}`))
+
+			return
+		}
+
+		_, _ = w.Write([]byte(`{"acknowledged":true,"errors":false,"items":[]}`))
+	}))
+	defer srv.Close()
+
+	client, err := elasticsearch.NewClient(elasticsearch.Config{Addresses: []string{srv.URL}})
+	require.NoError(t, err)
+
+	entry := auditrail.NewEntry("john", "Order Create", "Orders").
+		WithOccurredAt(time.Date(2026, 10, 17, 10, 30, 0, 0, time.UTC))
+
+	t.Run("GIVEN an index pattern WHEN logging an entry THEN it is written to a time based index", func(t *testing.T) {
+		requests = nil
+
+		logger := auditrail.NewElasticLogger("ignored", client, auditrail.WithElasticIndexPattern("audit-{module}-{yyyy}.{MM}"))
+		require.NoError(t, logger.Log(ctx, entry))
+
+		bulk := auditrail.NewElasticBulkLogger("ignored", client, auditrail.WithElasticIndexPattern("audit-{action}-{yyyy}.{MM}.{dd}"))
+		require.Nil(t, bulk.LogBatch(ctx, []*auditrail.Entry{entry}))
+
+		require.Len(t, requests, 2)
+		require.True(t, strings.HasPrefix(requests[0].path, "/audit-orders-2026.10/"), requests[0].path)
+		require.Contains(t, requests[1].body, `"_index":"audit-order_create-2026.10.17"`)
+	})
+
+	t.Run("GIVEN a data stream WHEN logging an entry THEN it is created with a @timestamp", func(t *testing.T) {
+		requests = nil
+
+		logger := auditrail.NewElasticLogger("logs-audit-default", client, auditrail.WithElasticDataStream())
+		require.NoError(t, logger.Log(ctx, entry))
+
+		require.Len(t, requests, 1)
+		require.Contains(t, requests[0].query, "op_type=create")
+		require.True(t, strings.HasPrefix(requests[0].body, `{"@timestamp":"2026-10-17T10:30:00Z","idempotency_id":`), requests[0].body)
+
+		t.Run("AND the entry already exists THEN the conflict is ignored", func(t *testing.T) {
+			dup := auditrail.NewEntry("john", "order_create", "orders").WithIdempotency("conflict")
+			require.NoError(t, logger.Log(ctx, dup))
+		})
+	})
+
+	t.Run("GIVEN a bootstrap config WHEN bootstrapping THEN policy, pipeline and template are installed", func(t *testing.T) {
+		requests = nil
+
+		err := auditrail.BootstrapElastic(ctx, client, auditrail.ElasticBootstrapConfig{
+			Name:        "audit",
+			DataStream:  true,
+			DeleteAfter: "365d",
+			GeoIP:       true,
+		})
+		require.NoError(t, err)
+		require.Len(t, requests, 3)
+
+		require.Equal(t, http.MethodPut, requests[0].method)
+		require.Equal(t, "/_ilm/policy/audit", requests[0].path)
+		require.Contains(t, requests[0].body, `"rollover":{"max_age":"30d","max_size":"50gb"}`)
+		require.Contains(t, requests[0].body, `"min_age":"365d"`)
+
+		require.Equal(t, "/_ingest/pipeline/audit", requests[1].path)
+		require.Contains(t, requests[1].body, `"geoip":{`)
+
+		var template struct {
+			IndexPatterns []string               `json:"index_patterns"`
+			DataStream    map[string]interface{} `json:"data_stream"`
+			Template      struct {
+				Settings map[string]interface{} `json:"settings"`
+				Mappings map[string]interface{} `json:"mappings"`
+			} `json:"template"`
+		}
+
+		require.Equal(t, "/_index_template/audit", requests[2].path)
+		require.NoError(t, json.Unmarshal([]byte(requests[2].body), &template))
+		require.Equal(t, []string{"audit-*"}, template.IndexPatterns)
+		require.NotNil(t, template.DataStream)
+		require.Equal(t, "audit", template.Template.Settings["index.default_pipeline"])
+		require.Equal(t, "strict", template.Template.Mappings["dynamic"])
+		require.Contains(t, requests[2].body, `"location":{"type":"geo_point"}`)
+		require.Contains(t, requests[2].body, `"ip":{"type":"ip"}`)
+	})
+}
@@ -2,14 +2,17 @@ package auditrail
 
 import (
 	"context"
-	"encoding/json"
 	"fmt"
 	"os"
 	"sync"
 )
 
+// FileLoggerOption is a function that configures a file logger.
+type FileLoggerOption func(options *fileDescriptor)
+
 type fileDescriptor struct {
 	fd           *os.File
+	encoder      Encoder
 	closed       bool
 	closeChannel chan struct{}
 	mu           sync.RWMutex
@@ -22,7 +25,7 @@ type fileDescriptor struct {
 // returned. You can use os.Stdout or os.Stderr as file descriptors.
 //
 // The file descriptor must be closed by the caller.
-func NewFileLogger(fd *os.File) (Logger, error) {
+func NewFileLogger(fd *os.File, options ...FileLoggerOption) (Logger, error) {
 	if fd == nil {
 		return nil, fmt.Errorf("file descriptor was nil")
 	}
@@ -45,10 +48,21 @@ func NewFileLogger(fd *os.File) (Logger, error) {
 		return nil, fmt.Errorf("file descriptor is not writable")
 	}
 
-	return &fileDescriptor{
+	dsc := &fileDescriptor{
 		fd:           fd,
+		encoder:      NewJSONCodec(),
 		closeChannel: make(chan struct{}),
-	}, nil
+	}
+
+	for _, option := range options {
+		option(dsc)
+	}
+
+	if isBinaryEncoder(dsc.encoder) {
+		return nil, fmt.Errorf("file logger requires a text encoder")
+	}
+
+	return dsc, nil
 }
 
 func (dsc *fileDescriptor) Log(_ context.Context, entry *Entry) error {
@@ -60,7 +74,7 @@ func (dsc *fileDescriptor) Log(_ context.Context, entry *Entry) error {
 		return ErrTrailClosed
 	}
 
-	log, err := json.Marshal(entry)
+	log, err := dsc.encoder.Encode(entry)
 	if err != nil {
 		return err
 	}
@@ -103,7 +117,7 @@ func (dsc *fileDescriptor) IsClosed() bool {
 // the given path.
 //
 // If the file does not exist, it will be created.
-func NewFilePathLogger(path string) (Logger, error) {
+func NewFilePathLogger(path string, options ...FileLoggerOption) (Logger, error) {
 	if _, err := os.Stat(path); os.IsNotExist(err) {
 		file, cErr := os.Create(path)
 		if cErr != nil {
@@ -120,5 +134,18 @@ func NewFilePathLogger(path string) (Logger, error) {
 		return nil, err
 	}
 
-	return NewFileLogger(fd)
+	return NewFileLogger(fd, options...)
+}
+
+// WithFileEncoder sets the encoder used to write entries, one per line. The
+// encoder must produce single line text such as JSON, binary codecs are
+// rejected. If encoder is nil, entries are encoded as JSON.
+func WithFileEncoder(encoder Encoder) FileLoggerOption {
+	return func(options *fileDescriptor) {
+		if encoder == nil {
+			encoder = NewJSONCodec()
+		}
+
+		options.encoder = encoder
+	}
 }
@@ -0,0 +1,256 @@
+package auditrail
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"fmt"
+	"sync"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/firehose"
+	"github.com/aws/aws-sdk-go-v2/service/firehose/types"
+)
+
+const (
+	// firehoseMaxRecordsPerCall is the maximum number of records of a
+	// PutRecordBatch request.
+	firehoseMaxRecordsPerCall = 500
+
+	// firehoseMaxBytesPerCall is the maximum size of a PutRecordBatch request.
+	firehoseMaxBytesPerCall = 4 << 20
+
+	// firehoseMaxRecordSize is the maximum size of a record.
+	firehoseMaxRecordSize = 1000 << 10
+)
+
+// FirehoseAPI captures the firehose client part that we need.
+type FirehoseAPI interface {
+	PutRecord(ctx context.Context, params *firehose.PutRecordInput, optFns ...func(*firehose.Options)) (*firehose.PutRecordOutput, error)
+	PutRecordBatch(ctx context.Context, params *firehose.PutRecordBatchInput, optFns ...func(*firehose.Options)) (*firehose.PutRecordBatchOutput, error)
+}
+
+// FirehoseLoggerOption is a function that configures a Firehose logger.
+type FirehoseLoggerOption func(options *firehoseLogger)
+
+var _ BatchLogger = (*firehoseLogger)(nil)
+
+type firehoseLogger struct {
+	client       FirehoseAPI
+	streamName   string
+	encoder      Encoder
+	gzip         bool
+	attempts     int
+	backoff      ExponentialBackoffConfig
+	closed       bool
+	closeChannel chan struct{}
+	mu           sync.RWMutex
+}
+
+// NewFirehoseLogger builds a new logger that writes entries to a Firehose
+// stream as JSON objects separated by newlines, so they can be delivered to
+// S3 or Redshift as they are. Single entries are written using PutRecord and
+// batches using PutRecordBatch; combine it with [NewBatcher] or [NewQueue] so
+// entries are sent in batches.
+//
+// Batches are split into requests of up to 500 records and 4 MiB. Records
+// reported as failed by Firehose through FailedPutCount are retried on their
+// own, as configured by [WithFirehoseRetries]; records still failing
+// afterward are reported per entry with an error wrapping [ErrRetryable], as
+// are the errors of failed requests.
+func NewFirehoseLogger(client FirehoseAPI, streamName string, options ...FirehoseLoggerOption) (BatchLogger, error) {
+	l := &firehoseLogger{
+		client:       client,
+		streamName:   streamName,
+		encoder:      NewJSONCodec(),
+		attempts:     3,
+		backoff:      defaultAWSBatchBackoff,
+		closeChannel: make(chan struct{}),
+	}
+
+	for _, option := range options {
+		option(l)
+	}
+
+	return l, nil
+}
+
+func (l *firehoseLogger) Log(ctx context.Context, entry *Entry) error {
+	if l.IsClosed() {
+		return ErrTrailClosed
+	}
+
+	data, err := l.record(entry)
+	if err != nil {
+		return err
+	}
+
+	_, err = l.client.PutRecord(ctx, &firehose.PutRecordInput{
+		DeliveryStreamName: &l.streamName,
+		Record:             &types.Record{Data: data},
+	})
+	if err != nil {
+		return fmt.Errorf("%w: %w: could not put record to %s", ErrRetryable, err, l.streamName)
+	}
+
+	return nil
+}
+
+func (l *firehoseLogger) LogBatch(ctx context.Context, entries []*Entry) []error {
+	if l.IsClosed() {
+		return batchErrors(len(entries), ErrTrailClosed)
+	}
+
+	var errs []error
+
+	records := make([]kinesisRecord, 0, len(entries))
+
+	for i, entry := range entries {
+		data, err := l.record(entry)
+		if err != nil {
+			errs = setBatchError(errs, len(entries), i, err)
+
+			continue
+		}
+
+		records = append(records, kinesisRecord{entries: []int{i}, data: data})
+	}
+
+	sender := awsBatchSender[kinesisRecord]{
+		limits:   awsBatchLimits{items: firehoseMaxRecordsPerCall, bytes: firehoseMaxBytesPerCall},
+		attempts: l.attempts,
+		backoff:  l.backoff,
+		send:     l.putRecordBatch,
+	}
+
+	return sender.sendAll(ctx, records, errs, len(entries))
+}
+
+// record returns the data of the record of the given entry.
+func (l *firehoseLogger) record(entry *Entry) ([]byte, error) {
+	data, err := encodeRecord(l.encoder, entry)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w: could not encode entry", ErrPermanent, err)
+	}
+
+	if l.gzip {
+		buf := &bytes.Buffer{}
+		zw := gzip.NewWriter(buf)
+
+		if _, err = zw.Write(data); err == nil {
+			err = zw.Close()
+		}
+
+		if err != nil {
+			return nil, fmt.Errorf("%w: %w: could not compress entry", ErrPermanent, err)
+		}
+
+		data = buf.Bytes()
+	}
+
+	if len(data) > firehoseMaxRecordSize {
+		return nil, fmt.Errorf("%w: firehose record exceeds the 1000 KiB limit: entry %s takes %d bytes",
+			ErrPermanent, entry.GetIdempotencyID(), len(data))
+	}
+
+	return data, nil
+}
+
+// putRecordBatch sends the given records in a single PutRecordBatch request,
+// returning the failure of every record.
+func (l *firehoseLogger) putRecordBatch(ctx context.Context, records []kinesisRecord) ([]error, error) {
+	input := &firehose.PutRecordBatchInput{
+		DeliveryStreamName: &l.streamName,
+		Records:            make([]types.Record, len(records)),
+	}
+
+	for i, r := range records {
+		input.Records[i] = types.Record{Data: r.data}
+	}
+
+	out, err := l.client.PutRecordBatch(ctx, input)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w: could not put firehose records", ErrRetryable, err)
+	}
+
+	failures := make([]error, len(records))
+
+	for i := range records {
+		switch {
+		case i >= len(out.RequestResponses):
+			failures[i] = fmt.Errorf("%w: missing firehose record result", ErrRetryable)
+		case out.RequestResponses[i].ErrorCode != nil:
+			failures[i] = fmt.Errorf("%w: firehose record failed: %s: %s",
+				ErrRetryable,
+				aws.ToString(out.RequestResponses[i].ErrorCode),
+				aws.ToString(out.RequestResponses[i].ErrorMessage),
+			)
+		}
+	}
+
+	return failures, nil
+}
+
+func (l *firehoseLogger) Close() error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if l.closed {
+		return nil
+	}
+
+	l.closed = true
+
+	close(l.closeChannel)
+
+	return nil
+}
+
+func (l *firehoseLogger) Closed() <-chan struct{} {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+
+	return l.closeChannel
+}
+
+func (l *firehoseLogger) IsClosed() bool {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+
+	return l.closed
+}
+
+// WithFirehoseEncoder sets the encoder used to build the records. If encoder
+// is nil, entries are encoded as JSON.
+func WithFirehoseEncoder(encoder Encoder) FirehoseLoggerOption {
+	return func(options *firehoseLogger) {
+		if encoder == nil {
+			encoder = NewJSONCodec()
+		}
+
+		options.encoder = encoder
+	}
+}
+
+// WithFirehoseGzip compresses each record with gzip. Firehose concatenates
+// records when delivering them, which results in a valid multi-member gzip
+// stream, so the delivered objects must not be compressed again.
+func WithFirehoseGzip() FirehoseLoggerOption {
+	return func(options *firehoseLogger) {
+		options.gzip = true
+	}
+}
+
+// WithFirehoseRetries sets how many times records reported as failed by a
+// PutRecordBatch request are sent, and the backoff between attempts. Defaults
+// to 3 attempts.
+func WithFirehoseRetries(attempts int, backoff ExponentialBackoffConfig) FirehoseLoggerOption {
+	return func(options *firehoseLogger) {
+		if attempts < 1 {
+			attempts = 1
+		}
+
+		options.attempts = attempts
+		options.backoff = backoff
+	}
+}
@@ -0,0 +1,235 @@
+package auditrail_test
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/firehose"
+	"github.com/aws/aws-sdk-go-v2/service/firehose/types"
+	"github.com/botchris/go-auditrail"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFirehoseLogger(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	fastRetries := auditrail.WithFirehoseRetries(3, auditrail.ExponentialBackoffConfig{
+		Base:   time.Millisecond,
+		Factor: time.Millisecond,
+		Max:    time.Millisecond,
+	})
+
+	t.Run("GIVEN a firehose logger WHEN logging an entry THEN a newline delimited JSON record is put", func(t *testing.T) {
+		api := &fakeFirehoseAPI{}
+		logger, err := auditrail.NewFirehoseLogger(api, "archive")
+		require.NoError(t, err)
+
+		entry := newFakeEntry()
+		require.NoError(t, logger.Log(ctx, entry))
+
+		require.Len(t, api.putCalls, 1)
+		require.Equal(t, "archive", *api.putCalls[0].DeliveryStreamName)
+
+		data := api.putCalls[0].Record.Data
+		require.True(t, bytes.HasSuffix(data, []byte("\n")))
+		require.Contains(t, string(data), entry.GetIdempotencyID())
+		require.True(t, json.Valid(data))
+	})
+
+	t.Run("GIVEN a batch of entries WHEN logging it THEN requests are limited to 500 records", func(t *testing.T) {
+		api := &fakeFirehoseAPI{}
+		logger, err := auditrail.NewFirehoseLogger(api, "archive")
+		require.NoError(t, err)
+
+		entries := make([]*auditrail.Entry, 700)
+		for i := range entries {
+			entries[i] = newFakeEntry()
+		}
+
+		require.Nil(t, logger.LogBatch(ctx, entries))
+		require.Empty(t, api.putCalls)
+		require.Len(t, api.batchCalls, 2)
+		require.Len(t, api.batchCalls[0].Records, 500)
+		require.Len(t, api.batchCalls[1].Records, 200)
+	})
+
+	t.Run("GIVEN records failing once WHEN logging a batch THEN only the failed records are retried", func(t *testing.T) {
+		api := &fakeFirehoseAPI{}
+		api.fail = func(r types.Record, attempt int) *string {
+			if attempt == 1 && strings.Contains(string(r.Data), `"actor":"throttled"`) {
+				return aws.String("ServiceUnavailableException")
+			}
+
+			return nil
+		}
+
+		logger, err := auditrail.NewFirehoseLogger(api, "archive", fastRetries)
+		require.NoError(t, err)
+
+		errs := logger.LogBatch(ctx, []*auditrail.Entry{
+			newFakeEntry(),
+			auditrail.NewEntry("throttled", "order_create", "orders"),
+			newFakeEntry(),
+		})
+		require.Nil(t, errs)
+		require.Len(t, api.batchCalls, 2)
+		require.Len(t, api.batchCalls[1].Records, 1)
+		require.Contains(t, string(api.batchCalls[1].Records[0].Data), `"actor":"throttled"`)
+	})
+
+	t.Run("GIVEN records failing on every attempt WHEN logging a batch THEN a retryable error is reported per entry", func(t *testing.T) {
+		api := &fakeFirehoseAPI{}
+		api.fail = func(r types.Record, _ int) *string {
+			if strings.Contains(string(r.Data), `"actor":"throttled"`) {
+				return aws.String("ServiceUnavailableException")
+			}
+
+			return nil
+		}
+
+		logger, err := auditrail.NewFirehoseLogger(api, "archive", fastRetries)
+		require.NoError(t, err)
+
+		errs := logger.LogBatch(ctx, []*auditrail.Entry{newFakeEntry(), auditrail.NewEntry("throttled", "order_create", "orders")})
+		require.Len(t, errs, 2)
+		require.NoError(t, errs[0])
+		require.ErrorIs(t, errs[1], auditrail.ErrRetryable)
+		require.ErrorContains(t, errs[1], "ServiceUnavailableException")
+		require.Len(t, api.batchCalls, 3)
+	})
+
+	t.Run("GIVEN a failing request WHEN logging a batch THEN every entry reports a retryable error", func(t *testing.T) {
+		api := &fakeFirehoseAPI{err: errors.New("connection reset")}
+		logger, err := auditrail.NewFirehoseLogger(api, "archive")
+		require.NoError(t, err)
+
+		errs := logger.LogBatch(ctx, []*auditrail.Entry{newFakeEntry(), newFakeEntry()})
+		require.Len(t, errs, 2)
+
+		for _, err := range errs {
+			require.ErrorIs(t, err, auditrail.ErrRetryable)
+		}
+
+		t.Run("AND logging a single entry THEN a retryable error is returned", func(t *testing.T) {
+			require.ErrorIs(t, logger.Log(ctx, newFakeEntry()), auditrail.ErrRetryable)
+		})
+	})
+
+	t.Run("GIVEN gzip WHEN logging entries THEN each record is compressed", func(t *testing.T) {
+		api := &fakeFirehoseAPI{}
+		logger, err := auditrail.NewFirehoseLogger(api, "archive", auditrail.WithFirehoseGzip())
+		require.NoError(t, err)
+
+		entries := []*auditrail.Entry{newFakeEntry(), newFakeEntry()}
+		require.Nil(t, logger.LogBatch(ctx, entries))
+		require.Len(t, api.batchCalls, 1)
+
+		// delivered objects concatenate records, a valid multi-member gzip stream.
+		var delivered []byte
+		for _, r := range api.batchCalls[0].Records {
+			delivered = append(delivered, r.Data...)
+		}
+
+		zr, err := gzip.NewReader(bytes.NewReader(delivered))
+		require.NoError(t, err)
+
+		raw, err := io.ReadAll(zr)
+		require.NoError(t, err)
+
+		lines := strings.Split(strings.TrimSpace(string(raw)), "\n")
+		require.Len(t, lines, 2)
+		require.Contains(t, lines[1], entries[1].GetIdempotencyID())
+	})
+
+	t.Run("GIVEN an entry exceeding the record size limit WHEN logging it THEN it is rejected", func(t *testing.T) {
+		api := &fakeFirehoseAPI{}
+		logger, err := auditrail.NewFirehoseLogger(api, "archive")
+		require.NoError(t, err)
+
+		large := newFakeEntry().AppendDetails("blob", strings.Repeat("x", 1_100_000))
+
+		require.ErrorIs(t, logger.Log(ctx, large), auditrail.ErrPermanent)
+		require.Empty(t, api.putCalls)
+	})
+
+	t.Run("GIVEN a closed logger WHEN logging THEN an error is returned", func(t *testing.T) {
+		logger, err := auditrail.NewFirehoseLogger(&fakeFirehoseAPI{}, "archive")
+		require.NoError(t, err)
+
+		checkClose(t, ctx, logger)
+		require.ErrorIs(t, logger.Log(ctx, newFakeEntry()), auditrail.ErrTrailClosed)
+	})
+}
+
+// fakeFirehoseAPI records the requests it receives. Records for which fail
+// returns an error code, given the attempt of the record, are reported as
+// failed.
+type fakeFirehoseAPI struct {
+	putCalls   []*firehose.PutRecordInput
+	batchCalls []*firehose.PutRecordBatchInput
+	fail       func(r types.Record, attempt int) *string
+	err        error
+	attempts   map[string]int
+	mu         sync.Mutex
+}
+
+func (f *fakeFirehoseAPI) PutRecord(_ context.Context, params *firehose.PutRecordInput, _ ...func(*firehose.Options)) (*firehose.PutRecordOutput, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if f.err != nil {
+		return nil, f.err
+	}
+
+	f.putCalls = append(f.putCalls, params)
+
+	return &firehose.PutRecordOutput{RecordId: aws.String("1")}, nil
+}
+
+func (f *fakeFirehoseAPI) PutRecordBatch(_ context.Context, params *firehose.PutRecordBatchInput, _ ...func(*firehose.Options)) (*firehose.PutRecordBatchOutput, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if f.err != nil {
+		return nil, f.err
+	}
+
+	if f.attempts == nil {
+		f.attempts = make(map[string]int)
+	}
+
+	f.batchCalls = append(f.batchCalls, params)
+
+	out := &firehose.PutRecordBatchOutput{FailedPutCount: aws.Int32(0)}
+
+	for _, r := range params.Records {
+		f.attempts[string(r.Data)]++
+
+		var code *string
+
+		if f.fail != nil {
+			code = f.fail(r, f.attempts[string(r.Data)])
+		}
+
+		if code != nil {
+			*out.FailedPutCount++
+			out.RequestResponses = append(out.RequestResponses, types.PutRecordBatchResponseEntry{ErrorCode: code, ErrorMessage: aws.String("slow down")})
+
+			continue
+		}
+
+		out.RequestResponses = append(out.RequestResponses, types.PutRecordBatchResponseEntry{RecordId: aws.String("1")})
+	}
+
+	return out, nil
+}
@@ -0,0 +1,256 @@
+package auditrail
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+)
+
+// DefaultJournaldSocket is the path of the socket where journald listens for
+// messages using its native protocol.
+const DefaultJournaldSocket = "/run/systemd/journal/socket"
+
+// JournaldLoggerOption is a function that configures a journald logger.
+type JournaldLoggerOption func(options *journaldLogger)
+
+type journaldLogger struct {
+	socket       string
+	identifier   string
+	priority     SyslogSeverity
+	encoder      Encoder
+	conn         *net.UnixConn
+	closed       bool
+	closeChannel chan struct{}
+	mu           sync.RWMutex
+}
+
+// NewJournaldLogger builds a new logger that writes log entries to the
+// systemd journal using the journald native protocol, so entry fields become
+// first-class journal fields that can be matched with journalctl:
+//
+//	journalctl AUDIT_ACTOR=john AUDIT_MODULE=orders
+//
+// Entry fields are mapped to AUDIT_IDEMPOTENCY_ID, AUDIT_ACTOR, AUDIT_ACTION,
+// AUDIT_MODULE, AUDIT_CORRELATION_ID, AUDIT_CAUSATION_ID, AUDIT_AUTH_METHOD,
+// AUDIT_OCCURRED_AT and AUDIT_DETAILS (as JSON), while the MESSAGE field holds
+// the JSON encoded entry.
+//
+// Messages too large to be sent as a single datagram are passed to journald
+// through a sealed memory file, which is only supported on Linux.
+func NewJournaldLogger(options ...JournaldLoggerOption) (Logger, error) {
+	l := &journaldLogger{
+		socket:       DefaultJournaldSocket,
+		identifier:   "auditrail",
+		priority:     SyslogSeverityNotice,
+		encoder:      NewJSONCodec(),
+		closeChannel: make(chan struct{}),
+	}
+
+	for _, option := range options {
+		option(l)
+	}
+
+	if err := l.connect(); err != nil {
+		return nil, err
+	}
+
+	return l, nil
+}
+
+func (l *journaldLogger) Log(_ context.Context, entry *Entry) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if l.closed {
+		return ErrTrailClosed
+	}
+
+	payload, err := l.payload(entry)
+	if err != nil {
+		return err
+	}
+
+	if l.conn == nil {
+		if err = l.connect(); err != nil {
+			return err
+		}
+	}
+
+	_, err = l.conn.Write(payload)
+	if errors.Is(err, syscall.EMSGSIZE) || errors.Is(err, syscall.ENOBUFS) {
+		err = journaldSendFD(l.conn, payload)
+	}
+
+	if err != nil {
+		_ = l.conn.Close()
+		l.conn = nil
+
+		return fmt.Errorf("%w: %w: could not write journal message", ErrRetryable, err)
+	}
+
+	return nil
+}
+
+func (l *journaldLogger) Close() error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if l.closed {
+		return nil
+	}
+
+	l.closed = true
+
+	close(l.closeChannel)
+
+	if l.conn == nil {
+		return nil
+	}
+
+	err := l.conn.Close()
+	l.conn = nil
+
+	return err
+}
+
+func (l *journaldLogger) Closed() <-chan struct{} {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+
+	return l.closeChannel
+}
+
+func (l *journaldLogger) IsClosed() bool {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+
+	return l.closed
+}
+
+func (l *journaldLogger) connect() error {
+	conn, err := net.DialUnix("unixgram", nil, &net.UnixAddr{Name: l.socket, Net: "unixgram"})
+	if err != nil {
+		return fmt.Errorf("%w: %w: could not connect to journald", ErrRetryable, err)
+	}
+
+	l.conn = conn
+
+	return nil
+}
+
+// payload serializes the given entry using the journald native protocol.
+func (l *journaldLogger) payload(entry *Entry) ([]byte, error) {
+	message, err := l.encoder.Encode(entry)
+	if err != nil {
+		return nil, err
+	}
+
+	var details []byte
+
+	if d := entry.GetDetails(); len(d) > 0 {
+		if details, err = json.Marshal(d); err != nil {
+			return nil, err
+		}
+	}
+
+	var occurredAt string
+
+	if at := entry.GetOccurredAt(); !at.IsZero() {
+		occurredAt = at.UTC().Format(time.RFC3339Nano)
+	}
+
+	b := &bytes.Buffer{}
+
+	for _, field := range []struct {
+		name  string
+		value []byte
+	}{
+		{"MESSAGE", bytes.TrimRight(message, "\n")},
+		{"PRIORITY", []byte(strconv.Itoa(int(l.priority)))},
+		{"SYSLOG_IDENTIFIER", []byte(l.identifier)},
+		{"AUDIT_IDEMPOTENCY_ID", []byte(entry.GetIdempotencyID())},
+		{"AUDIT_ACTOR", []byte(entry.GetActor())},
+		{"AUDIT_ACTION", []byte(entry.GetAction())},
+		{"AUDIT_MODULE", []byte(entry.GetModule())},
+		{"AUDIT_CORRELATION_ID", []byte(entry.GetCorrelationID())},
+		{"AUDIT_CAUSATION_ID", []byte(entry.GetCausationID())},
+		{"AUDIT_AUTH_METHOD", []byte(entry.GetAuthMethod())},
+		{"AUDIT_OCCURRED_AT", []byte(occurredAt)},
+		{"AUDIT_DETAILS", details},
+	} {
+		if len(field.value) > 0 {
+			appendJournaldField(b, field.name, field.value)
+		}
+	}
+
+	return b.Bytes(), nil
+}
+
+// appendJournaldField appends a field to the given buffer. Values containing
+// newlines are serialized as a little-endian 64-bit size followed by the raw
+// value, as required by the native protocol.
+func appendJournaldField(b *bytes.Buffer, name string, value []byte) {
+	b.WriteString(name)
+
+	if !bytes.ContainsRune(value, '\n') {
+		b.WriteByte('=')
+		b.Write(value)
+		b.WriteByte('\n')
+
+		return
+	}
+
+	b.WriteByte('\n')
+	_ = binary.Write(b, binary.LittleEndian, uint64(len(value)))
+	b.Write(value)
+	b.WriteByte('\n')
+}
+
+// WithJournaldSocket sets the path of the journald socket. Defaults to
+// [DefaultJournaldSocket].
+func WithJournaldSocket(path string) JournaldLoggerOption {
+	return func(options *journaldLogger) {
+		if path != "" {
+			options.socket = path
+		}
+	}
+}
+
+// WithJournaldIdentifier sets the SYSLOG_IDENTIFIER field of the messages.
+// Defaults to "auditrail".
+func WithJournaldIdentifier(identifier string) JournaldLoggerOption {
+	return func(options *journaldLogger) {
+		options.identifier = strings.TrimSpace(identifier)
+	}
+}
+
+// WithJournaldPriority sets the PRIORITY field of the messages. Defaults to
+// notice.
+func WithJournaldPriority(priority SyslogSeverity) JournaldLoggerOption {
+	return func(options *journaldLogger) {
+		if priority >= SyslogSeverityEmergency && priority <= SyslogSeverityDebug {
+			options.priority = priority
+		}
+	}
+}
+
+// WithJournaldEncoder sets the encoder used to build the MESSAGE field. If
+// encoder is nil, entries are encoded as JSON.
+func WithJournaldEncoder(encoder Encoder) JournaldLoggerOption {
+	return func(options *journaldLogger) {
+		if encoder == nil {
+			encoder = NewJSONCodec()
+		}
+
+		options.encoder = encoder
+	}
+}
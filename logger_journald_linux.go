@@ -0,0 +1,51 @@
+package auditrail
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"os"
+
+	"golang.org/x/sys/unix"
+)
+
+// journaldSendFD sends the given payload through a sealed memory file, which
+// journald reads when a message exceeds the maximum datagram size.
+func journaldSendFD(conn *net.UnixConn, payload []byte) error {
+	fd, err := unix.MemfdCreate("auditrail-journald", unix.MFD_ALLOW_SEALING|unix.MFD_CLOEXEC)
+	if err != nil {
+		return fmt.Errorf("%w: could not create memfd", err)
+	}
+
+	f := os.NewFile(uintptr(fd), "auditrail-journald")
+	defer f.Close()
+
+	if _, err = f.Write(payload); err != nil {
+		return err
+	}
+
+	seals := unix.F_SEAL_SHRINK | unix.F_SEAL_GROW | unix.F_SEAL_WRITE | unix.F_SEAL_SEAL
+	if _, err = unix.FcntlInt(uintptr(fd), unix.F_ADD_SEALS, seals); err != nil {
+		return fmt.Errorf("%w: could not seal memfd", err)
+	}
+
+	rc, err := conn.SyscallConn()
+	if err != nil {
+		return err
+	}
+
+	// WriteMsgUnix refuses connected datagram sockets, so the message is sent
+	// with sendmsg directly.
+	var sendErr error
+
+	err = rc.Write(func(s uintptr) bool {
+		sendErr = unix.Sendmsg(int(s), nil, unix.UnixRights(fd), nil, 0)
+
+		return !errors.Is(sendErr, unix.EAGAIN)
+	})
+	if err != nil {
+		return err
+	}
+
+	return sendErr
+}
@@ -0,0 +1,144 @@
+package auditrail_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"io"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/botchris/go-auditrail"
+	"github.com/stretchr/testify/require"
+)
+
+func TestJournaldLogger(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	t.Run("GIVEN a journald logger WHEN logging an entry THEN AUDIT fields are sent using the native protocol", func(t *testing.T) {
+		socket := listenJournald(t)
+
+		logger, err := auditrail.NewJournaldLogger(
+			auditrail.WithJournaldSocket(socket.LocalAddr().String()),
+			auditrail.WithJournaldIdentifier("shop"),
+		)
+		require.NoError(t, err)
+		defer checkClose(t, ctx, logger)
+
+		entry := auditrail.NewEntry("john\ndoe", "order_create", "orders").
+			WithCorrelation("c0ffee").
+			AppendDetails("amount", 125)
+
+		require.NoError(t, logger.Log(ctx, entry))
+
+		fields := readJournald(t, socket)
+		require.Equal(t, "shop", fields["SYSLOG_IDENTIFIER"])
+		require.Equal(t, "5", fields["PRIORITY"])
+		require.Equal(t, entry.GetIdempotencyID(), fields["AUDIT_IDEMPOTENCY_ID"])
+		require.Equal(t, "john\ndoe", fields["AUDIT_ACTOR"])
+		require.Equal(t, "order_create", fields["AUDIT_ACTION"])
+		require.Equal(t, "orders", fields["AUDIT_MODULE"])
+		require.Equal(t, "c0ffee", fields["AUDIT_CORRELATION_ID"])
+		require.JSONEq(t, `{"amount":125}`, fields["AUDIT_DETAILS"])
+		require.Contains(t, fields["MESSAGE"], `"action":"order_create"`)
+		require.NotContains(t, fields, "AUDIT_CAUSATION_ID")
+	})
+
+	t.Run("GIVEN an entry larger than a datagram WHEN logging THEN it is passed through a memfd", func(t *testing.T) {
+		socket := listenJournald(t)
+
+		logger, err := auditrail.NewJournaldLogger(auditrail.WithJournaldSocket(socket.LocalAddr().String()))
+		require.NoError(t, err)
+		defer checkClose(t, ctx, logger)
+
+		blob := strings.Repeat("x", 4<<20)
+		entry := auditrail.NewEntry("john", "upload", "files").AppendDetails("blob", blob)
+
+		require.NoError(t, logger.Log(ctx, entry))
+
+		fields := readJournald(t, socket)
+		require.Equal(t, "john", fields["AUDIT_ACTOR"])
+		require.Contains(t, fields["AUDIT_DETAILS"], blob)
+	})
+
+	t.Run("GIVEN a missing journald socket WHEN building the logger THEN a retryable error is returned", func(t *testing.T) {
+		_, err := auditrail.NewJournaldLogger(auditrail.WithJournaldSocket(filepath.Join(t.TempDir(), "missing")))
+		require.ErrorIs(t, err, auditrail.ErrRetryable)
+	})
+}
+
+// listenJournald starts a unix datagram socket standing in for journald.
+func listenJournald(t *testing.T) *net.UnixConn {
+	t.Helper()
+
+	path := filepath.Join(t.TempDir(), "journal.sock")
+
+	conn, err := net.ListenUnixgram("unixgram", &net.UnixAddr{Name: path, Net: "unixgram"})
+	require.NoError(t, err)
+
+	t.Cleanup(func() { _ = conn.Close() })
+
+	return conn
+}
+
+// readJournald reads a single message from the given socket, following the
+// memfd passed along when the datagram is empty, and parses its fields.
+func readJournald(t *testing.T, conn *net.UnixConn) map[string]string {
+	t.Helper()
+
+	buf := make([]byte, 256*1024)
+	oob := make([]byte, syscall.CmsgSpace(4))
+
+	require.NoError(t, conn.SetReadDeadline(time.Now().Add(5*time.Second)))
+
+	n, oobn, _, _, err := conn.ReadMsgUnix(buf, oob)
+	require.NoError(t, err)
+
+	payload := buf[:n]
+
+	if oobn > 0 {
+		msgs, pErr := syscall.ParseSocketControlMessage(oob[:oobn])
+		require.NoError(t, pErr)
+		require.Len(t, msgs, 1)
+
+		fds, pErr := syscall.ParseUnixRights(&msgs[0])
+		require.NoError(t, pErr)
+		require.Len(t, fds, 1)
+
+		f := os.NewFile(uintptr(fds[0]), "memfd")
+		defer f.Close()
+
+		st, sErr := f.Stat()
+		require.NoError(t, sErr)
+
+		payload, err = io.ReadAll(io.NewSectionReader(f, 0, st.Size()))
+		require.NoError(t, err)
+	}
+
+	fields := make(map[string]string)
+
+	for len(payload) > 0 {
+		line := bytes.IndexByte(payload, '\n')
+		require.Positive(t, line)
+
+		if eq := bytes.IndexByte(payload[:line], '='); eq >= 0 {
+			fields[string(payload[:eq])] = string(payload[eq+1 : line])
+			payload = payload[line+1:]
+
+			continue
+		}
+
+		name := string(payload[:line])
+		size := binary.LittleEndian.Uint64(payload[line+1 : line+9])
+		fields[name] = string(payload[line+9 : line+9+int(size)])
+		payload = payload[line+9+int(size)+1:]
+	}
+
+	return fields
+}
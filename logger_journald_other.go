@@ -0,0 +1,13 @@
+//go:build !linux
+
+package auditrail
+
+import (
+	"fmt"
+	"net"
+)
+
+// journaldSendFD is only supported on Linux, where memfd is available.
+func journaldSendFD(*net.UnixConn, []byte) error {
+	return fmt.Errorf("journal message exceeds the maximum datagram size")
+}
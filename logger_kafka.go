@@ -0,0 +1,336 @@
+package auditrail
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// Headers added to every Kafka record.
+const (
+	KafkaHeaderIdempotencyID = "idempotency_id"
+	KafkaHeaderActor         = "actor"
+	KafkaHeaderAction        = "action"
+	KafkaHeaderCorrelationID = "correlation_id"
+)
+
+// KafkaHeader is a header of a Kafka record.
+type KafkaHeader struct {
+	Key   string
+	Value []byte
+}
+
+// KafkaRecord is a record produced to Kafka.
+type KafkaRecord struct {
+	Topic     string
+	Key       []byte
+	Value     []byte
+	Headers   []KafkaHeader
+	Timestamp time.Time
+}
+
+// KafkaProducer captures the producer part that we need. It is modeled after
+// the franz-go client, and can be easily implemented on top of any other
+// Kafka client.
+//
+// Producers should be configured to be idempotent and to wait for the
+// acknowledgement of all in-sync replicas, so records are neither lost nor
+// duplicated when retried by the producer.
+type KafkaProducer interface {
+	// ProduceSync produces the given records and waits for them to be
+	// acknowledged. The returned slice is nil when all records were
+	// acknowledged, otherwise it holds the error of each record.
+	ProduceSync(ctx context.Context, records ...*KafkaRecord) []error
+
+	// Produce produces the given record asynchronously, calling promise
+	// once the record is acknowledged or fails.
+	Produce(ctx context.Context, record *KafkaRecord, promise func(*KafkaRecord, error))
+}
+
+// KafkaLoggerOption is a function that configures a Kafka logger.
+type KafkaLoggerOption func(options *kafkaLogger)
+
+var (
+	_ BatchLogger    = (*kafkaLogger)(nil)
+	_ ShutdownLogger = (*kafkaLogger)(nil)
+)
+
+type kafkaLogger struct {
+	producer     KafkaProducer
+	topic        string
+	encoder      Encoder
+	key          KeyFunc
+	async        bool
+	dropHandler  DropHandlerFunc
+	inflight     sync.WaitGroup
+	pending      map[*KafkaRecord]*Entry
+	delivered    int
+	pendingMu    sync.Mutex
+	closed       bool
+	closeChannel chan struct{}
+	mu           sync.RWMutex
+}
+
+// NewKafkaLogger builds a new logger that produces entries to the given Kafka
+// topic as JSON objects, keyed by module unless configured otherwise using
+// [WithKafkaKey]. Records carry the idempotency ID, actor, action and
+// correlation ID of entries as headers.
+//
+// By default, entries are written once acknowledged by Kafka. Use
+// [WithKafkaAsync] to produce entries without waiting for their
+// acknowledgement.
+func NewKafkaLogger(producer KafkaProducer, topic string, options ...KafkaLoggerOption) BatchLogger {
+	l := &kafkaLogger{
+		producer:     producer,
+		topic:        topic,
+		encoder:      NewJSONCodec(),
+		key:          KeyByModule,
+		dropHandler:  func(*Entry, error) {},
+		pending:      make(map[*KafkaRecord]*Entry),
+		closeChannel: make(chan struct{}),
+	}
+
+	for _, option := range options {
+		option(l)
+	}
+
+	return l
+}
+
+func (l *kafkaLogger) Log(ctx context.Context, entry *Entry) error {
+	if errs := l.LogBatch(ctx, []*Entry{entry}); errs != nil {
+		return errs[0]
+	}
+
+	return nil
+}
+
+func (l *kafkaLogger) LogBatch(ctx context.Context, entries []*Entry) []error {
+	l.mu.RLock()
+
+	if l.closed {
+		l.mu.RUnlock()
+
+		return batchErrors(len(entries), ErrTrailClosed)
+	}
+
+	// the call is accounted while the lock is held, so Shutdown waits for the
+	// records produced by it without holding the lock while producing.
+	l.inflight.Add(1)
+	l.mu.RUnlock()
+
+	defer l.inflight.Done()
+
+	var errs []error
+
+	records := make([]*KafkaRecord, 0, len(entries))
+	index := make([]int, 0, len(entries))
+
+	for i, entry := range entries {
+		record, err := l.record(entry)
+		if err != nil {
+			errs = setBatchError(errs, len(entries), i, err)
+
+			continue
+		}
+
+		records = append(records, record)
+		index = append(index, i)
+	}
+
+	if len(records) == 0 {
+		return errs
+	}
+
+	if l.async {
+		l.inflight.Add(len(records))
+
+		l.pendingMu.Lock()
+		for i, record := range records {
+			l.pending[record] = entries[index[i]]
+		}
+		l.pendingMu.Unlock()
+
+		// records outlive the call, so they must not be canceled along with
+		// the context of the caller.
+		produceCtx := context.WithoutCancel(ctx)
+
+		for _, record := range records {
+			l.producer.Produce(produceCtx, record, l.report)
+		}
+
+		return errs
+	}
+
+	for i, err := range l.producer.ProduceSync(ctx, records...) {
+		if err != nil && i < len(index) {
+			errs = setBatchError(errs, len(entries), index[i], fmt.Errorf("%w: %w: kafka delivery failed", ErrRetryable, err))
+		}
+	}
+
+	return errs
+}
+
+// record builds the Kafka record of the given entry.
+func (l *kafkaLogger) record(entry *Entry) (*KafkaRecord, error) {
+	value, err := l.encoder.Encode(entry)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w: could not encode entry", ErrPermanent, err)
+	}
+
+	headers := []KafkaHeader{
+		{Key: KafkaHeaderIdempotencyID, Value: []byte(entry.GetIdempotencyID())},
+		{Key: KafkaHeaderActor, Value: []byte(entry.GetActor())},
+		{Key: KafkaHeaderAction, Value: []byte(entry.GetAction())},
+	}
+
+	if id := entry.GetCorrelationID(); id != "" {
+		headers = append(headers, KafkaHeader{Key: KafkaHeaderCorrelationID, Value: []byte(id)})
+	}
+
+	return &KafkaRecord{
+		Topic:     l.topic,
+		Key:       []byte(l.key(entry)),
+		Value:     value,
+		Headers:   headers,
+		Timestamp: entry.GetOccurredAt(),
+	}, nil
+}
+
+// report handles the delivery report of a record produced asynchronously.
+// Reports of the records abandoned at shutdown are ignored.
+func (l *kafkaLogger) report(record *KafkaRecord, err error) {
+	defer l.inflight.Done()
+
+	l.pendingMu.Lock()
+	entry, ok := l.pending[record]
+	delete(l.pending, record)
+
+	if ok && err == nil {
+		l.delivered++
+	}
+	l.pendingMu.Unlock()
+
+	if ok && err != nil {
+		l.dropHandler(entry, fmt.Errorf("%w: %w: kafka delivery failed", ErrRetryable, err))
+	}
+}
+
+// Close closes the logger, waiting for the delivery reports of the records
+// produced asynchronously.
+func (l *kafkaLogger) Close() error {
+	_, err := l.Shutdown(context.Background())
+
+	return err
+}
+
+// Shutdown closes the logger, waiting for the delivery reports of the records
+// produced asynchronously until ctx is done. Then, the records still pending
+// are abandoned and their entries passed to the drop handler, although the
+// producer may still deliver them.
+func (l *kafkaLogger) Shutdown(ctx context.Context) (ShutdownReport, error) {
+	l.mu.Lock()
+
+	if l.closed {
+		l.mu.Unlock()
+
+		return ShutdownReport{}, nil
+	}
+
+	l.closed = true
+	l.mu.Unlock()
+
+	l.pendingMu.Lock()
+	delivered := l.delivered
+	l.pendingMu.Unlock()
+
+	done := make(chan struct{})
+
+	go func() {
+		l.inflight.Wait()
+
+		l.mu.Lock()
+		close(l.closeChannel)
+		l.mu.Unlock()
+
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		l.pendingMu.Lock()
+		defer l.pendingMu.Unlock()
+
+		return ShutdownReport{Flushed: l.delivered - delivered}, nil
+	case <-ctx.Done():
+	}
+
+	l.pendingMu.Lock()
+	abandoned := l.pending
+	l.pending = make(map[*KafkaRecord]*Entry)
+	report := ShutdownReport{Flushed: l.delivered - delivered, Abandoned: len(abandoned)}
+	l.pendingMu.Unlock()
+
+	err := abandonedError(ctx.Err())
+	for _, entry := range abandoned {
+		l.dropHandler(entry, err)
+	}
+
+	return report, fmt.Errorf("%w: kafka delivery reports could not be awaited before the deadline", ctx.Err())
+}
+
+func (l *kafkaLogger) Closed() <-chan struct{} {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+
+	return l.closeChannel
+}
+
+func (l *kafkaLogger) IsClosed() bool {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+
+	return l.closed
+}
+
+// WithKafkaEncoder sets the encoder used to build the values of records. If
+// encoder is nil, entries are encoded as JSON.
+func WithKafkaEncoder(encoder Encoder) KafkaLoggerOption {
+	return func(options *kafkaLogger) {
+		if encoder == nil {
+			encoder = NewJSONCodec()
+		}
+
+		options.encoder = encoder
+	}
+}
+
+// WithKafkaKey sets the function returning the key of the record of an entry,
+// which determines its partition, such as [KeyByActor] or a custom function.
+// If fn is nil, records are keyed by module.
+func WithKafkaKey(fn KeyFunc) KafkaLoggerOption {
+	return func(options *kafkaLogger) {
+		if fn == nil {
+			fn = KeyByModule
+		}
+
+		options.key = fn
+	}
+}
+
+// WithKafkaAsync produces entries without waiting for their acknowledgement.
+// Entries whose delivery fails are passed to the given handler, which must be
+// goroutine safe, with an error wrapping [ErrRetryable], or [ErrTrailClosed]
+// for the ones abandoned by a shutdown whose deadline was reached. Closing the
+// logger waits for pending delivery reports.
+func WithKafkaAsync(handler DropHandlerFunc) KafkaLoggerOption {
+	return func(options *kafkaLogger) {
+		if handler == nil {
+			handler = func(*Entry, error) {}
+		}
+
+		options.async = true
+		options.dropHandler = handler
+	}
+}
@@ -0,0 +1,284 @@
+package auditrail_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/botchris/go-auditrail"
+	"github.com/stretchr/testify/require"
+)
+
+func TestKafkaLogger(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	t.Run("GIVEN a kafka logger WHEN logging an entry THEN a keyed record with headers is produced", func(t *testing.T) {
+		producer := &memoryKafkaProducer{}
+		logger := auditrail.NewKafkaLogger(producer, "audit")
+		defer checkClose(t, ctx, logger)
+
+		entry := auditrail.NewEntry("john", "order_create", "orders").WithCorrelation("flow-1")
+		require.NoError(t, logger.Log(ctx, entry))
+
+		records := producer.Records()
+		require.Len(t, records, 1)
+		require.Equal(t, "audit", records[0].Topic)
+		require.Equal(t, "orders", string(records[0].Key))
+		require.Equal(t, entry.GetOccurredAt(), records[0].Timestamp)
+		require.True(t, json.Valid(records[0].Value))
+
+		headers := make(map[string]string)
+		for _, h := range records[0].Headers {
+			headers[h.Key] = string(h.Value)
+		}
+
+		require.Equal(t, map[string]string{
+			auditrail.KafkaHeaderIdempotencyID: entry.GetIdempotencyID(),
+			auditrail.KafkaHeaderActor:         "john",
+			auditrail.KafkaHeaderAction:        "order_create",
+			auditrail.KafkaHeaderCorrelationID: "flow-1",
+		}, headers)
+	})
+
+	t.Run("GIVEN a custom key WHEN logging an entry THEN records are keyed with it", func(t *testing.T) {
+		producer := &memoryKafkaProducer{}
+		logger := auditrail.NewKafkaLogger(producer, "audit", auditrail.WithKafkaKey(auditrail.KeyByActor))
+		defer checkClose(t, ctx, logger)
+
+		require.NoError(t, logger.Log(ctx, auditrail.NewEntry("john", "order_create", "orders")))
+		require.Equal(t, "john", string(producer.Records()[0].Key))
+	})
+
+	t.Run("GIVEN synchronous acks WHEN records are rejected THEN errors are reported per entry", func(t *testing.T) {
+		producer := &memoryKafkaProducer{fail: func(r *auditrail.KafkaRecord) error {
+			if string(r.Key) == "broken" {
+				return errors.New("NOT_ENOUGH_REPLICAS")
+			}
+
+			return nil
+		}}
+
+		logger := auditrail.NewKafkaLogger(producer, "audit")
+		defer checkClose(t, ctx, logger)
+
+		errs := logger.LogBatch(ctx, []*auditrail.Entry{newFakeEntry(), auditrail.NewEntry("john", "order_create", "broken")})
+		require.Len(t, errs, 2)
+		require.NoError(t, errs[0])
+		require.ErrorIs(t, errs[1], auditrail.ErrRetryable)
+		require.ErrorContains(t, errs[1], "NOT_ENOUGH_REPLICAS")
+		require.Len(t, producer.Records(), 1)
+	})
+
+	t.Run("GIVEN async production WHEN deliveries fail THEN entries are passed to the drop handler", func(t *testing.T) {
+		producer := &memoryKafkaProducer{fail: func(r *auditrail.KafkaRecord) error {
+			if string(r.Key) == "broken" {
+				return errors.New("MESSAGE_TOO_LARGE")
+			}
+
+			return nil
+		}}
+
+		var (
+			dropped []*auditrail.Entry
+			mu      sync.Mutex
+		)
+
+		logger := auditrail.NewKafkaLogger(producer, "audit", auditrail.WithKafkaAsync(func(e *auditrail.Entry, err error) {
+			mu.Lock()
+			defer mu.Unlock()
+
+			require.ErrorIs(t, err, auditrail.ErrRetryable)
+			require.ErrorContains(t, err, "MESSAGE_TOO_LARGE")
+			dropped = append(dropped, e)
+		}))
+
+		failing := auditrail.NewEntry("john", "order_create", "broken")
+		entries := []*auditrail.Entry{newFakeEntry(), failing, newFakeEntry()}
+
+		require.Nil(t, logger.LogBatch(ctx, entries))
+		require.NoError(t, logger.Log(ctx, newFakeEntry()))
+
+		t.Run("AND closing the logger THEN pending delivery reports are awaited", func(t *testing.T) {
+			checkClose(t, ctx, logger)
+
+			require.Len(t, producer.Records(), 3)
+			require.Equal(t, []*auditrail.Entry{failing}, dropped)
+		})
+	})
+
+	t.Run("GIVEN async production WHEN the context of the caller is canceled THEN pending records are still delivered", func(t *testing.T) {
+		producer := &memoryKafkaProducer{}
+
+		var dropped atomic.Int64
+
+		logger := auditrail.NewKafkaLogger(producer, "audit", auditrail.WithKafkaAsync(func(*auditrail.Entry, error) {
+			dropped.Add(1)
+		}))
+
+		callCtx, callCancel := context.WithCancel(ctx)
+		require.Nil(t, logger.LogBatch(callCtx, []*auditrail.Entry{newFakeEntry(), newFakeEntry()}))
+		callCancel()
+
+		checkClose(t, ctx, logger)
+		require.Len(t, producer.Records(), 2)
+		require.Zero(t, dropped.Load())
+	})
+
+	t.Run("GIVEN async production WHEN shutting down in time THEN pending records are reported as flushed", func(t *testing.T) {
+		producer := &memoryKafkaProducer{}
+		logger := auditrail.NewKafkaLogger(producer, "audit", auditrail.WithKafkaAsync(nil))
+
+		require.Nil(t, logger.LogBatch(ctx, []*auditrail.Entry{newFakeEntry(), newFakeEntry(), newFakeEntry()}))
+
+		report, err := auditrail.Shutdown(ctx, logger)
+		require.NoError(t, err)
+		require.Equal(t, auditrail.ShutdownReport{Flushed: 3}, report)
+		require.Len(t, producer.Records(), 3)
+		require.True(t, logger.IsClosed())
+	})
+
+	t.Run("GIVEN async production of unacknowledged records WHEN the shutdown deadline is reached THEN they are abandoned", func(t *testing.T) {
+		producer := &heldKafkaProducer{release: make(chan struct{})}
+		defer close(producer.release)
+
+		var abandoned atomic.Int64
+
+		logger := auditrail.NewKafkaLogger(producer, "audit", auditrail.WithKafkaAsync(func(_ *auditrail.Entry, err error) {
+			require.ErrorIs(t, err, auditrail.ErrTrailClosed)
+			abandoned.Add(1)
+		}))
+
+		require.Nil(t, logger.LogBatch(ctx, []*auditrail.Entry{newFakeEntry(), newFakeEntry()}))
+
+		deadline, cancelDeadline := context.WithTimeout(ctx, 20*time.Millisecond)
+		defer cancelDeadline()
+
+		report, err := auditrail.Shutdown(deadline, logger)
+		require.ErrorIs(t, err, context.DeadlineExceeded)
+		require.Equal(t, auditrail.ShutdownReport{Abandoned: 2}, report)
+		require.EqualValues(t, 2, abandoned.Load())
+	})
+
+	t.Run("GIVEN a stuck synchronous produce WHEN shutting down THEN the deadline is honored", func(t *testing.T) {
+		producer := &heldKafkaProducer{release: make(chan struct{}), received: make(chan struct{}, 1)}
+		logger := auditrail.NewKafkaLogger(producer, "audit")
+
+		done := make(chan error)
+		go func() { done <- logger.Log(ctx, newFakeEntry()) }()
+
+		<-producer.received
+
+		deadline, cancelDeadline := context.WithTimeout(ctx, 20*time.Millisecond)
+		defer cancelDeadline()
+
+		report, err := auditrail.Shutdown(deadline, logger)
+		require.ErrorIs(t, err, context.DeadlineExceeded)
+		require.Zero(t, report)
+
+		close(producer.release)
+		require.NoError(t, <-done)
+
+		select {
+		case <-logger.Closed():
+		case <-ctx.Done():
+			require.FailNow(t, "logger was not closed")
+		}
+	})
+
+	t.Run("GIVEN a closed logger WHEN logging THEN an error is returned", func(t *testing.T) {
+		logger := auditrail.NewKafkaLogger(&memoryKafkaProducer{}, "audit")
+		checkClose(t, ctx, logger)
+
+		require.ErrorIs(t, logger.Log(ctx, newFakeEntry()), auditrail.ErrTrailClosed)
+	})
+}
+
+// memoryKafkaProducer is an in-memory stand-in of a Kafka producer. Records
+// for which fail returns an error are not acknowledged. Asynchronous records
+// are acknowledged from another goroutine after a short delay, unless their
+// context is canceled first.
+type memoryKafkaProducer struct {
+	fail    func(*auditrail.KafkaRecord) error
+	records []*auditrail.KafkaRecord
+	mu      sync.Mutex
+}
+
+func (p *memoryKafkaProducer) ProduceSync(_ context.Context, records ...*auditrail.KafkaRecord) []error {
+	var errs []error
+
+	for i, r := range records {
+		if err := p.produce(r); err != nil {
+			if errs == nil {
+				errs = make([]error, len(records))
+			}
+
+			errs[i] = err
+		}
+	}
+
+	return errs
+}
+
+func (p *memoryKafkaProducer) Produce(ctx context.Context, record *auditrail.KafkaRecord, promise func(*auditrail.KafkaRecord, error)) {
+	go func() {
+		select {
+		case <-ctx.Done():
+			promise(record, ctx.Err())
+		case <-time.After(10 * time.Millisecond):
+			promise(record, p.produce(record))
+		}
+	}()
+}
+
+func (p *memoryKafkaProducer) produce(record *auditrail.KafkaRecord) error {
+	if p.fail != nil {
+		if err := p.fail(record); err != nil {
+			return err
+		}
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.records = append(p.records, record)
+
+	return nil
+}
+
+func (p *memoryKafkaProducer) Records() []*auditrail.KafkaRecord {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	return append([]*auditrail.KafkaRecord(nil), p.records...)
+}
+
+// heldKafkaProducer is a Kafka producer whose records are not acknowledged
+// until release is closed. If set, received is signaled whenever a
+// synchronous produce starts waiting.
+type heldKafkaProducer struct {
+	memoryKafkaProducer
+	release  chan struct{}
+	received chan struct{}
+}
+
+func (p *heldKafkaProducer) ProduceSync(ctx context.Context, records ...*auditrail.KafkaRecord) []error {
+	if p.received != nil {
+		p.received <- struct{}{}
+	}
+
+	<-p.release
+
+	return p.memoryKafkaProducer.ProduceSync(ctx, records...)
+}
+
+func (p *heldKafkaProducer) Produce(_ context.Context, record *auditrail.KafkaRecord, promise func(*auditrail.KafkaRecord, error)) {
+	go func() {
+		<-p.release
+		promise(record, p.produce(record))
+	}()
+}
@@ -2,7 +2,6 @@ package auditrail
 
 import (
 	"context"
-	"encoding/json"
 	"sync"
 
 	"github.com/aws/aws-sdk-go-v2/aws"
@@ -12,11 +11,22 @@ import (
 // KinesisAPI captures the kinesis client part that we need.
 type KinesisAPI interface {
 	PutRecord(ctx context.Context, params *kinesis.PutRecordInput, optFns ...func(*kinesis.Options)) (*kinesis.PutRecordOutput, error)
+	PutRecords(ctx context.Context, params *kinesis.PutRecordsInput, optFns ...func(*kinesis.Options)) (*kinesis.PutRecordsOutput, error)
 }
 
+// KinesisLoggerOption is a function that configures a Kinesis logger.
+type KinesisLoggerOption func(options *kinesisLogger)
+
 type kinesisLogger struct {
 	client       KinesisAPI
 	streamName   string
+	encoder      Encoder
+	partitionKey KinesisPartitionKeyFunc
+	hashKey      func(*Entry) string
+	oversize     KinesisOversizePolicy
+	aggregate    int
+	attempts     int
+	backoff      ExponentialBackoffConfig
 	closed       bool
 	closeChannel chan struct{}
 	mu           sync.RWMutex
@@ -24,12 +34,34 @@ type kinesisLogger struct {
 
 // NewKinesisLogger builds a new logger that writes log entries to a Kinesis
 // stream as JSON objects separated by newlines.
-func NewKinesisLogger(client KinesisAPI, streamName string) (Logger, error) {
-	return &kinesisLogger{
+//
+// Use [WithKinesisEncoder] to pick a different encoding, binary encodings such
+// as [NewProtobufCodec] produce one undelimited entry per record.
+//
+// Records are partitioned by module unless configured otherwise using
+// [WithKinesisPartitionKey] or [WithKinesisExplicitHashKey]. Entries exceeding
+// the record size limit are rejected with [ErrKinesisRecordTooLarge], see
+// [WithKinesisOversizePolicy].
+func NewKinesisLogger(client KinesisAPI, streamName string, options ...KinesisLoggerOption) (Logger, error) {
+	return newKinesisLogger(client, streamName, options...), nil
+}
+
+func newKinesisLogger(client KinesisAPI, streamName string, options ...KinesisLoggerOption) *kinesisLogger {
+	l := &kinesisLogger{
 		client:       client,
 		streamName:   streamName,
+		encoder:      NewJSONCodec(),
+		partitionKey: KeyByModule,
+		attempts:     3,
+		backoff:      defaultAWSBatchBackoff,
 		closeChannel: make(chan struct{}),
-	}, nil
+	}
+
+	for _, option := range options {
+		option(l)
+	}
+
+	return l
 }
 
 func (l *kinesisLogger) Log(ctx context.Context, entry *Entry) error {
@@ -41,18 +73,28 @@ func (l *kinesisLogger) Log(ctx context.Context, entry *Entry) error {
 		return ErrTrailClosed
 	}
 
-	log, err := json.Marshal(entry)
+	records, err := l.records(entry, 0)
 	if err != nil {
 		return err
 	}
 
-	_, err = l.client.PutRecord(ctx, &kinesis.PutRecordInput{
-		Data:         append(log, '\n'),
-		PartitionKey: aws.String(entry.GetModule()),
-		StreamName:   &l.streamName,
-	})
+	for _, r := range records {
+		input := &kinesis.PutRecordInput{
+			Data:         r.data,
+			PartitionKey: aws.String(r.partitionKey),
+			StreamName:   &l.streamName,
+		}
+
+		if r.explicitHashKey != "" {
+			input.ExplicitHashKey = aws.String(r.explicitHashKey)
+		}
 
-	return err
+		if _, err = l.client.PutRecord(ctx, input); err != nil {
+			return err
+		}
+	}
+
+	return nil
 }
 
 func (l *kinesisLogger) Close() error {
@@ -83,3 +125,15 @@ func (l *kinesisLogger) IsClosed() bool {
 
 	return l.closed
 }
+
+// WithKinesisEncoder sets the encoder used to build the records. If encoder is
+// nil, entries are encoded as JSON.
+func WithKinesisEncoder(encoder Encoder) KinesisLoggerOption {
+	return func(options *kinesisLogger) {
+		if encoder == nil {
+			encoder = NewJSONCodec()
+		}
+
+		options.encoder = encoder
+	}
+}
@@ -0,0 +1,179 @@
+package auditrail
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/kinesis"
+	"github.com/aws/aws-sdk-go-v2/service/kinesis/types"
+)
+
+const (
+	// kinesisMaxRecordsPerCall is the maximum number of records of a
+	// PutRecords request.
+	kinesisMaxRecordsPerCall = 500
+
+	// kinesisMaxBytesPerCall is the maximum size of a PutRecords request,
+	// including data and partition keys.
+	kinesisMaxBytesPerCall = 5 << 20
+
+	// kinesisMaxRecordSize is the maximum size of a record, including its
+	// partition key.
+	kinesisMaxRecordSize = 1 << 20
+)
+
+// kinesisRecord is a record sent to Kinesis, carrying one entry or, when
+// aggregated, many of them.
+type kinesisRecord struct {
+	entries         []int
+	partitionKey    string
+	explicitHashKey string
+	data            []byte
+}
+
+// size returns the size the record counts against the Kinesis limits.
+func (r kinesisRecord) size() int {
+	return len(r.partitionKey) + len(r.data)
+}
+
+// batchEntries returns the indexes of the entries carried by the record.
+func (r kinesisRecord) batchEntries() []int {
+	return r.entries
+}
+
+var _ BatchLogger = (*kinesisBatchLogger)(nil)
+
+type kinesisBatchLogger struct {
+	*kinesisLogger
+}
+
+// NewKinesisBatchLogger builds a new logger that writes entries to a Kinesis
+// stream using the PutRecords API. Combine it with [NewBatcher] or
+// [NewQueue] so entries are sent in batches.
+//
+// Batches are split into requests of up to 500 records and 5 MiB. Records
+// reported as failed by Kinesis are retried on their own, as configured by
+// [WithKinesisRetries]; records still failing afterward are reported per
+// entry with an error wrapping [ErrRetryable].
+//
+// Use [WithKinesisAggregation] to pack many entries into KPL aggregated
+// records.
+func NewKinesisBatchLogger(client KinesisAPI, streamName string, options ...KinesisLoggerOption) (BatchLogger, error) {
+	return &kinesisBatchLogger{kinesisLogger: newKinesisLogger(client, streamName, options...)}, nil
+}
+
+func (l *kinesisBatchLogger) Log(ctx context.Context, entry *Entry) error {
+	if errs := l.LogBatch(ctx, []*Entry{entry}); errs != nil {
+		return errs[0]
+	}
+
+	return nil
+}
+
+func (l *kinesisBatchLogger) LogBatch(ctx context.Context, entries []*Entry) []error {
+	if l.IsClosed() {
+		return batchErrors(len(entries), ErrTrailClosed)
+	}
+
+	var errs []error
+
+	records := make([]kinesisRecord, 0, len(entries))
+
+	for i, entry := range entries {
+		r, err := l.records(entry, i)
+		if err != nil {
+			errs = setBatchError(errs, len(entries), i, err)
+
+			continue
+		}
+
+		records = append(records, r...)
+	}
+
+	if l.aggregate > 0 {
+		records = kplAggregate(records, l.aggregate)
+	}
+
+	sender := awsBatchSender[kinesisRecord]{
+		limits:   awsBatchLimits{items: kinesisMaxRecordsPerCall, bytes: kinesisMaxBytesPerCall},
+		attempts: l.attempts,
+		backoff:  l.backoff,
+		send:     l.putRecords,
+	}
+
+	return sender.sendAll(ctx, records, errs, len(entries))
+}
+
+// putRecords sends the given records in a single PutRecords request,
+// returning the failure of every record.
+func (l *kinesisBatchLogger) putRecords(ctx context.Context, records []kinesisRecord) ([]error, error) {
+	input := &kinesis.PutRecordsInput{
+		StreamName: &l.streamName,
+		Records:    make([]types.PutRecordsRequestEntry, len(records)),
+	}
+
+	for i, r := range records {
+		input.Records[i] = types.PutRecordsRequestEntry{
+			Data:         r.data,
+			PartitionKey: aws.String(r.partitionKey),
+		}
+
+		if r.explicitHashKey != "" {
+			input.Records[i].ExplicitHashKey = aws.String(r.explicitHashKey)
+		}
+	}
+
+	out, err := l.client.PutRecords(ctx, input)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w: could not put kinesis records", ErrRetryable, err)
+	}
+
+	failures := make([]error, len(records))
+
+	for i := range records {
+		switch {
+		case i >= len(out.Records):
+			failures[i] = fmt.Errorf("%w: missing kinesis record result", ErrRetryable)
+		case out.Records[i].ErrorCode != nil:
+			failures[i] = fmt.Errorf("%w: kinesis record failed: %s: %s",
+				ErrRetryable,
+				aws.ToString(out.Records[i].ErrorCode),
+				aws.ToString(out.Records[i].ErrorMessage),
+			)
+		}
+	}
+
+	return failures, nil
+}
+
+// WithKinesisAggregation packs many entries into KPL aggregated records of up
+// to maxSize bytes, which consumers read back using KCL deaggregation. If
+// maxSize is not positive or exceeds the Kinesis limit, records of up to
+// 1 MiB are built. Only used by [NewKinesisBatchLogger].
+//
+// Aggregated records are routed to shards using the partition key of their
+// first entry, so entries of different partition keys may share a shard.
+func WithKinesisAggregation(maxSize int) KinesisLoggerOption {
+	return func(options *kinesisLogger) {
+		if maxSize <= 0 || maxSize > kinesisMaxRecordSize {
+			maxSize = kinesisMaxRecordSize
+		}
+
+		options.aggregate = maxSize
+	}
+}
+
+// WithKinesisRetries sets how many times records reported as failed by a
+// PutRecords request are sent, and the backoff between attempts. Only used by
+// [NewKinesisBatchLogger]. Defaults to 3 attempts.
+func WithKinesisRetries(attempts int, backoff ExponentialBackoffConfig) KinesisLoggerOption {
+	return func(options *kinesisLogger) {
+		if attempts < 1 {
+			attempts = 1
+		}
+
+		options.attempts = attempts
+		options.backoff = backoff
+	}
+}
@@ -0,0 +1,184 @@
+package auditrail
+
+import (
+	"crypto/md5"
+
+	"google.golang.org/protobuf/encoding/protowire"
+)
+
+// kplMagic prefixes KPL aggregated records.
+var kplMagic = []byte{0xF3, 0x89, 0x9A, 0xC2}
+
+// kplAggregate packs the given records into KPL aggregated records of up to
+// maxSize bytes. Records that do not fit on their own are kept as they are.
+func kplAggregate(records []kinesisRecord, maxSize int) []kinesisRecord {
+	out := make([]kinesisRecord, 0, 1)
+	agg := newKPLAggregator()
+
+	for _, r := range records {
+		if agg.fits(r, maxSize) {
+			agg.add(r)
+
+			continue
+		}
+
+		if len(agg.records) > 0 {
+			out = append(out, agg.record())
+			agg = newKPLAggregator()
+		}
+
+		if !agg.fits(r, maxSize) {
+			out = append(out, r)
+
+			continue
+		}
+
+		agg.add(r)
+	}
+
+	if len(agg.records) > 0 {
+		out = append(out, agg.record())
+	}
+
+	return out
+}
+
+// kplAggregator builds an AggregatedRecord message of the KPL aggregation
+// format:
+//
+//	message AggregatedRecord {
+//	  repeated string partition_key_table = 1;
+//	  repeated string explicit_hash_key_table = 2;
+//	  repeated Record records = 3;
+//	}
+//
+//	message Record {
+//	  required uint64 partition_key_index = 1;
+//	  optional uint64 explicit_hash_key_index = 2;
+//	  required bytes data = 3;
+//	}
+//
+// The aggregated record is the magic number followed by the message and its
+// MD5 digest.
+type kplAggregator struct {
+	keys      []string
+	index     map[string]uint64
+	hashKeys  []string
+	hashIndex map[string]uint64
+	records   []kinesisRecord
+	size      int
+}
+
+func newKPLAggregator() *kplAggregator {
+	return &kplAggregator{
+		index:     make(map[string]uint64),
+		hashIndex: make(map[string]uint64),
+	}
+}
+
+// grow returns how many bytes the message grows when adding the given record.
+func (a *kplAggregator) grow(r kinesisRecord) int {
+	n := 0
+
+	idx, ok := a.index[r.partitionKey]
+	if !ok {
+		idx = uint64(len(a.keys))
+		n += protowire.SizeTag(1) + protowire.SizeBytes(len(r.partitionKey))
+	}
+
+	inner := protowire.SizeTag(1) + protowire.SizeVarint(idx) + protowire.SizeTag(3) + protowire.SizeBytes(len(r.data))
+
+	if r.explicitHashKey != "" {
+		hashIdx, ok := a.hashIndex[r.explicitHashKey]
+		if !ok {
+			hashIdx = uint64(len(a.hashKeys))
+			n += protowire.SizeTag(2) + protowire.SizeBytes(len(r.explicitHashKey))
+		}
+
+		inner += protowire.SizeTag(2) + protowire.SizeVarint(hashIdx)
+	}
+
+	return n + protowire.SizeTag(3) + protowire.SizeBytes(inner)
+}
+
+// fits reports whether the aggregated record stays within maxSize bytes when
+// adding the given record.
+func (a *kplAggregator) fits(r kinesisRecord, maxSize int) bool {
+	key := r.partitionKey
+	if len(a.records) > 0 {
+		key = a.records[0].partitionKey
+	}
+
+	return len(key)+len(kplMagic)+a.size+a.grow(r)+md5.Size <= maxSize
+}
+
+func (a *kplAggregator) add(r kinesisRecord) {
+	a.size += a.grow(r)
+
+	if _, ok := a.index[r.partitionKey]; !ok {
+		a.index[r.partitionKey] = uint64(len(a.keys))
+		a.keys = append(a.keys, r.partitionKey)
+	}
+
+	if _, ok := a.hashIndex[r.explicitHashKey]; r.explicitHashKey != "" && !ok {
+		a.hashIndex[r.explicitHashKey] = uint64(len(a.hashKeys))
+		a.hashKeys = append(a.hashKeys, r.explicitHashKey)
+	}
+
+	a.records = append(a.records, r)
+}
+
+// record returns the aggregated record. A single record is not aggregated.
+func (a *kplAggregator) record() kinesisRecord {
+	if len(a.records) == 1 {
+		return a.records[0]
+	}
+
+	msg := make([]byte, 0, a.size)
+
+	for _, key := range a.keys {
+		msg = protowire.AppendTag(msg, 1, protowire.BytesType)
+		msg = protowire.AppendString(msg, key)
+	}
+
+	for _, key := range a.hashKeys {
+		msg = protowire.AppendTag(msg, 2, protowire.BytesType)
+		msg = protowire.AppendString(msg, key)
+	}
+
+	entries := make([]int, 0, len(a.records))
+
+	for _, r := range a.records {
+		var inner []byte
+
+		inner = protowire.AppendTag(inner, 1, protowire.VarintType)
+		inner = protowire.AppendVarint(inner, a.index[r.partitionKey])
+
+		if r.explicitHashKey != "" {
+			inner = protowire.AppendTag(inner, 2, protowire.VarintType)
+			inner = protowire.AppendVarint(inner, a.hashIndex[r.explicitHashKey])
+		}
+
+		inner = protowire.AppendTag(inner, 3, protowire.BytesType)
+		inner = protowire.AppendBytes(inner, r.data)
+
+		msg = protowire.AppendTag(msg, 3, protowire.BytesType)
+		msg = protowire.AppendBytes(msg, inner)
+
+		entries = append(entries, r.entries...)
+	}
+
+	sum := md5.Sum(msg)
+
+	data := make([]byte, 0, len(kplMagic)+len(msg)+len(sum))
+	data = append(data, kplMagic...)
+	data = append(data, msg...)
+	data = append(data, sum[:]...)
+
+	return kinesisRecord{
+		entries:         entries,
+		partitionKey:    a.records[0].partitionKey,
+		explicitHashKey: a.records[0].explicitHashKey,
+		data:            data,
+	}
+}
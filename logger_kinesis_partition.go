@@ -0,0 +1,185 @@
+package auditrail
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"unicode/utf8"
+)
+
+// kinesisMaxPartitionKeyLength is the maximum length, in characters, of a
+// partition key.
+const kinesisMaxPartitionKeyLength = 256
+
+// ErrKinesisRecordTooLarge is wrapped by the errors of entries that do not fit
+// in a Kinesis record, which is limited to 1 MiB including its partition key.
+var ErrKinesisRecordTooLarge = errors.New("kinesis record exceeds the 1 MiB limit")
+
+// KinesisPartitionKeyFunc returns the partition key of the record of an entry,
+// which determines the shard the record is written to.
+type KinesisPartitionKeyFunc = KeyFunc
+
+// KinesisPartitionByModule is an alias of [KeyByModule]. It is the default
+// strategy.
+func KinesisPartitionByModule(entry *Entry) string {
+	return KeyByModule(entry)
+}
+
+// KinesisPartitionByActor is an alias of [KeyByActor].
+func KinesisPartitionByActor(entry *Entry) string {
+	return KeyByActor(entry)
+}
+
+// KinesisPartitionByCorrelationID is an alias of [KeyByCorrelationID].
+func KinesisPartitionByCorrelationID(entry *Entry) string {
+	return KeyByCorrelationID(entry)
+}
+
+// KinesisPartitionByHashedIdempotencyID is an alias of
+// [KeyByHashedIdempotencyID].
+func KinesisPartitionByHashedIdempotencyID(entry *Entry) string {
+	return KeyByHashedIdempotencyID(entry)
+}
+
+// KinesisOversizePolicy defines how entries exceeding the record size limit
+// are handled.
+type KinesisOversizePolicy int
+
+const (
+	// KinesisRejectOversized rejects entries exceeding the record size limit
+	// with an error wrapping [ErrKinesisRecordTooLarge] and [ErrPermanent].
+	KinesisRejectOversized KinesisOversizePolicy = iota
+
+	// KinesisSplitOversized splits entries exceeding the record size limit
+	// into many records, each one holding a [KinesisChunk].
+	KinesisSplitOversized
+)
+
+// KinesisChunk is the JSON object written, followed by a newline, in each of
+// the records of an entry split by [KinesisSplitOversized]. Consumers
+// reassemble the encoded entry by concatenating the data of the chunks with
+// the same ID, sorted by index. Chunks of a failed entry may be written
+// again, so consumers must expect duplicates.
+type KinesisChunk struct {
+	// ID is the idempotency ID of the entry.
+	ID string `json:"chunk_id"`
+
+	// Index of the chunk, starting at zero.
+	Index int `json:"chunk_index"`
+
+	// Count is the number of chunks of the entry.
+	Count int `json:"chunk_count"`
+
+	// Data is a part of the encoded entry.
+	Data []byte `json:"data"`
+}
+
+// records returns the records of the given entry, which is the i-th entry of
+// its batch.
+func (l *kinesisLogger) records(entry *Entry, i int) ([]kinesisRecord, error) {
+	key := l.partitionKey(entry)
+	if key == "" || utf8.RuneCountInString(key) > kinesisMaxPartitionKeyLength {
+		return nil, fmt.Errorf("%w: invalid kinesis partition key %q, it must have between 1 and %d characters",
+			ErrPermanent, key, kinesisMaxPartitionKeyLength)
+	}
+
+	r := kinesisRecord{entries: []int{i}, partitionKey: key}
+
+	if l.hashKey != nil {
+		r.explicitHashKey = l.hashKey(entry)
+
+		if !validKinesisHashKey(r.explicitHashKey) {
+			return nil, fmt.Errorf("%w: invalid kinesis explicit hash key %q, it must be a 128-bit decimal integer",
+				ErrPermanent, r.explicitHashKey)
+		}
+	}
+
+	data, err := encodeRecord(l.encoder, entry)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w: could not encode entry", ErrPermanent, err)
+	}
+
+	r.data = data
+
+	if r.size() <= kinesisMaxRecordSize {
+		return []kinesisRecord{r}, nil
+	}
+
+	if l.oversize != KinesisSplitOversized {
+		return nil, fmt.Errorf("%w: %w: entry %s takes %d bytes",
+			ErrPermanent, ErrKinesisRecordTooLarge, entry.GetIdempotencyID(), r.size())
+	}
+
+	return splitKinesisRecord(r, entry.GetIdempotencyID())
+}
+
+// splitKinesisRecord splits the given record into records holding chunks of
+// its data.
+func splitKinesisRecord(r kinesisRecord, id string) ([]kinesisRecord, error) {
+	// room for the fields of the chunk, base64 taking 4 bytes every 3.
+	room := (kinesisMaxRecordSize - len(r.partitionKey) - len(id) - 128) / 4 * 3
+	count := (len(r.data) + room - 1) / room
+	records := make([]kinesisRecord, 0, count)
+
+	for i := 0; i < count; i++ {
+		end := min((i+1)*room, len(r.data))
+
+		chunk, err := json.Marshal(KinesisChunk{ID: id, Index: i, Count: count, Data: r.data[i*room : end]})
+		if err != nil {
+			return nil, fmt.Errorf("%w: %w: could not encode chunk", ErrPermanent, err)
+		}
+
+		records = append(records, kinesisRecord{
+			entries:         r.entries,
+			partitionKey:    r.partitionKey,
+			explicitHashKey: r.explicitHashKey,
+			data:            append(chunk, '\n'),
+		})
+	}
+
+	return records, nil
+}
+
+// validKinesisHashKey reports whether the given explicit hash key is valid.
+// Empty keys are valid, the partition key is hashed instead.
+func validKinesisHashKey(key string) bool {
+	if key == "" {
+		return true
+	}
+
+	n, ok := new(big.Int).SetString(key, 10)
+
+	return ok && n.Sign() >= 0 && n.BitLen() <= 128
+}
+
+// WithKinesisPartitionKey sets the strategy choosing the partition key of
+// records, such as [KeyByActor] or a custom function. If fn is
+// nil, records are partitioned by module.
+func WithKinesisPartitionKey(fn KinesisPartitionKeyFunc) KinesisLoggerOption {
+	return func(options *kinesisLogger) {
+		if fn == nil {
+			fn = KeyByModule
+		}
+
+		options.partitionKey = fn
+	}
+}
+
+// WithKinesisExplicitHashKey sets a function returning the explicit hash key of
+// the record of an entry, a 128-bit decimal integer routing the record to the
+// shard owning it instead of the hash of its partition key. Entries for which
+// fn returns an empty string are routed by partition key.
+func WithKinesisExplicitHashKey(fn func(*Entry) string) KinesisLoggerOption {
+	return func(options *kinesisLogger) {
+		options.hashKey = fn
+	}
+}
+
+// WithKinesisOversizePolicy sets how entries exceeding the record size limit
+// are handled. Defaults to [KinesisRejectOversized].
+func WithKinesisOversizePolicy(policy KinesisOversizePolicy) KinesisLoggerOption {
+	return func(options *kinesisLogger) {
+		options.oversize = policy
+	}
+}
@@ -1,15 +1,21 @@
 package auditrail_test
 
 import (
+	"bytes"
 	"context"
+	"crypto/md5"
 	"encoding/json"
+	"strings"
 	"testing"
 	"time"
 
+	"github.com/aws/aws-sdk-go-v2/aws"
 	"github.com/aws/aws-sdk-go-v2/service/kinesis"
+	"github.com/aws/aws-sdk-go-v2/service/kinesis/types"
 	"github.com/botchris/go-auditrail"
 	"github.com/brianvoe/gofakeit/v6"
 	"github.com/stretchr/testify/require"
+	"google.golang.org/protobuf/encoding/protowire"
 )
 
 func TestKinesisLogger(t *testing.T) {
@@ -52,8 +58,339 @@ func TestKinesisLogger(t *testing.T) {
 	})
 }
 
+func TestKinesisBatchLogger(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	fastRetries := auditrail.WithKinesisRetries(3, auditrail.ExponentialBackoffConfig{
+		Base:   time.Millisecond,
+		Factor: time.Millisecond,
+		Max:    time.Millisecond,
+	})
+
+	t.Run("GIVEN a batch of entries WHEN logging them THEN they are sent in a single PutRecords request", func(t *testing.T) {
+		api := &mockKinesisAPI{}
+		logger, err := auditrail.NewKinesisBatchLogger(api, "audit")
+		require.NoError(t, err)
+
+		entries := []*auditrail.Entry{newFakeEntry(), newFakeEntry(), newFakeEntry()}
+
+		require.Nil(t, logger.LogBatch(ctx, entries))
+		require.Empty(t, api.putCalls)
+		require.Len(t, api.putRecordsCalls, 1)
+		require.Equal(t, "audit", *api.putRecordsCalls[0].StreamName)
+		require.Len(t, api.putRecordsCalls[0].Records, 3)
+
+		for i, r := range api.putRecordsCalls[0].Records {
+			require.Equal(t, entries[i].GetModule(), *r.PartitionKey)
+			require.True(t, json.Valid(r.Data))
+		}
+	})
+
+	t.Run("GIVEN a large batch WHEN logging it THEN requests are limited to 500 records and 5 MiB", func(t *testing.T) {
+		api := &mockKinesisAPI{}
+		logger, err := auditrail.NewKinesisBatchLogger(api, "audit")
+		require.NoError(t, err)
+
+		entries := make([]*auditrail.Entry, 1200)
+		for i := range entries {
+			entries[i] = newFakeEntry()
+		}
+
+		require.Nil(t, logger.LogBatch(ctx, entries))
+		require.Len(t, api.putRecordsCalls, 3)
+		require.Len(t, api.putRecordsCalls[0].Records, 500)
+		require.Len(t, api.putRecordsCalls[1].Records, 500)
+		require.Len(t, api.putRecordsCalls[2].Records, 200)
+
+		api.putRecordsCalls = nil
+		entries = make([]*auditrail.Entry, 20)
+
+		for i := range entries {
+			entries[i] = newFakeEntry().AppendDetails("blob", strings.Repeat("x", 500_000))
+		}
+
+		require.Nil(t, logger.LogBatch(ctx, entries))
+		require.Len(t, api.putRecordsCalls, 2)
+
+		for _, call := range api.putRecordsCalls {
+			size := 0
+			for _, r := range call.Records {
+				size += len(r.Data) + len(*r.PartitionKey)
+			}
+
+			require.LessOrEqual(t, size, 5<<20)
+		}
+	})
+
+	t.Run("GIVEN records failing once WHEN logging THEN only the failed records are retried", func(t *testing.T) {
+		throttled := newFakeEntry()
+		attempts := 0
+
+		api := &mockKinesisAPI{}
+		api.fail = func(r types.PutRecordsRequestEntry) *string {
+			if !strings.Contains(string(r.Data), throttled.GetIdempotencyID()) {
+				return nil
+			}
+
+			if attempts++; attempts > 1 {
+				return nil
+			}
+
+			return aws.String("ProvisionedThroughputExceededException")
+		}
+
+		logger, err := auditrail.NewKinesisBatchLogger(api, "audit", fastRetries)
+		require.NoError(t, err)
+
+		require.Nil(t, logger.LogBatch(ctx, []*auditrail.Entry{newFakeEntry(), throttled, newFakeEntry()}))
+		require.Len(t, api.putRecordsCalls, 2)
+		require.Len(t, api.putRecordsCalls[1].Records, 1)
+		require.Contains(t, string(api.putRecordsCalls[1].Records[0].Data), throttled.GetIdempotencyID())
+	})
+
+	t.Run("GIVEN records failing on every attempt WHEN logging THEN a retryable error is reported per entry", func(t *testing.T) {
+		api := &mockKinesisAPI{}
+		api.fail = func(r types.PutRecordsRequestEntry) *string {
+			if strings.Contains(string(r.Data), `"actor":"throttled"`) {
+				return aws.String("InternalFailure")
+			}
+
+			return nil
+		}
+
+		logger, err := auditrail.NewKinesisBatchLogger(api, "audit", fastRetries)
+		require.NoError(t, err)
+
+		errs := logger.LogBatch(ctx, []*auditrail.Entry{newFakeEntry(), auditrail.NewEntry("throttled", "order_create", "orders")})
+		require.Len(t, errs, 2)
+		require.NoError(t, errs[0])
+		require.ErrorIs(t, errs[1], auditrail.ErrRetryable)
+		require.ErrorContains(t, errs[1], "InternalFailure")
+		require.Len(t, api.putRecordsCalls, 3)
+	})
+
+	t.Run("GIVEN aggregation WHEN logging a batch THEN entries are packed into KPL aggregated records", func(t *testing.T) {
+		api := &mockKinesisAPI{}
+		logger, err := auditrail.NewKinesisBatchLogger(api, "audit", auditrail.WithKinesisAggregation(0))
+		require.NoError(t, err)
+
+		entries := make([]*auditrail.Entry, 10)
+		for i := range entries {
+			entries[i] = auditrail.NewEntry(gofakeit.Username(), "order_create", []string{"orders", "billing"}[i%2])
+		}
+
+		require.Nil(t, logger.LogBatch(ctx, entries))
+		require.Len(t, api.putRecordsCalls, 1)
+		require.Len(t, api.putRecordsCalls[0].Records, 1)
+
+		keys, records := deaggregateKPL(t, api.putRecordsCalls[0].Records[0].Data)
+		require.Equal(t, []string{"orders", "billing"}, keys)
+		require.Len(t, records, 10)
+
+		for i, r := range records {
+			var entry auditrail.Entry
+
+			require.NoError(t, json.Unmarshal(r, &entry))
+			require.Equal(t, entries[i].GetIdempotencyID(), entry.GetIdempotencyID())
+		}
+
+		t.Run("AND a maximum aggregated size THEN entries are spread over many records", func(t *testing.T) {
+			api.putRecordsCalls = nil
+
+			logger, err := auditrail.NewKinesisBatchLogger(api, "audit", auditrail.WithKinesisAggregation(1500))
+			require.NoError(t, err)
+
+			require.Nil(t, logger.LogBatch(ctx, entries))
+			require.Len(t, api.putRecordsCalls, 1)
+			require.Greater(t, len(api.putRecordsCalls[0].Records), 1)
+
+			total := 0
+
+			for _, r := range api.putRecordsCalls[0].Records {
+				require.LessOrEqual(t, len(r.Data)+len(*r.PartitionKey), 1500)
+
+				_, records := deaggregateKPL(t, r.Data)
+				total += len(records)
+			}
+
+			require.Equal(t, 10, total)
+		})
+	})
+}
+
+func TestKinesisPartitioning(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	entry := auditrail.NewEntry("john", "order_create", "orders")
+
+	t.Run("GIVEN partition key strategies WHEN logging an entry THEN the record uses the chosen partition key", func(t *testing.T) {
+		correlated := auditrail.NewEntry("john", "order_create", "orders").WithCorrelation("flow-1")
+
+		for _, tc := range []struct {
+			strategy auditrail.KinesisPartitionKeyFunc
+			entry    *auditrail.Entry
+			expected string
+		}{
+			{strategy: nil, entry: entry, expected: "orders"},
+			{strategy: auditrail.KinesisPartitionByActor, entry: entry, expected: "john"},
+			{strategy: auditrail.KinesisPartitionByCorrelationID, entry: correlated, expected: "flow-1"},
+			{strategy: auditrail.KinesisPartitionByCorrelationID, entry: entry, expected: auditrail.KinesisPartitionByHashedIdempotencyID(entry)},
+			{strategy: func(e *auditrail.Entry) string { return e.GetAction() }, entry: entry, expected: "order_create"},
+		} {
+			api := &mockKinesisAPI{}
+			logger, err := auditrail.NewKinesisLogger(api, "audit", auditrail.WithKinesisPartitionKey(tc.strategy))
+			require.NoError(t, err)
+
+			require.NoError(t, logger.Log(ctx, tc.entry))
+			require.Len(t, api.putCalls, 1)
+			require.Equal(t, tc.expected, *api.putCalls[0].PartitionKey)
+			require.Nil(t, api.putCalls[0].ExplicitHashKey)
+		}
+
+		require.Regexp(t, "^[0-9a-f]{32}$", auditrail.KinesisPartitionByHashedIdempotencyID(entry))
+	})
+
+	t.Run("GIVEN an invalid partition key WHEN logging THEN the entry is rejected", func(t *testing.T) {
+		api := &mockKinesisAPI{}
+		logger, err := auditrail.NewKinesisLogger(api, "audit", auditrail.WithKinesisPartitionKey(func(*auditrail.Entry) string { return "" }))
+		require.NoError(t, err)
+
+		require.ErrorIs(t, logger.Log(ctx, entry), auditrail.ErrPermanent)
+		require.Empty(t, api.putCalls)
+	})
+
+	t.Run("GIVEN an explicit hash key WHEN logging entries THEN records are routed with it", func(t *testing.T) {
+		api := &mockKinesisAPI{}
+		logger, err := auditrail.NewKinesisBatchLogger(api, "audit", auditrail.WithKinesisExplicitHashKey(func(e *auditrail.Entry) string {
+			if e.GetActor() == "invalid" {
+				return "-1"
+			}
+
+			return "170141183460469231731687303715884105728"
+		}))
+		require.NoError(t, err)
+
+		errs := logger.LogBatch(ctx, []*auditrail.Entry{entry, auditrail.NewEntry("invalid", "order_create", "orders")})
+		require.Len(t, errs, 2)
+		require.NoError(t, errs[0])
+		require.ErrorIs(t, errs[1], auditrail.ErrPermanent)
+
+		require.Len(t, api.putRecordsCalls, 1)
+		require.Len(t, api.putRecordsCalls[0].Records, 1)
+		require.Equal(t, "170141183460469231731687303715884105728", *api.putRecordsCalls[0].Records[0].ExplicitHashKey)
+	})
+
+	large := auditrail.NewEntry("john", "document_upload", "documents").
+		AppendDetails("blob", strings.Repeat("x", 1_500_000))
+
+	t.Run("GIVEN an entry exceeding the record size limit WHEN logging it THEN it is rejected with a clear error", func(t *testing.T) {
+		api := &mockKinesisAPI{}
+		logger, err := auditrail.NewKinesisLogger(api, "audit")
+		require.NoError(t, err)
+
+		err = logger.Log(ctx, large)
+		require.ErrorIs(t, err, auditrail.ErrKinesisRecordTooLarge)
+		require.ErrorIs(t, err, auditrail.ErrPermanent)
+		require.ErrorContains(t, err, large.GetIdempotencyID())
+		require.Empty(t, api.putCalls)
+	})
+
+	t.Run("GIVEN the split policy WHEN logging an entry exceeding the record size limit THEN it is written in chunks", func(t *testing.T) {
+		api := &mockKinesisAPI{}
+		logger, err := auditrail.NewKinesisBatchLogger(api, "audit",
+			auditrail.WithKinesisOversizePolicy(auditrail.KinesisSplitOversized),
+		)
+		require.NoError(t, err)
+
+		require.Nil(t, logger.LogBatch(ctx, []*auditrail.Entry{large, entry}))
+		require.Len(t, api.putRecordsCalls, 1)
+
+		records := api.putRecordsCalls[0].Records
+		require.Len(t, records, 3)
+
+		var data []byte
+
+		for i, r := range records[:2] {
+			require.LessOrEqual(t, len(r.Data)+len(*r.PartitionKey), 1<<20)
+			require.Equal(t, "documents", *r.PartitionKey)
+
+			var chunk auditrail.KinesisChunk
+
+			require.NoError(t, json.Unmarshal(r.Data, &chunk))
+			require.Equal(t, large.GetIdempotencyID(), chunk.ID)
+			require.Equal(t, i, chunk.Index)
+			require.Equal(t, 2, chunk.Count)
+
+			data = append(data, chunk.Data...)
+		}
+
+		var reassembled auditrail.Entry
+
+		require.NoError(t, json.Unmarshal(data, &reassembled))
+		require.Equal(t, large.GetIdempotencyID(), reassembled.GetIdempotencyID())
+		require.True(t, json.Valid(records[2].Data))
+	})
+}
+
+// deaggregateKPL decodes a KPL aggregated record, returning its partition key
+// table and the data of its records.
+func deaggregateKPL(t *testing.T, data []byte) ([]string, [][]byte) {
+	t.Helper()
+
+	require.True(t, bytes.HasPrefix(data, []byte{0xF3, 0x89, 0x9A, 0xC2}), "missing KPL magic number")
+
+	msg := data[4 : len(data)-md5.Size]
+	sum := md5.Sum(msg)
+	require.Equal(t, sum[:], data[len(data)-md5.Size:])
+
+	var (
+		keys    []string
+		records [][]byte
+	)
+
+	for len(msg) > 0 {
+		num, typ, n := protowire.ConsumeTag(msg)
+		require.GreaterOrEqual(t, n, 0)
+		require.Equal(t, protowire.BytesType, typ)
+		msg = msg[n:]
+
+		value, n := protowire.ConsumeBytes(msg)
+		require.GreaterOrEqual(t, n, 0)
+		msg = msg[n:]
+
+		switch num {
+		case 1:
+			keys = append(keys, string(value))
+		case 3:
+			for len(value) > 0 {
+				num, typ, n := protowire.ConsumeTag(value)
+				require.GreaterOrEqual(t, n, 0)
+				value = value[n:]
+
+				n = protowire.ConsumeFieldValue(num, typ, value)
+				require.GreaterOrEqual(t, n, 0)
+
+				if num == 3 {
+					record, _ := protowire.ConsumeBytes(value)
+					records = append(records, record)
+				}
+
+				value = value[n:]
+			}
+		}
+	}
+
+	return keys, records
+}
+
 type mockKinesisAPI struct {
-	putCalls []*kinesis.PutRecordInput
+	putCalls        []*kinesis.PutRecordInput
+	putRecordsCalls []*kinesis.PutRecordsInput
+
+	// fail returns the error code of records to be reported as failed.
+	fail func(types.PutRecordsRequestEntry) *string
 }
 
 func (m *mockKinesisAPI) PutRecord(_ context.Context, params *kinesis.PutRecordInput, _ ...func(*kinesis.Options)) (*kinesis.PutRecordOutput, error) {
@@ -61,3 +398,28 @@ func (m *mockKinesisAPI) PutRecord(_ context.Context, params *kinesis.PutRecordI
 
 	return &kinesis.PutRecordOutput{}, nil
 }
+
+func (m *mockKinesisAPI) PutRecords(_ context.Context, params *kinesis.PutRecordsInput, _ ...func(*kinesis.Options)) (*kinesis.PutRecordsOutput, error) {
+	m.putRecordsCalls = append(m.putRecordsCalls, params)
+
+	out := &kinesis.PutRecordsOutput{FailedRecordCount: aws.Int32(0)}
+
+	for _, r := range params.Records {
+		var code *string
+
+		if m.fail != nil {
+			code = m.fail(r)
+		}
+
+		if code != nil {
+			*out.FailedRecordCount++
+			out.Records = append(out.Records, types.PutRecordsResultEntry{ErrorCode: code, ErrorMessage: aws.String("rate exceeded")})
+
+			continue
+		}
+
+		out.Records = append(out.Records, types.PutRecordsResultEntry{SequenceNumber: aws.String("1"), ShardId: aws.String("shardId-000000000000")})
+	}
+
+	return out, nil
+}
@@ -0,0 +1,194 @@
+package auditrail
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+
+	"github.com/nats-io/nats.go"
+	"github.com/nats-io/nats.go/jetstream"
+)
+
+// DefaultJetStreamSubject is the default subject template of the JetStream
+// logger.
+const DefaultJetStreamSubject = "audit.{module}.{action}"
+
+// jetStreamErrCodeStreamNotMatch is the code of the API error returned when
+// the stream of a subject is not the expected one.
+const jetStreamErrCodeStreamNotMatch jetstream.ErrorCode = 10060
+
+// JetStreamPublisher captures the JetStream client part that we need. It is
+// implemented by [jetstream.JetStream].
+type JetStreamPublisher interface {
+	PublishMsg(ctx context.Context, msg *nats.Msg, opts ...jetstream.PublishOpt) (*jetstream.PubAck, error)
+}
+
+// JetStreamLoggerOption is a function that configures a JetStream logger.
+type JetStreamLoggerOption func(options *jetStreamLogger)
+
+type jetStreamLogger struct {
+	js           JetStreamPublisher
+	subject      string
+	encoder      Encoder
+	stream       string
+	closed       bool
+	closeChannel chan struct{}
+	mu           sync.RWMutex
+}
+
+// NewJetStreamLogger builds a new logger that publishes entries as JSON
+// objects to NATS JetStream, waiting for the acknowledgement of the stream.
+//
+// The subject of each entry is the result of expanding the given template,
+// which supports the placeholders {module}, {action} and {actor}. Dots,
+// spaces and wildcards of the replaced values are turned into underscores so
+// each one stays a single token. If subject is empty, [DefaultJetStreamSubject]
+// is used.
+//
+// Messages carry the idempotency ID of entries in the Nats-Msg-Id header, so
+// JetStream discards publishes retried within the duplicate window of the
+// stream. Publish errors wrap [ErrRetryable], except the ones caused by a
+// misconfiguration, such as a subject not bound to any stream or bound to a
+// stream other than the expected one, which wrap [ErrPermanent].
+func NewJetStreamLogger(js JetStreamPublisher, subject string, options ...JetStreamLoggerOption) Logger {
+	if subject == "" {
+		subject = DefaultJetStreamSubject
+	}
+
+	l := &jetStreamLogger{
+		js:           js,
+		subject:      subject,
+		encoder:      NewJSONCodec(),
+		closeChannel: make(chan struct{}),
+	}
+
+	for _, option := range options {
+		option(l)
+	}
+
+	return l
+}
+
+func (l *jetStreamLogger) Log(ctx context.Context, entry *Entry) error {
+	if l.IsClosed() {
+		return ErrTrailClosed
+	}
+
+	data, err := l.encoder.Encode(entry)
+	if err != nil {
+		return fmt.Errorf("%w: %w: could not encode entry", ErrPermanent, err)
+	}
+
+	msg := nats.NewMsg(expandJetStreamSubject(l.subject, entry))
+	msg.Data = data
+	msg.Header.Set(jetstream.MsgIDHeader, entry.GetIdempotencyID())
+
+	if typed, ok := l.encoder.(interface{ ContentType() string }); ok {
+		msg.Header.Set("Content-Type", typed.ContentType())
+	}
+
+	var opts []jetstream.PublishOpt
+
+	if l.stream != "" {
+		opts = append(opts, jetstream.WithExpectStream(l.stream))
+	}
+
+	if _, err = l.js.PublishMsg(ctx, msg, opts...); err != nil {
+		if jetStreamMisconfigured(err) {
+			return fmt.Errorf("%w: %w: could not publish entry to %s", ErrPermanent, err, msg.Subject)
+		}
+
+		return fmt.Errorf("%w: %w: could not publish entry to %s", ErrRetryable, err, msg.Subject)
+	}
+
+	return nil
+}
+
+// jetStreamMisconfigured reports whether the given publish error is caused by
+// a stream that does not exist or does not match the expected one, which
+// retrying cannot fix.
+func jetStreamMisconfigured(err error) bool {
+	if errors.Is(err, jetstream.ErrNoStreamResponse) || errors.Is(err, jetstream.ErrStreamNotFound) {
+		return true
+	}
+
+	var apiErr *jetstream.APIError
+	if !errors.As(err, &apiErr) {
+		return false
+	}
+
+	return apiErr.ErrorCode == jetstream.JSErrCodeStreamNotFound || apiErr.ErrorCode == jetStreamErrCodeStreamNotMatch
+}
+
+func (l *jetStreamLogger) Close() error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if l.closed {
+		return nil
+	}
+
+	l.closed = true
+
+	close(l.closeChannel)
+
+	return nil
+}
+
+func (l *jetStreamLogger) Closed() <-chan struct{} {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+
+	return l.closeChannel
+}
+
+func (l *jetStreamLogger) IsClosed() bool {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+
+	return l.closed
+}
+
+// jetStreamTokenReplacer replaces the characters that are not allowed within
+// a subject token.
+var jetStreamTokenReplacer = strings.NewReplacer(".", "_", " ", "_", "\t", "_", "*", "_", ">", "_")
+
+// expandJetStreamSubject expands the placeholders of the given subject
+// template.
+func expandJetStreamSubject(template string, entry *Entry) string {
+	token := func(value string) string {
+		if value == "" {
+			return "_"
+		}
+
+		return jetStreamTokenReplacer.Replace(value)
+	}
+
+	return strings.NewReplacer(
+		"{module}", token(entry.GetModule()),
+		"{action}", token(entry.GetAction()),
+		"{actor}", token(entry.GetActor()),
+	).Replace(template)
+}
+
+// WithJetStreamEncoder sets the encoder used to build the messages. If encoder
+// is nil, entries are encoded as JSON.
+func WithJetStreamEncoder(encoder Encoder) JetStreamLoggerOption {
+	return func(options *jetStreamLogger) {
+		if encoder == nil {
+			encoder = NewJSONCodec()
+		}
+
+		options.encoder = encoder
+	}
+}
+
+// WithJetStreamExpectStream makes JetStream reject entries whose subject is
+// not bound to the given stream, with an error wrapping [ErrPermanent].
+func WithJetStreamExpectStream(stream string) JetStreamLoggerOption {
+	return func(options *jetStreamLogger) {
+		options.stream = stream
+	}
+}
@@ -0,0 +1,173 @@
+package auditrail_test
+
+import (
+	"context"
+	"encoding/json"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/botchris/go-auditrail"
+	"github.com/nats-io/nats-server/v2/test"
+	"github.com/nats-io/nats.go"
+	"github.com/nats-io/nats.go/jetstream"
+	"github.com/stretchr/testify/require"
+)
+
+func TestJetStreamLogger(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	t.Run("GIVEN a jetstream logger WHEN logging an entry THEN it is published to its templated subject", func(t *testing.T) {
+		js, stream := newJetStream(t, ctx)
+		logger := auditrail.NewJetStreamLogger(js, "")
+		defer checkClose(t, ctx, logger)
+
+		entry := auditrail.NewEntry("john", "order.create", "orders")
+		require.NoError(t, logger.Log(ctx, entry))
+
+		msg, err := stream.GetMsg(ctx, 1)
+		require.NoError(t, err)
+		require.Equal(t, "audit.orders.order_create", msg.Subject)
+		require.Equal(t, entry.GetIdempotencyID(), msg.Header.Get(jetstream.MsgIDHeader))
+		require.Equal(t, "application/json", msg.Header.Get("Content-Type"))
+		require.True(t, json.Valid(msg.Data))
+	})
+
+	t.Run("GIVEN a custom subject template WHEN logging an entry THEN wildcards and spaces are escaped", func(t *testing.T) {
+		js, stream := newJetStream(t, ctx)
+		logger := auditrail.NewJetStreamLogger(js, "tenants.{actor}.{module}", auditrail.WithJetStreamExpectStream("AUDIT"))
+		defer checkClose(t, ctx, logger)
+
+		require.NoError(t, logger.Log(ctx, auditrail.NewEntry("acme *corp", "order_create", "orders>eu")))
+
+		msg, err := stream.GetMsg(ctx, 1)
+		require.NoError(t, err)
+		require.Equal(t, "tenants.acme__corp.orders_eu", msg.Subject)
+	})
+
+	t.Run("GIVEN a retried entry WHEN publishing it again THEN jetstream de-duplicates it", func(t *testing.T) {
+		js, stream := newJetStream(t, ctx)
+		logger := auditrail.NewJetStreamLogger(js, "")
+		defer checkClose(t, ctx, logger)
+
+		entry := newFakeEntry()
+		require.NoError(t, logger.Log(ctx, entry))
+		require.NoError(t, logger.Log(ctx, entry))
+		require.NoError(t, logger.Log(ctx, newFakeEntry()))
+
+		info, err := stream.Info(ctx)
+		require.NoError(t, err)
+		require.EqualValues(t, 2, info.State.Msgs)
+	})
+
+	t.Run("GIVEN a publish without ack WHEN logging THEN a retryable error is returned", func(t *testing.T) {
+		js, stream := newJetStream(t, ctx)
+		unacked := &unackedJetStream{JetStreamPublisher: js, err: nats.ErrTimeout}
+
+		logger := auditrail.NewJetStreamLogger(unacked, "")
+		defer checkClose(t, ctx, logger)
+
+		err := logger.Log(ctx, newFakeEntry())
+		require.ErrorIs(t, err, auditrail.ErrRetryable)
+		require.ErrorIs(t, err, nats.ErrTimeout)
+
+		t.Run("AND a retryer THEN the entry is published once the stream acks it", func(t *testing.T) {
+			retryer := auditrail.NewRetryer(logger, auditrail.WithRetryStrategy(auditrail.NewExponentialBackoff(auditrail.ExponentialBackoffConfig{
+				Base:   time.Millisecond,
+				Factor: time.Millisecond,
+				Max:    time.Millisecond,
+			})))
+
+			time.AfterFunc(20*time.Millisecond, func() { unacked.setErr(nil) })
+
+			require.NoError(t, retryer.Log(ctx, newFakeEntry()))
+
+			info, err := stream.Info(ctx)
+			require.NoError(t, err)
+			require.EqualValues(t, 1, info.State.Msgs)
+		})
+	})
+
+	t.Run("GIVEN a misconfigured logger WHEN logging THEN a permanent error is returned", func(t *testing.T) {
+		js, _ := newJetStream(t, ctx)
+
+		for name, logger := range map[string]auditrail.Logger{
+			"unexpected stream": auditrail.NewJetStreamLogger(js, "", auditrail.WithJetStreamExpectStream("ORDERS")),
+			"unbound subject":   auditrail.NewJetStreamLogger(js, "events.{module}"),
+		} {
+			err := logger.Log(ctx, newFakeEntry())
+			require.ErrorIs(t, err, auditrail.ErrPermanent, name)
+			require.NotErrorIs(t, err, auditrail.ErrRetryable, name)
+
+			checkClose(t, ctx, logger)
+		}
+	})
+
+	t.Run("GIVEN a closed logger WHEN logging THEN an error is returned", func(t *testing.T) {
+		js, _ := newJetStream(t, ctx)
+		logger := auditrail.NewJetStreamLogger(js, "")
+		checkClose(t, ctx, logger)
+
+		require.ErrorIs(t, logger.Log(ctx, newFakeEntry()), auditrail.ErrTrailClosed)
+	})
+}
+
+// newJetStream runs an embedded NATS server with JetStream enabled, holding
+// an AUDIT stream bound to the audit.> and tenants.> subjects whose duplicate
+// window is one minute.
+func newJetStream(t *testing.T, ctx context.Context) (jetstream.JetStream, jetstream.Stream) {
+	t.Helper()
+
+	opts := test.DefaultTestOptions
+	opts.Port = -1
+	opts.JetStream = true
+	opts.StoreDir = t.TempDir()
+
+	srv := test.RunServer(&opts)
+	t.Cleanup(srv.Shutdown)
+
+	nc, err := nats.Connect(srv.ClientURL())
+	require.NoError(t, err)
+
+	t.Cleanup(nc.Close)
+
+	js, err := jetstream.New(nc)
+	require.NoError(t, err)
+
+	stream, err := js.CreateStream(ctx, jetstream.StreamConfig{
+		Name:       "AUDIT",
+		Subjects:   []string{"audit.>", "tenants.>"},
+		Duplicates: time.Minute,
+	})
+	require.NoError(t, err)
+
+	return js, stream
+}
+
+// unackedJetStream is a JetStream publisher failing with err, while set,
+// instead of publishing messages.
+type unackedJetStream struct {
+	auditrail.JetStreamPublisher
+	err error
+	mu  sync.Mutex
+}
+
+func (u *unackedJetStream) PublishMsg(ctx context.Context, msg *nats.Msg, opts ...jetstream.PublishOpt) (*jetstream.PubAck, error) {
+	u.mu.Lock()
+	err := u.err
+	u.mu.Unlock()
+
+	if err != nil {
+		return nil, err
+	}
+
+	return u.JetStreamPublisher.PublishMsg(ctx, msg, opts...)
+}
+
+func (u *unackedJetStream) setErr(err error) {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+
+	u.err = err
+}
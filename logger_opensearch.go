@@ -0,0 +1,321 @@
+package auditrail
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	v4 "github.com/aws/aws-sdk-go-v2/aws/signer/v4"
+	"github.com/elastic/go-elasticsearch/esapi"
+)
+
+// OpenSearchSigner signs the requests sent to OpenSearch, given the request
+// and its body.
+type OpenSearchSigner interface {
+	Sign(req *http.Request, body []byte) error
+}
+
+// OpenSearchSignerFunc is a function implementing [OpenSearchSigner].
+type OpenSearchSignerFunc func(req *http.Request, body []byte) error
+
+// Sign calls f(req, body).
+func (f OpenSearchSignerFunc) Sign(req *http.Request, body []byte) error {
+	return f(req, body)
+}
+
+type sigV4Signer struct {
+	credentials aws.CredentialsProvider
+	region      string
+	service     string
+	signer      *v4.Signer
+}
+
+// NewOpenSearchSigV4Signer returns a signer authenticating requests with AWS
+// Signature Version 4, as required by Amazon OpenSearch Service. Service is
+// "es" for managed domains and "aoss" for OpenSearch Serverless.
+func NewOpenSearchSigV4Signer(credentials aws.CredentialsProvider, region, service string) OpenSearchSigner {
+	return &sigV4Signer{
+		credentials: credentials,
+		region:      region,
+		service:     service,
+		signer:      v4.NewSigner(),
+	}
+}
+
+func (s *sigV4Signer) Sign(req *http.Request, body []byte) error {
+	creds, err := s.credentials.Retrieve(req.Context())
+	if err != nil {
+		return fmt.Errorf("%w: could not retrieve aws credentials", err)
+	}
+
+	sum := sha256.Sum256(body)
+	hash := hex.EncodeToString(sum[:])
+
+	req.Header.Set("X-Amz-Content-Sha256", hash)
+
+	return s.signer.SignHTTP(req.Context(), creds, req, hash, s.service, s.region, time.Now())
+}
+
+// OpenSearchClientOption is a function that configures an OpenSearch client.
+type OpenSearchClientOption func(options *OpenSearchClient)
+
+// OpenSearchClient is a minimal OpenSearch client, sending the requests needed
+// by the OpenSearch loggers.
+type OpenSearchClient struct {
+	endpoint   *url.URL
+	httpClient *http.Client
+	signer     OpenSearchSigner
+}
+
+// NewOpenSearchClient creates a new client for the OpenSearch cluster at the
+// given endpoint, e.g. "https://search-audit.eu-west-1.es.amazonaws.com".
+// Credentials given in the endpoint are used for basic authentication.
+func NewOpenSearchClient(endpoint string, options ...OpenSearchClientOption) (*OpenSearchClient, error) {
+	u, err := url.Parse(strings.TrimRight(endpoint, "/"))
+	if err != nil {
+		return nil, fmt.Errorf("%w: invalid opensearch endpoint", err)
+	}
+
+	if u.Scheme == "" || u.Host == "" {
+		return nil, fmt.Errorf("invalid opensearch endpoint %q", endpoint)
+	}
+
+	c := &OpenSearchClient{
+		endpoint:   u,
+		httpClient: http.DefaultClient,
+	}
+
+	for _, option := range options {
+		option(c)
+	}
+
+	return c, nil
+}
+
+// do sends a request to the path made of the given segments, which are
+// escaped, returning an error wrapping [ErrRetryable] if it could not be sent.
+func (c *OpenSearchClient) do(ctx context.Context, method string, path []string, query url.Values, body []byte, contentType string) (*http.Response, error) {
+	u := *c.endpoint
+	u.User = nil
+	u.RawPath = u.EscapedPath()
+	u.RawQuery = query.Encode()
+
+	for _, segment := range path {
+		u.Path += "/" + segment
+		u.RawPath += "/" + url.PathEscape(segment)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, u.String(), bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+
+	if body != nil {
+		req.Header.Set("Content-Type", contentType)
+	}
+
+	if user := c.endpoint.User; user != nil {
+		password, _ := user.Password()
+		req.SetBasicAuth(user.Username(), password)
+	}
+
+	if c.signer != nil {
+		if err = c.signer.Sign(req, body); err != nil {
+			return nil, fmt.Errorf("%w: could not sign opensearch request", err)
+		}
+	}
+
+	res, err := c.httpClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w: opensearch request failed", ErrRetryable, err)
+	}
+
+	return res, nil
+}
+
+// responseError consumes the given response and returns an [ElasticError] if
+// it reports a failure, as OpenSearch shares the error format of ElasticSearch.
+func (c *OpenSearchClient) responseError(res *http.Response) error {
+	return elasticResponseError(&esapi.Response{StatusCode: res.StatusCode, Header: res.Header, Body: res.Body})
+}
+
+// WithOpenSearchHTTPClient sets the HTTP client used to send requests.
+func WithOpenSearchHTTPClient(client *http.Client) OpenSearchClientOption {
+	return func(options *OpenSearchClient) {
+		if client != nil {
+			options.httpClient = client
+		}
+	}
+}
+
+// WithOpenSearchSigner sets the signer of the requests, such as
+// [NewOpenSearchSigV4Signer].
+func WithOpenSearchSigner(signer OpenSearchSigner) OpenSearchClientOption {
+	return func(options *OpenSearchClient) {
+		options.signer = signer
+	}
+}
+
+// OpenSearchLoggerOption is a function that configures an OpenSearch logger.
+type OpenSearchLoggerOption func(options *openSearchLogger)
+
+var _ BatchLogger = (*openSearchBulkLogger)(nil)
+
+type openSearchLogger struct {
+	index        func(*Entry) string
+	client       *OpenSearchClient
+	encoder      Encoder
+	closed       bool
+	closeChannel chan struct{}
+	mu           sync.RWMutex
+}
+
+type openSearchBulkLogger struct {
+	*openSearchLogger
+}
+
+// NewOpenSearchLogger creates a new OpenSearch logger, indexing each entry
+// using its idempotency ID as document ID, like [NewElasticLogger].
+func NewOpenSearchLogger(index string, client *OpenSearchClient, options ...OpenSearchLoggerOption) Logger {
+	return newOpenSearchLogger(index, client, options...)
+}
+
+// NewOpenSearchBulkLogger creates a new OpenSearch logger that writes entries
+// using the _bulk API, with the same semantics as [NewElasticBulkLogger].
+func NewOpenSearchBulkLogger(index string, client *OpenSearchClient, options ...OpenSearchLoggerOption) BatchLogger {
+	return &openSearchBulkLogger{openSearchLogger: newOpenSearchLogger(index, client, options...)}
+}
+
+func newOpenSearchLogger(index string, client *OpenSearchClient, options ...OpenSearchLoggerOption) *openSearchLogger {
+	o := &openSearchLogger{
+		index:        func(*Entry) string { return index },
+		client:       client,
+		encoder:      NewJSONCodec(),
+		closeChannel: make(chan struct{}),
+	}
+
+	for _, option := range options {
+		option(o)
+	}
+
+	return o
+}
+
+func (o *openSearchLogger) Log(ctx context.Context, entry *Entry) error {
+	if o.IsClosed() {
+		return ErrTrailClosed
+	}
+
+	doc, err := encodeDocument(o.encoder, entry)
+	if err != nil {
+		return err
+	}
+
+	path := []string{o.index(entry), "_doc", entry.GetIdempotencyID()}
+
+	res, err := o.client.do(ctx, http.MethodPut, path, nil, doc, "application/json")
+	if err != nil {
+		return err
+	}
+
+	return o.client.responseError(res)
+}
+
+func (o *openSearchLogger) Close() error {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	if o.closed {
+		return nil
+	}
+
+	o.closed = true
+
+	close(o.closeChannel)
+
+	return nil
+}
+
+func (o *openSearchLogger) Closed() <-chan struct{} {
+	o.mu.RLock()
+	defer o.mu.RUnlock()
+
+	return o.closeChannel
+}
+
+func (o *openSearchLogger) IsClosed() bool {
+	o.mu.RLock()
+	defer o.mu.RUnlock()
+
+	return o.closed
+}
+
+func (o *openSearchBulkLogger) Log(ctx context.Context, entry *Entry) error {
+	if errs := o.LogBatch(ctx, []*Entry{entry}); errs != nil {
+		return errs[0]
+	}
+
+	return nil
+}
+
+func (o *openSearchBulkLogger) LogBatch(ctx context.Context, entries []*Entry) []error {
+	if o.IsClosed() {
+		return batchErrors(len(entries), ErrTrailClosed)
+	}
+
+	if len(entries) == 0 {
+		return nil
+	}
+
+	body, errs := elasticBulkBody(entries, o.index, func(entry *Entry) ([]byte, error) {
+		return encodeDocument(o.encoder, entry)
+	})
+	if body.Len() == 0 {
+		return errs
+	}
+
+	res, err := o.client.do(ctx, http.MethodPost, []string{"_bulk"}, nil, body.Bytes(), "application/x-ndjson")
+	if err != nil {
+		return mergeBatchErrors(errs, len(entries), err)
+	}
+
+	if res.StatusCode > 299 {
+		return mergeBatchErrors(errs, len(entries), o.client.responseError(res))
+	}
+
+	defer res.Body.Close()
+
+	return elasticBulkResults(res.Body, errs, len(entries))
+}
+
+// WithOpenSearchEncoder sets the encoder used to build the indexed documents.
+// The encoder must produce JSON documents. If encoder is nil, entries are
+// encoded as JSON.
+func WithOpenSearchEncoder(encoder Encoder) OpenSearchLoggerOption {
+	return func(options *openSearchLogger) {
+		if encoder == nil {
+			encoder = NewJSONCodec()
+		}
+
+		options.encoder = encoder
+	}
+}
+
+// WithOpenSearchIndexPattern writes each entry to the index resulting from
+// expanding the given pattern. See [WithElasticIndexPattern] for the supported
+// placeholders.
+func WithOpenSearchIndexPattern(pattern string) OpenSearchLoggerOption {
+	return func(options *openSearchLogger) {
+		options.index = func(entry *Entry) string {
+			return expandElasticIndex(pattern, entry)
+		}
+	}
+}
@@ -0,0 +1,176 @@
+package auditrail
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+)
+
+// OpenSearchBootstrapConfig describes the resources installed by
+// [BootstrapOpenSearch].
+type OpenSearchBootstrapConfig struct {
+	// Name of the index template, ISM policy and ingest pipeline. Defaults to
+	// "auditrail".
+	Name string
+
+	// IndexPatterns matched by the index template and the ISM policy.
+	// Defaults to Name followed by "-*".
+	IndexPatterns []string
+
+	// DeleteAfter sets the age after which indices are deleted by the ISM
+	// policy, e.g. "365d". Indices are never deleted if empty.
+	DeleteAfter string
+
+	// GeoIP enriches entries carrying a client IP address but no GeoIP details
+	// using the geoip processor of the ingest pipeline.
+	GeoIP bool
+
+	// Shards and Replicas of the created indices. Cluster defaults are used
+	// when zero.
+	Shards   int
+	Replicas int
+}
+
+// BootstrapOpenSearch installs in OpenSearch the resources used to store audit
+// entries: an Index State Management (ISM) policy attached to new indices, an
+// ingest pipeline and an index template with the same strict mapping installed
+// by [BootstrapElastic]. Existing resources with the same name are replaced.
+func BootstrapOpenSearch(ctx context.Context, client *OpenSearchClient, config OpenSearchBootstrapConfig) error {
+	if config.Name == "" {
+		config.Name = "auditrail"
+	}
+
+	if len(config.IndexPatterns) == 0 {
+		config.IndexPatterns = []string{config.Name + "-*"}
+	}
+
+	name := url.PathEscape(config.Name)
+
+	if err := openSearchPutPolicy(ctx, client, name, openSearchISMPolicy(config)); err != nil {
+		return fmt.Errorf("%w: could not install ism policy", err)
+	}
+
+	pipeline := elasticIngestPipeline(ElasticBootstrapConfig{GeoIP: config.GeoIP})
+	if err := openSearchPut(ctx, client, []string{"_ingest", "pipeline", name}, nil, pipeline); err != nil {
+		return fmt.Errorf("%w: could not install ingest pipeline", err)
+	}
+
+	settings := map[string]interface{}{"index.default_pipeline": config.Name}
+
+	if config.Shards > 0 {
+		settings["index.number_of_shards"] = config.Shards
+	}
+
+	if config.Replicas > 0 {
+		settings["index.number_of_replicas"] = config.Replicas
+	}
+
+	template := map[string]interface{}{
+		"index_patterns": config.IndexPatterns,
+		"priority":       200,
+		"template": map[string]interface{}{
+			"settings": settings,
+			"mappings": elasticMappings(),
+		},
+		"_meta": map[string]string{"managed_by": "auditrail"},
+	}
+
+	if err := openSearchPut(ctx, client, []string{"_index_template", name}, nil, template); err != nil {
+		return fmt.Errorf("%w: could not install index template", err)
+	}
+
+	return nil
+}
+
+func openSearchISMPolicy(config OpenSearchBootstrapConfig) map[string]interface{} {
+	hot := map[string]interface{}{
+		"name":        "hot",
+		"actions":     []interface{}{},
+		"transitions": []interface{}{},
+	}
+
+	states := []interface{}{hot}
+
+	if config.DeleteAfter != "" {
+		hot["transitions"] = []interface{}{
+			map[string]interface{}{
+				"state_name": "delete",
+				"conditions": map[string]string{"min_index_age": config.DeleteAfter},
+			},
+		}
+
+		states = append(states, map[string]interface{}{
+			"name":        "delete",
+			"actions":     []interface{}{map[string]interface{}{"delete": map[string]interface{}{}}},
+			"transitions": []interface{}{},
+		})
+	}
+
+	return map[string]interface{}{
+		"policy": map[string]interface{}{
+			"description":   "Retention of auditrail entries",
+			"default_state": "hot",
+			"states":        states,
+			"ism_template": []interface{}{
+				map[string]interface{}{"index_patterns": config.IndexPatterns, "priority": 100},
+			},
+		},
+	}
+}
+
+// openSearchPutPolicy creates or replaces the given ISM policy. Replacing a
+// policy requires its current sequence number and primary term.
+func openSearchPutPolicy(ctx context.Context, client *OpenSearchClient, name string, policy interface{}) error {
+	path := []string{"_plugins", "_ism", "policies", name}
+
+	err := openSearchPut(ctx, client, path, nil, policy)
+
+	var osErr *ElasticError
+	if !errors.As(err, &osErr) || osErr.Status != http.StatusConflict {
+		return err
+	}
+
+	res, err := client.do(ctx, http.MethodGet, path, nil, nil, "")
+	if err != nil {
+		return err
+	}
+
+	if res.StatusCode > 299 {
+		return client.responseError(res)
+	}
+
+	defer res.Body.Close()
+
+	var current struct {
+		SeqNo       int64 `json:"_seq_no"`
+		PrimaryTerm int64 `json:"_primary_term"`
+	}
+
+	if err = json.NewDecoder(res.Body).Decode(&current); err != nil {
+		return fmt.Errorf("%w: invalid ism policy response", err)
+	}
+
+	return openSearchPut(ctx, client, path, url.Values{
+		"if_seq_no":       {strconv.FormatInt(current.SeqNo, 10)},
+		"if_primary_term": {strconv.FormatInt(current.PrimaryTerm, 10)},
+	}, policy)
+}
+
+// openSearchPut sends a PUT request with the given JSON body.
+func openSearchPut(ctx context.Context, client *OpenSearchClient, path []string, query url.Values, body interface{}) error {
+	raw, err := json.Marshal(body)
+	if err != nil {
+		return err
+	}
+
+	res, err := client.do(ctx, http.MethodPut, path, query, raw, "application/json")
+	if err != nil {
+		return err
+	}
+
+	return client.responseError(res)
+}
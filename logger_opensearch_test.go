@@ -0,0 +1,221 @@
+package auditrail_test
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/botchris/go-auditrail"
+	"github.com/stretchr/testify/require"
+)
+
+func TestOpenSearchLogger(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	type request struct {
+		method string
+		path   string
+		raw    string
+		query  string
+		header http.Header
+		body   string
+	}
+
+	var requests []request
+
+	// the stand-in fails bulk items logged by "400" and reports items logged
+	// by "409" as version conflicts. The ISM policy "existing" already exists.
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		raw, err := io.ReadAll(r.Body)
+		require.NoError(t, err)
+
+		requests = append(requests, request{method: r.Method, path: r.URL.Path, raw: r.URL.EscapedPath(), query: r.URL.RawQuery, header: r.Header, body: string(raw)})
+
+		w.Header().Set("Content-Type", "application/json")
+
+		switch {
+		case r.URL.Path == "/_plugins/_ism/policies/existing" && r.Method == http.MethodGet:
+			_, _ = w.Write([]byte(`{"_id":"existing","_seq_no":7,"_primary_term":2,"policy":{}}`))
+		case r.URL.Path == "/_plugins/_ism/policies/existing" && r.URL.RawQuery == "":
+			w.WriteHeader(http.StatusConflict)
+			_, _ = w.Write([]byte(`{"error":{"type":"version_conflict_engine_exception","reason":"policy already exists"},"status":409}`))
+		case r.URL.Path == "/_bulk":
+			lines := strings.Split(strings.TrimSpace(string(raw)), "\n")
+			items := make([]map[string]interface{}, 0, len(lines)/2)
+
+			for i := 1; i < len(lines); i += 2 {
+				item := map[string]interface{}{"status": 201}
+
+				switch {
+				case strings.Contains(lines[i], `"actor":"400"`):
+					item["status"] = 400
+					item["error"] = map[string]string{"type": "mapper_parsing_exception", "reason": "failed to parse"}
+				case strings.Contains(lines[i], `"actor":"409"`):
+					item["status"] = 409
+					item["error"] = map[string]string{"type": "version_conflict_engine_exception", "reason": "document already exists"}
+				}
+
+				items = append(items, map[string]interface{}{"create": item})
+			}
+
+			_ = json.NewEncoder(w).Encode(map[string]interface{}{"errors": true, "items": items})
+		default:
+			_, _ = w.Write([]byte(`{"acknowledged":true}`))
+		}
+	}))
+	defer srv.Close()
+
+	client, err := auditrail.NewOpenSearchClient(srv.URL)
+	require.NoError(t, err)
+
+	t.Run("GIVEN an opensearch logger WHEN logging an entry THEN it is indexed using its idempotency id", func(t *testing.T) {
+		requests = nil
+
+		logger := auditrail.NewOpenSearchLogger("audit", client)
+		defer checkClose(t, ctx, logger)
+
+		entry := newFakeEntry()
+		require.NoError(t, logger.Log(ctx, entry))
+
+		require.Len(t, requests, 1)
+		require.Equal(t, http.MethodPut, requests[0].method)
+		require.Equal(t, "/audit/_doc/"+entry.GetIdempotencyID(), requests[0].path)
+		require.Equal(t, "application/json", requests[0].header.Get("Content-Type"))
+
+		var logged auditrail.Entry
+
+		require.NoError(t, json.Unmarshal([]byte(requests[0].body), &logged))
+		require.Equal(t, entry.GetActor(), logged.GetActor())
+	})
+
+	t.Run("GIVEN an entry with reserved characters in its idempotency id WHEN logging it THEN they are escaped once", func(t *testing.T) {
+		requests = nil
+
+		logger := auditrail.NewOpenSearchLogger("audit", client)
+		defer checkClose(t, ctx, logger)
+
+		entry := newFakeEntry().WithIdempotency("order/42 #1?%")
+		require.NoError(t, logger.Log(ctx, entry))
+
+		require.Len(t, requests, 1)
+		require.Equal(t, "/audit/_doc/order/42 #1?%", requests[0].path)
+		require.Equal(t, "/audit/_doc/order%2F42%20%231%3F%25", requests[0].raw)
+	})
+
+	t.Run("GIVEN a bulk logger WHEN items fail THEN errors are reported per entry", func(t *testing.T) {
+		requests = nil
+
+		logger := auditrail.NewOpenSearchBulkLogger("audit", client, auditrail.WithOpenSearchIndexPattern("audit-{module}"))
+		defer checkClose(t, ctx, logger)
+
+		errs := logger.LogBatch(ctx, []*auditrail.Entry{
+			auditrail.NewEntry("john", "order_create", "orders"),
+			auditrail.NewEntry("409", "order_create", "orders"),
+			auditrail.NewEntry("400", "order_create", "orders"),
+		})
+
+		require.Len(t, requests, 1)
+		require.Equal(t, "application/x-ndjson", requests[0].header.Get("Content-Type"))
+		require.Contains(t, requests[0].body, `"_index":"audit-orders"`)
+
+		require.Len(t, errs, 3)
+		require.NoError(t, errs[0])
+		require.NoError(t, errs[1], "version conflicts are duplicates")
+
+		var osErr *auditrail.ElasticError
+
+		require.ErrorAs(t, errs[2], &osErr)
+		require.Equal(t, "mapper_parsing_exception", osErr.Type)
+		require.ErrorIs(t, errs[2], auditrail.ErrPermanent)
+	})
+
+	t.Run("GIVEN a sigv4 signer WHEN logging an entry THEN the request is signed", func(t *testing.T) {
+		requests = nil
+
+		credentials := aws.CredentialsProviderFunc(func(context.Context) (aws.Credentials, error) {
+			return aws.Credentials{AccessKeyID: "AKID", SecretAccessKey: "SECRET"}, nil
+		})
+
+		signed, err := auditrail.NewOpenSearchClient(srv.URL,
+			auditrail.WithOpenSearchSigner(auditrail.NewOpenSearchSigV4Signer(credentials, "eu-west-1", "es")),
+		)
+		require.NoError(t, err)
+
+		logger := auditrail.NewOpenSearchBulkLogger("audit", signed)
+		defer checkClose(t, ctx, logger)
+
+		require.NoError(t, logger.Log(ctx, newFakeEntry()))
+
+		require.Len(t, requests, 1)
+		require.True(t, strings.HasPrefix(requests[0].header.Get("Authorization"), "AWS4-HMAC-SHA256 Credential=AKID/"), requests[0].header.Get("Authorization"))
+		require.Contains(t, requests[0].header.Get("Authorization"), "/eu-west-1/es/aws4_request")
+		require.NotEmpty(t, requests[0].header.Get("X-Amz-Date"))
+		require.Len(t, requests[0].header.Get("X-Amz-Content-Sha256"), 64)
+	})
+
+	t.Run("GIVEN an endpoint with credentials WHEN logging an entry THEN basic authentication is used", func(t *testing.T) {
+		requests = nil
+
+		basic, err := auditrail.NewOpenSearchClient(strings.Replace(srv.URL, "http://", "http://admin:secret@", 1))
+		require.NoError(t, err)
+
+		require.NoError(t, auditrail.NewOpenSearchLogger("audit", basic).Log(ctx, newFakeEntry()))
+
+		require.Len(t, requests, 1)
+
+		r := &http.Request{Header: requests[0].header}
+		user, password, ok := r.BasicAuth()
+		require.True(t, ok)
+		require.Equal(t, "admin", user)
+		require.Equal(t, "secret", password)
+	})
+
+	t.Run("GIVEN a closed logger WHEN logging THEN an error is returned", func(t *testing.T) {
+		logger := auditrail.NewOpenSearchBulkLogger("audit", client)
+		checkClose(t, ctx, logger)
+
+		require.ErrorIs(t, logger.Log(ctx, newFakeEntry()), auditrail.ErrTrailClosed)
+	})
+
+	t.Run("GIVEN a bootstrap config WHEN bootstrapping THEN ism policy, pipeline and template are installed", func(t *testing.T) {
+		requests = nil
+
+		err := auditrail.BootstrapOpenSearch(ctx, client, auditrail.OpenSearchBootstrapConfig{
+			Name:        "audit",
+			DeleteAfter: "365d",
+			Replicas:    1,
+		})
+		require.NoError(t, err)
+		require.Len(t, requests, 3)
+
+		require.Equal(t, "/_plugins/_ism/policies/audit", requests[0].path)
+		require.Contains(t, requests[0].body, `"min_index_age":"365d"`)
+		require.Contains(t, requests[0].body, `"ism_template":[{"index_patterns":["audit-*"]`)
+
+		require.Equal(t, "/_ingest/pipeline/audit", requests[1].path)
+		require.NotContains(t, requests[1].body, `"geoip":{`)
+
+		require.Equal(t, "/_index_template/audit", requests[2].path)
+		require.Contains(t, requests[2].body, `"index.default_pipeline":"audit"`)
+		require.Contains(t, requests[2].body, `"index.number_of_replicas":1`)
+		require.NotContains(t, requests[2].body, "index.lifecycle.name")
+
+		t.Run("AND the ism policy exists THEN it is replaced using its sequence number", func(t *testing.T) {
+			requests = nil
+
+			require.NoError(t, auditrail.BootstrapOpenSearch(ctx, client, auditrail.OpenSearchBootstrapConfig{Name: "existing"}))
+			require.Len(t, requests, 5)
+
+			require.Equal(t, http.MethodGet, requests[1].method)
+			require.Equal(t, http.MethodPut, requests[2].method)
+			require.Equal(t, "if_primary_term=2&if_seq_no=7", requests[2].query)
+		})
+	})
+}
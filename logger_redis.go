@@ -0,0 +1,216 @@
+package auditrail
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+const (
+	// DefaultRedisStreamKey is the default stream key template of the Redis
+	// stream logger.
+	DefaultRedisStreamKey = "audit:{module}"
+
+	// DefaultRedisStreamMaxLen is the default approximate maximum length of
+	// the streams written by the Redis stream logger.
+	DefaultRedisStreamMaxLen = 1_000_000
+)
+
+// Fields of the stream messages written by the Redis stream logger.
+const (
+	redisFieldIdempotencyID = "idempotency_id"
+	redisFieldActor         = "actor"
+	redisFieldAction        = "action"
+	redisFieldModule        = "module"
+	redisFieldCorrelationID = "correlation_id"
+	redisFieldCausationID   = "causation_id"
+	redisFieldAuthMethod    = "auth_method"
+	redisFieldOccurredAt    = "occurred_at"
+	redisFieldDetails       = "details"
+)
+
+// RedisStreamsAPI captures the Redis client part that we need. It is
+// implemented by the go-redis clients, such as [redis.Client] and
+// [redis.ClusterClient].
+type RedisStreamsAPI interface {
+	XAdd(ctx context.Context, a *redis.XAddArgs) *redis.StringCmd
+	XGroupCreateMkStream(ctx context.Context, stream, group, start string) *redis.StatusCmd
+	XReadGroup(ctx context.Context, a *redis.XReadGroupArgs) *redis.XStreamSliceCmd
+	XAck(ctx context.Context, stream, group string, ids ...string) *redis.IntCmd
+}
+
+// RedisStreamLoggerOption is a function that configures a Redis stream logger.
+type RedisStreamLoggerOption func(options *redisStreamLogger)
+
+type redisStreamLogger struct {
+	client       RedisStreamsAPI
+	key          func(*Entry) string
+	maxLen       int64
+	closed       bool
+	closeChannel chan struct{}
+	mu           sync.RWMutex
+}
+
+// NewRedisStreamLogger builds a new logger that appends entries to Redis
+// streams using XADD, trimming streams to approximately
+// [DefaultRedisStreamMaxLen] messages.
+//
+// The stream key of each entry is the result of expanding the given template,
+// which supports the placeholders {module}, {action} and {actor}. Use
+// [WithRedisStreamKeyFunc] to key streams by other criteria, such as tenant.
+// If key is empty, [DefaultRedisStreamKey] is used.
+//
+// Entries are stored as flat fields: idempotency_id, actor, action, module,
+// correlation_id, causation_id, auth_method, occurred_at (RFC 3339) and
+// details (JSON). Empty fields are omitted. Use [NewRedisStreamReader] to read
+// them back.
+func NewRedisStreamLogger(client RedisStreamsAPI, key string, options ...RedisStreamLoggerOption) Logger {
+	if key == "" {
+		key = DefaultRedisStreamKey
+	}
+
+	l := &redisStreamLogger{
+		client: client,
+		key: func(entry *Entry) string {
+			return expandRedisStreamKey(key, entry)
+		},
+		maxLen:       DefaultRedisStreamMaxLen,
+		closeChannel: make(chan struct{}),
+	}
+
+	for _, option := range options {
+		option(l)
+	}
+
+	return l
+}
+
+func (l *redisStreamLogger) Log(ctx context.Context, entry *Entry) error {
+	if l.IsClosed() {
+		return ErrTrailClosed
+	}
+
+	values, err := redisStreamValues(entry)
+	if err != nil {
+		return fmt.Errorf("%w: %w: could not encode entry", ErrPermanent, err)
+	}
+
+	args := &redis.XAddArgs{
+		Stream: l.key(entry),
+		Values: values,
+	}
+
+	if l.maxLen > 0 {
+		args.MaxLen = l.maxLen
+		args.Approx = true
+	}
+
+	if err = l.client.XAdd(ctx, args).Err(); err != nil {
+		return fmt.Errorf("%w: %w: could not add entry to stream %s", ErrRetryable, err, args.Stream)
+	}
+
+	return nil
+}
+
+func (l *redisStreamLogger) Close() error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if l.closed {
+		return nil
+	}
+
+	l.closed = true
+
+	close(l.closeChannel)
+
+	return nil
+}
+
+func (l *redisStreamLogger) Closed() <-chan struct{} {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+
+	return l.closeChannel
+}
+
+func (l *redisStreamLogger) IsClosed() bool {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+
+	return l.closed
+}
+
+// redisStreamValues returns the flat fields of the given entry.
+func redisStreamValues(entry *Entry) ([]string, error) {
+	var details string
+
+	if d := entry.GetDetails(); len(d) > 0 {
+		raw, err := json.Marshal(d)
+		if err != nil {
+			return nil, err
+		}
+
+		details = string(raw)
+	}
+
+	var occurredAt string
+
+	if at := entry.GetOccurredAt(); !at.IsZero() {
+		occurredAt = at.UTC().Format(time.RFC3339Nano)
+	}
+
+	values := make([]string, 0, 18)
+
+	for _, field := range [][2]string{
+		{redisFieldIdempotencyID, entry.GetIdempotencyID()},
+		{redisFieldActor, entry.GetActor()},
+		{redisFieldAction, entry.GetAction()},
+		{redisFieldModule, entry.GetModule()},
+		{redisFieldCorrelationID, entry.GetCorrelationID()},
+		{redisFieldCausationID, entry.GetCausationID()},
+		{redisFieldAuthMethod, entry.GetAuthMethod()},
+		{redisFieldOccurredAt, occurredAt},
+		{redisFieldDetails, details},
+	} {
+		if field[1] != "" {
+			values = append(values, field[0], field[1])
+		}
+	}
+
+	return values, nil
+}
+
+// expandRedisStreamKey expands the placeholders of the given key template.
+func expandRedisStreamKey(template string, entry *Entry) string {
+	return strings.NewReplacer(
+		"{module}", entry.GetModule(),
+		"{action}", entry.GetAction(),
+		"{actor}", entry.GetActor(),
+	).Replace(template)
+}
+
+// WithRedisStreamKeyFunc sets the function returning the stream key of an
+// entry, overriding the key template, e.g. to write the entries of each tenant
+// to its own stream.
+func WithRedisStreamKeyFunc(fn func(entry *Entry) string) RedisStreamLoggerOption {
+	return func(options *redisStreamLogger) {
+		if fn != nil {
+			options.key = fn
+		}
+	}
+}
+
+// WithRedisStreamMaxLen sets the approximate maximum length of streams, which
+// are trimmed using MAXLEN ~ on every write. Streams are not trimmed if maxLen
+// is not positive.
+func WithRedisStreamMaxLen(maxLen int64) RedisStreamLoggerOption {
+	return func(options *redisStreamLogger) {
+		options.maxLen = maxLen
+	}
+}
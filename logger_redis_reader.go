@@ -0,0 +1,196 @@
+package auditrail
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// RedisStreamReaderOption is a function that configures a Redis stream reader.
+type RedisStreamReaderOption func(options *RedisStreamReader)
+
+// RedisStreamReader reads the entries written by [NewRedisStreamLogger] to a
+// stream as a member of a consumer group, so many readers can share the
+// replay of a stream.
+type RedisStreamReader struct {
+	client      RedisStreamsAPI
+	stream      string
+	group       string
+	consumer    string
+	count       int64
+	block       time.Duration
+	dropHandler func(message redis.XMessage, err error)
+}
+
+// NewRedisStreamReader creates a reader of the given stream, reading as the
+// given consumer of the given consumer group. The group is created, along
+// with the stream, if it does not exist.
+func NewRedisStreamReader(client RedisStreamsAPI, stream, group, consumer string, options ...RedisStreamReaderOption) *RedisStreamReader {
+	r := &RedisStreamReader{
+		client:      client,
+		stream:      stream,
+		group:       group,
+		consumer:    consumer,
+		count:       100,
+		block:       5 * time.Second,
+		dropHandler: func(redis.XMessage, error) {},
+	}
+
+	for _, option := range options {
+		option(r)
+	}
+
+	return r
+}
+
+// Replay reads entries from the stream and logs them into dst until ctx is
+// done, in which case it returns nil. Messages are acknowledged once logged.
+//
+// Messages delivered to the consumer but not acknowledged, e.g. because a
+// previous replay stopped, are replayed first. Messages that cannot be decoded
+// or that dst rejects with an error wrapping [ErrPermanent] are passed to the
+// drop handler and acknowledged. Any other error stops the replay, leaving
+// the message pending.
+func (r *RedisStreamReader) Replay(ctx context.Context, dst Logger) error {
+	err := r.client.XGroupCreateMkStream(ctx, r.stream, r.group, "0").Err()
+	if err != nil && !strings.HasPrefix(err.Error(), "BUSYGROUP") {
+		return fmt.Errorf("%w: could not create consumer group %s", err, r.group)
+	}
+
+	// "0" reads the pending messages of the consumer, ">" new messages.
+	start := "0"
+
+	for ctx.Err() == nil {
+		streams, err := r.client.XReadGroup(ctx, &redis.XReadGroupArgs{
+			Group:    r.group,
+			Consumer: r.consumer,
+			Streams:  []string{r.stream, start},
+			Count:    r.count,
+			Block:    r.block,
+		}).Result()
+
+		switch {
+		case ctx.Err() != nil:
+			return nil
+		case errors.Is(err, redis.Nil):
+			continue
+		case err != nil:
+			return fmt.Errorf("%w: could not read stream %s", err, r.stream)
+		}
+
+		read := 0
+
+		for _, stream := range streams {
+			for _, message := range stream.Messages {
+				read++
+
+				if err = r.replay(ctx, dst, message); err != nil {
+					return err
+				}
+			}
+		}
+
+		if read == 0 {
+			start = ">"
+		}
+	}
+
+	return nil
+}
+
+// replay logs the entry of the given message into dst, acknowledging it.
+func (r *RedisStreamReader) replay(ctx context.Context, dst Logger, message redis.XMessage) error {
+	entry, err := redisStreamEntry(message.Values)
+	if err == nil {
+		err = dst.Log(ctx, entry)
+	}
+
+	if err != nil {
+		if entry != nil && !errors.Is(err, ErrPermanent) {
+			return fmt.Errorf("%w: could not replay message %s", err, message.ID)
+		}
+
+		r.dropHandler(message, err)
+	}
+
+	if err = r.client.XAck(ctx, r.stream, r.group, message.ID).Err(); err != nil {
+		return fmt.Errorf("%w: could not acknowledge message %s", err, message.ID)
+	}
+
+	return nil
+}
+
+// redisStreamEntry decodes the entry stored in the given fields.
+func redisStreamEntry(values map[string]interface{}) (*Entry, error) {
+	field := func(name string) string {
+		value, _ := values[name].(string)
+
+		return value
+	}
+
+	if field(redisFieldIdempotencyID) == "" {
+		return nil, fmt.Errorf("%w: message is not an entry", ErrPermanent)
+	}
+
+	data := &entryData{
+		IdempotencyID: field(redisFieldIdempotencyID),
+		Actor:         field(redisFieldActor),
+		Action:        field(redisFieldAction),
+		Module:        field(redisFieldModule),
+		CorrelationID: field(redisFieldCorrelationID),
+		CausationID:   field(redisFieldCausationID),
+		AuthMethod:    field(redisFieldAuthMethod),
+	}
+
+	if at := field(redisFieldOccurredAt); at != "" {
+		occurredAt, err := time.Parse(time.RFC3339Nano, at)
+		if err != nil {
+			return nil, fmt.Errorf("%w: %w: invalid occurred_at field", ErrPermanent, err)
+		}
+
+		data.OccurredAt = occurredAt
+	}
+
+	if details := field(redisFieldDetails); details != "" {
+		if err := json.Unmarshal([]byte(details), &data.Details); err != nil {
+			return nil, fmt.Errorf("%w: %w: invalid details field", ErrPermanent, err)
+		}
+	}
+
+	return &Entry{data: data}, nil
+}
+
+// WithRedisStreamReaderCount sets the maximum number of messages read at once.
+// Defaults to 100.
+func WithRedisStreamReaderCount(count int64) RedisStreamReaderOption {
+	return func(options *RedisStreamReader) {
+		if count > 0 {
+			options.count = count
+		}
+	}
+}
+
+// WithRedisStreamReaderBlock sets how long reads wait for new messages.
+// Defaults to 5 seconds.
+func WithRedisStreamReaderBlock(block time.Duration) RedisStreamReaderOption {
+	return func(options *RedisStreamReader) {
+		if block > 0 {
+			options.block = block
+		}
+	}
+}
+
+// WithRedisStreamReaderDropHandler sets the handler of the messages that are
+// dropped because they cannot be decoded or are permanently rejected.
+func WithRedisStreamReaderDropHandler(handler func(message redis.XMessage, err error)) RedisStreamReaderOption {
+	return func(options *RedisStreamReader) {
+		if handler != nil {
+			options.dropHandler = handler
+		}
+	}
+}
@@ -0,0 +1,328 @@
+package auditrail_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/botchris/go-auditrail"
+	"github.com/redis/go-redis/v9"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRedisStreamLogger(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	t.Run("GIVEN a redis stream logger WHEN logging an entry THEN it is added with flat fields and trimming", func(t *testing.T) {
+		client := newFakeRedisStreams()
+		logger := auditrail.NewRedisStreamLogger(client, "")
+		defer checkClose(t, ctx, logger)
+
+		entry := auditrail.NewEntry("john", "order_create", "orders").
+			WithCorrelation("flow-1").
+			WithOccurredAt(time.Date(2026, 10, 17, 10, 30, 0, 0, time.UTC)).
+			AppendDetails("order_id", "1234")
+
+		require.NoError(t, logger.Log(ctx, entry))
+
+		require.Len(t, client.adds, 1)
+		require.Equal(t, "audit:orders", client.adds[0].Stream)
+		require.EqualValues(t, auditrail.DefaultRedisStreamMaxLen, client.adds[0].MaxLen)
+		require.True(t, client.adds[0].Approx)
+
+		messages := client.Messages("audit:orders")
+		require.Len(t, messages, 1)
+		require.Equal(t, map[string]interface{}{
+			"idempotency_id": entry.GetIdempotencyID(),
+			"actor":          "john",
+			"action":         "order_create",
+			"module":         "orders",
+			"correlation_id": "flow-1",
+			"occurred_at":    "2026-10-17T10:30:00Z",
+			"details":        `{"order_id":"1234"}`,
+		}, messages[0].Values)
+	})
+
+	t.Run("GIVEN a key per tenant WHEN logging entries THEN each tenant has its own stream", func(t *testing.T) {
+		client := newFakeRedisStreams()
+		logger := auditrail.NewRedisStreamLogger(client, "",
+			auditrail.WithRedisStreamKeyFunc(func(e *auditrail.Entry) string {
+				return fmt.Sprintf("audit:%v", e.GetDetails()["tenant"])
+			}),
+			auditrail.WithRedisStreamMaxLen(2),
+		)
+		defer checkClose(t, ctx, logger)
+
+		for i := 0; i < 3; i++ {
+			require.NoError(t, logger.Log(ctx, newFakeEntry().AppendDetails("tenant", "acme")))
+		}
+
+		require.NoError(t, logger.Log(ctx, newFakeEntry().AppendDetails("tenant", "globex")))
+		require.Len(t, client.Messages("audit:acme"), 2)
+		require.Len(t, client.Messages("audit:globex"), 1)
+	})
+
+	t.Run("GIVEN a failing redis WHEN logging THEN a retryable error is returned", func(t *testing.T) {
+		client := newFakeRedisStreams()
+		client.err = errors.New("LOADING Redis is loading the dataset in memory")
+
+		logger := auditrail.NewRedisStreamLogger(client, "")
+		defer checkClose(t, ctx, logger)
+
+		require.ErrorIs(t, logger.Log(ctx, newFakeEntry()), auditrail.ErrRetryable)
+	})
+}
+
+func TestRedisStreamReader(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	client := newFakeRedisStreams()
+	logger := auditrail.NewRedisStreamLogger(client, "audit")
+
+	entries := make([]*auditrail.Entry, 5)
+	for i := range entries {
+		entries[i] = newFakeEntry().WithCausation("cause").AppendDetails("n", float64(i))
+		require.NoError(t, logger.Log(ctx, entries[i]))
+	}
+
+	checkClose(t, ctx, logger)
+
+	t.Run("GIVEN a stream WHEN replaying it THEN entries are logged into the destination and acknowledged", func(t *testing.T) {
+		dst := auditrail.NewMemoryLogger()
+		reader := auditrail.NewRedisStreamReader(client, "audit", "shippers", "shipper-1",
+			auditrail.WithRedisStreamReaderCount(2),
+			auditrail.WithRedisStreamReaderBlock(time.Millisecond),
+		)
+
+		replayCtx, stop := context.WithCancel(ctx)
+
+		done := make(chan error)
+		go func() { done <- reader.Replay(replayCtx, dst) }()
+
+		require.Eventually(t, func() bool { return dst.Size() == 5 }, 5*time.Second, time.Millisecond)
+		stop()
+		require.NoError(t, <-done)
+
+		replayed := make(map[string]*auditrail.Entry)
+		for _, entry := range dst.Trail() {
+			replayed[entry.GetIdempotencyID()] = entry
+		}
+
+		for _, entry := range entries {
+			got := replayed[entry.GetIdempotencyID()]
+			require.NotNil(t, got)
+			require.Equal(t, entry.GetActor(), got.GetActor())
+			require.Equal(t, "cause", got.GetCausationID())
+			require.True(t, entry.GetOccurredAt().Equal(got.GetOccurredAt()))
+			require.Equal(t, entry.GetDetails(), got.GetDetails())
+		}
+
+		require.Empty(t, client.Pending("audit", "shippers", "shipper-1"))
+	})
+
+	t.Run("GIVEN a destination failing WHEN replaying THEN the message is left pending and replayed first next time", func(t *testing.T) {
+		failing := &failingLogger{Logger: auditrail.NewMemoryLogger(), err: errors.New("disk full")}
+		reader := auditrail.NewRedisStreamReader(client, "audit", "archivers", "archiver-1", auditrail.WithRedisStreamReaderBlock(time.Millisecond))
+
+		require.ErrorContains(t, reader.Replay(ctx, failing), "disk full")
+		require.Len(t, client.Pending("audit", "archivers", "archiver-1"), 5)
+
+		dst := auditrail.NewMemoryLogger()
+		replayCtx, stop := context.WithCancel(ctx)
+
+		done := make(chan error)
+		go func() { done <- reader.Replay(replayCtx, dst) }()
+
+		require.Eventually(t, func() bool { return dst.Size() == 5 }, 5*time.Second, time.Millisecond)
+		stop()
+		require.NoError(t, <-done)
+		require.Empty(t, client.Pending("audit", "archivers", "archiver-1"))
+	})
+
+	t.Run("GIVEN a message that is not an entry WHEN replaying THEN it is dropped and acknowledged", func(t *testing.T) {
+		client.XAdd(ctx, &redis.XAddArgs{Stream: "audit", Values: []string{"foo", "bar"}})
+
+		var dropped []redis.XMessage
+
+		dst := auditrail.NewMemoryLogger()
+		reader := auditrail.NewRedisStreamReader(client, "audit", "shippers", "shipper-1",
+			auditrail.WithRedisStreamReaderBlock(time.Millisecond),
+			auditrail.WithRedisStreamReaderDropHandler(func(m redis.XMessage, err error) {
+				require.ErrorIs(t, err, auditrail.ErrPermanent)
+				dropped = append(dropped, m)
+			}),
+		)
+
+		replayCtx, stop := context.WithTimeout(ctx, 50*time.Millisecond)
+		defer stop()
+
+		require.NoError(t, reader.Replay(replayCtx, dst))
+		require.Len(t, dropped, 1)
+		require.Equal(t, "bar", dropped[0].Values["foo"])
+		require.Zero(t, dst.Size())
+		require.Empty(t, client.Pending("audit", "shippers", "shipper-1"))
+	})
+}
+
+// failingLogger is a logger failing every write with err.
+type failingLogger struct {
+	auditrail.Logger
+	err error
+}
+
+func (f *failingLogger) Log(context.Context, *auditrail.Entry) error {
+	return f.err
+}
+
+// fakeRedisStreams is an in-memory stand-in of the Redis streams commands,
+// supporting consumer groups with pending entries lists.
+type fakeRedisStreams struct {
+	adds    []*redis.XAddArgs
+	streams map[string][]redis.XMessage
+	groups  map[string]*fakeRedisGroup
+	seq     int
+	err     error
+	mu      sync.Mutex
+}
+
+type fakeRedisGroup struct {
+	last    int
+	pending map[string][]redis.XMessage
+}
+
+func newFakeRedisStreams() *fakeRedisStreams {
+	return &fakeRedisStreams{
+		streams: make(map[string][]redis.XMessage),
+		groups:  make(map[string]*fakeRedisGroup),
+	}
+}
+
+func (f *fakeRedisStreams) XAdd(_ context.Context, a *redis.XAddArgs) *redis.StringCmd {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if f.err != nil {
+		return redis.NewStringResult("", f.err)
+	}
+
+	f.adds = append(f.adds, a)
+	f.seq++
+
+	values := make(map[string]interface{})
+	raw := a.Values.([]string)
+
+	for i := 0; i < len(raw); i += 2 {
+		values[raw[i]] = raw[i+1]
+	}
+
+	id := fmt.Sprintf("%d-0", f.seq)
+	messages := append(f.streams[a.Stream], redis.XMessage{ID: id, Values: values})
+
+	if a.MaxLen > 0 && int64(len(messages)) > a.MaxLen {
+		messages = messages[int64(len(messages))-a.MaxLen:]
+	}
+
+	f.streams[a.Stream] = messages
+
+	return redis.NewStringResult(id, nil)
+}
+
+func (f *fakeRedisStreams) XGroupCreateMkStream(_ context.Context, stream, group, _ string) *redis.StatusCmd {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if _, ok := f.groups[stream+"/"+group]; ok {
+		return redis.NewStatusResult("", errors.New("BUSYGROUP Consumer Group name already exists"))
+	}
+
+	f.groups[stream+"/"+group] = &fakeRedisGroup{pending: make(map[string][]redis.XMessage)}
+
+	return redis.NewStatusResult("OK", nil)
+}
+
+func (f *fakeRedisStreams) XReadGroup(ctx context.Context, a *redis.XReadGroupArgs) *redis.XStreamSliceCmd {
+	f.mu.Lock()
+
+	stream, start := a.Streams[0], a.Streams[1]
+	g := f.groups[stream+"/"+a.Group]
+
+	var read []redis.XMessage
+
+	if start == "0" {
+		read = g.pending[a.Consumer]
+		if int64(len(read)) > a.Count {
+			read = read[:a.Count]
+		}
+	} else {
+		for _, m := range f.streams[stream] {
+			var seq int
+
+			_, _ = fmt.Sscanf(m.ID, "%d-0", &seq)
+
+			if seq > g.last && int64(len(read)) < a.Count {
+				read = append(read, m)
+				g.last = seq
+			}
+		}
+
+		g.pending[a.Consumer] = append(g.pending[a.Consumer], read...)
+	}
+
+	f.mu.Unlock()
+
+	if len(read) == 0 && start != "0" {
+		select {
+		case <-ctx.Done():
+			return redis.NewXStreamSliceCmdResult(nil, ctx.Err())
+		case <-time.After(a.Block):
+			return redis.NewXStreamSliceCmdResult(nil, redis.Nil)
+		}
+	}
+
+	return redis.NewXStreamSliceCmdResult([]redis.XStream{{Stream: stream, Messages: read}}, nil)
+}
+
+func (f *fakeRedisStreams) XAck(_ context.Context, stream, group string, ids ...string) *redis.IntCmd {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	acked := int64(0)
+	g := f.groups[stream+"/"+group]
+
+	for consumer, pending := range g.pending {
+		kept := pending[:0]
+
+		for _, m := range pending {
+			if m.ID == ids[0] {
+				acked++
+
+				continue
+			}
+
+			kept = append(kept, m)
+		}
+
+		g.pending[consumer] = kept
+	}
+
+	return redis.NewIntResult(acked, nil)
+}
+
+func (f *fakeRedisStreams) Messages(stream string) []redis.XMessage {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	return append([]redis.XMessage(nil), f.streams[stream]...)
+}
+
+func (f *fakeRedisStreams) Pending(stream, group, consumer string) []redis.XMessage {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	return append([]redis.XMessage(nil), f.groups[stream+"/"+group].pending[consumer]...)
+}
@@ -0,0 +1,226 @@
+package auditrail
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/sns"
+	"github.com/aws/aws-sdk-go-v2/service/sns/types"
+)
+
+// SNSAPI captures the SNS client part that we need.
+type SNSAPI interface {
+	Publish(ctx context.Context, params *sns.PublishInput, optFns ...func(*sns.Options)) (*sns.PublishOutput, error)
+	PublishBatch(ctx context.Context, params *sns.PublishBatchInput, optFns ...func(*sns.Options)) (*sns.PublishBatchOutput, error)
+}
+
+// SNSLoggerOption is a function that configures a SNS logger.
+type SNSLoggerOption func(options *snsLogger)
+
+var _ BatchLogger = (*snsLogger)(nil)
+
+type snsLogger struct {
+	client       SNSAPI
+	topicARN     string
+	messages     awsMessageBuilder
+	attempts     int
+	backoff      ExponentialBackoffConfig
+	closed       bool
+	closeChannel chan struct{}
+	mu           sync.RWMutex
+}
+
+// NewSNSLogger builds a new logger that publishes entries to a SNS topic as
+// JSON messages carrying the action and module of entries in the
+// [MessageAttributeAction] and [MessageAttributeModule] attributes, so
+// subscriptions can filter them. Single entries are published using Publish
+// and batches using PublishBatch; combine it with [NewBatcher] or [NewQueue]
+// so entries are published in batches.
+//
+// If the topic ARN ends with ".fifo", messages are published with the
+// idempotency ID of entries as deduplication ID and grouped as configured by
+// [WithSNSMessageGroup].
+//
+// Batches are split into requests of up to 10 messages and 256 KiB, and
+// failures are handled as described by [NewSQSLogger].
+func NewSNSLogger(client SNSAPI, topicARN string, options ...SNSLoggerOption) (BatchLogger, error) {
+	l := &snsLogger{
+		client:   client,
+		topicARN: topicARN,
+		messages: awsMessageBuilder{
+			encoder: NewJSONCodec(),
+			fifo:    strings.HasSuffix(topicARN, ".fifo"),
+			group:   MessageGroupByActorAndModule,
+		},
+		attempts:     3,
+		backoff:      defaultAWSBatchBackoff,
+		closeChannel: make(chan struct{}),
+	}
+
+	for _, option := range options {
+		option(l)
+	}
+
+	return l, nil
+}
+
+func (l *snsLogger) Log(ctx context.Context, entry *Entry) error {
+	if l.IsClosed() {
+		return ErrTrailClosed
+	}
+
+	m, err := l.messages.build(entry, 0)
+	if err != nil {
+		return err
+	}
+
+	_, err = l.client.Publish(ctx, &sns.PublishInput{
+		TopicArn:               &l.topicARN,
+		Message:                &m.body,
+		MessageAttributes:      snsAttributes(m.attributes),
+		MessageGroupId:         m.groupID,
+		MessageDeduplicationId: m.deduplicationID,
+	})
+	if err != nil {
+		return fmt.Errorf("%w: %w: could not publish entry to %s", ErrRetryable, err, l.topicARN)
+	}
+
+	return nil
+}
+
+func (l *snsLogger) LogBatch(ctx context.Context, entries []*Entry) []error {
+	if l.IsClosed() {
+		return batchErrors(len(entries), ErrTrailClosed)
+	}
+
+	return sendAWSMessages(ctx, l.messages, entries, awsBatchSender[awsMessage]{
+		limits:   awsMessageLimits,
+		attempts: l.attempts,
+		backoff:  l.backoff,
+		send:     l.publishBatch,
+	})
+}
+
+// publishBatch publishes the given messages in a single PublishBatch request,
+// returning the failure of every message.
+func (l *snsLogger) publishBatch(ctx context.Context, messages []awsMessage) ([]error, error) {
+	input := &sns.PublishBatchInput{
+		TopicArn:                   &l.topicARN,
+		PublishBatchRequestEntries: make([]types.PublishBatchRequestEntry, len(messages)),
+	}
+
+	for i, m := range messages {
+		input.PublishBatchRequestEntries[i] = types.PublishBatchRequestEntry{
+			Id:                     aws.String(m.id),
+			Message:                aws.String(m.body),
+			MessageAttributes:      snsAttributes(m.attributes),
+			MessageGroupId:         m.groupID,
+			MessageDeduplicationId: m.deduplicationID,
+		}
+	}
+
+	out, err := l.client.PublishBatch(ctx, input)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w: could not publish messages to %s", ErrRetryable, err, l.topicARN)
+	}
+
+	failures := make(map[string]error, len(messages))
+
+	for _, m := range messages {
+		failures[m.id] = fmt.Errorf("%w: missing sns message result", ErrRetryable)
+	}
+
+	for _, s := range out.Successful {
+		delete(failures, aws.ToString(s.Id))
+	}
+
+	for _, f := range out.Failed {
+		failures[aws.ToString(f.Id)] = awsMessageError("sns", f.SenderFault, f.Code, f.Message)
+	}
+
+	return awsMessageFailures(messages, failures), nil
+}
+
+func (l *snsLogger) Close() error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if l.closed {
+		return nil
+	}
+
+	l.closed = true
+
+	close(l.closeChannel)
+
+	return nil
+}
+
+func (l *snsLogger) Closed() <-chan struct{} {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+
+	return l.closeChannel
+}
+
+func (l *snsLogger) IsClosed() bool {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+
+	return l.closed
+}
+
+// snsAttributes returns the SNS message attributes of the given values.
+func snsAttributes(values map[string]string) map[string]types.MessageAttributeValue {
+	attributes := make(map[string]types.MessageAttributeValue, len(values))
+
+	for name, value := range values {
+		attributes[name] = types.MessageAttributeValue{
+			DataType:    aws.String("String"),
+			StringValue: aws.String(value),
+		}
+	}
+
+	return attributes
+}
+
+// WithSNSEncoder sets the encoder used to build the messages. Messages of
+// binary encoders are encoded as base64. If encoder is nil, entries are
+// encoded as JSON.
+func WithSNSEncoder(encoder Encoder) SNSLoggerOption {
+	return func(options *snsLogger) {
+		if encoder == nil {
+			encoder = NewJSONCodec()
+		}
+
+		options.messages.encoder = encoder
+	}
+}
+
+// WithSNSMessageGroup sets the function returning the message group ID of
+// entries published to FIFO topics. Defaults to
+// [MessageGroupByActorAndModule].
+func WithSNSMessageGroup(fn MessageGroupFunc) SNSLoggerOption {
+	return func(options *snsLogger) {
+		if fn != nil {
+			options.messages.group = fn
+		}
+	}
+}
+
+// WithSNSRetries sets how many times messages reported as failed by a
+// PublishBatch request are published, and the backoff between attempts.
+// Defaults to 3 attempts.
+func WithSNSRetries(attempts int, backoff ExponentialBackoffConfig) SNSLoggerOption {
+	return func(options *snsLogger) {
+		if attempts < 1 {
+			attempts = 1
+		}
+
+		options.attempts = attempts
+		options.backoff = backoff
+	}
+}
@@ -0,0 +1,135 @@
+package auditrail_test
+
+import (
+	"context"
+	"encoding/base64"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/sns"
+	"github.com/aws/aws-sdk-go-v2/service/sns/types"
+	"github.com/botchris/go-auditrail"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSNSLogger(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	const topic = "arn:aws:sns:eu-west-1:123:audit.fifo"
+
+	t.Run("GIVEN a FIFO topic WHEN logging an entry THEN it is published grouped, de-duplicated and with filtering attributes", func(t *testing.T) {
+		api := &fakeSNSAPI{}
+		logger, err := auditrail.NewSNSLogger(api, topic, auditrail.WithSNSMessageGroup(auditrail.MessageGroupByActor))
+		require.NoError(t, err)
+
+		entry := auditrail.NewEntry("john", "order_create", "orders")
+		require.NoError(t, logger.Log(ctx, entry))
+
+		require.Len(t, api.publishCalls, 1)
+
+		published := api.publishCalls[0]
+		require.Equal(t, topic, *published.TopicArn)
+		require.Contains(t, *published.Message, entry.GetIdempotencyID())
+		require.Equal(t, "john", *published.MessageGroupId)
+		require.Equal(t, entry.GetIdempotencyID(), *published.MessageDeduplicationId)
+		require.Equal(t, "order_create", *published.MessageAttributes[auditrail.MessageAttributeAction].StringValue)
+		require.Equal(t, "orders", *published.MessageAttributes[auditrail.MessageAttributeModule].StringValue)
+	})
+
+	t.Run("GIVEN a batch with a rejected message WHEN logging it THEN only that entry reports a permanent error", func(t *testing.T) {
+		api := &fakeSNSAPI{}
+		api.fail = func(e types.PublishBatchRequestEntry) *types.BatchResultErrorEntry {
+			if strings.Contains(*e.Message, `"actor":"invalid"`) {
+				return &types.BatchResultErrorEntry{Code: aws.String("InvalidParameter"), SenderFault: true}
+			}
+
+			return nil
+		}
+
+		logger, err := auditrail.NewSNSLogger(api, topic)
+		require.NoError(t, err)
+
+		entries := make([]*auditrail.Entry, 12)
+		for i := range entries {
+			entries[i] = newFakeEntry()
+		}
+
+		entries[11] = auditrail.NewEntry("invalid", "order_create", "orders")
+
+		errs := logger.LogBatch(ctx, entries)
+		require.Len(t, errs, 12)
+		require.ErrorIs(t, errs[11], auditrail.ErrPermanent)
+
+		for _, err := range errs[:11] {
+			require.NoError(t, err)
+		}
+
+		require.Len(t, api.batchCalls, 2)
+		require.Len(t, api.batchCalls[0].PublishBatchRequestEntries, 10)
+		require.Len(t, api.batchCalls[1].PublishBatchRequestEntries, 2)
+	})
+
+	t.Run("GIVEN a binary encoder WHEN logging an entry THEN the message is base64 encoded", func(t *testing.T) {
+		api := &fakeSNSAPI{}
+		logger, err := auditrail.NewSNSLogger(api, topic, auditrail.WithSNSEncoder(auditrail.NewProtobufCodec()))
+		require.NoError(t, err)
+
+		require.NoError(t, logger.Log(ctx, newFakeEntry()))
+
+		_, err = base64.StdEncoding.DecodeString(*api.publishCalls[0].Message)
+		require.NoError(t, err)
+	})
+
+	t.Run("GIVEN a closed logger WHEN logging THEN an error is returned", func(t *testing.T) {
+		logger, err := auditrail.NewSNSLogger(&fakeSNSAPI{}, topic)
+		require.NoError(t, err)
+
+		checkClose(t, ctx, logger)
+		require.ErrorIs(t, logger.Log(ctx, newFakeEntry()), auditrail.ErrTrailClosed)
+	})
+}
+
+// fakeSNSAPI records the requests it receives. Messages for which fail returns
+// an error entry are reported as failed.
+type fakeSNSAPI struct {
+	publishCalls []*sns.PublishInput
+	batchCalls   []*sns.PublishBatchInput
+	fail         func(e types.PublishBatchRequestEntry) *types.BatchResultErrorEntry
+	mu           sync.Mutex
+}
+
+func (f *fakeSNSAPI) Publish(_ context.Context, params *sns.PublishInput, _ ...func(*sns.Options)) (*sns.PublishOutput, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	f.publishCalls = append(f.publishCalls, params)
+
+	return &sns.PublishOutput{MessageId: aws.String("1")}, nil
+}
+
+func (f *fakeSNSAPI) PublishBatch(_ context.Context, params *sns.PublishBatchInput, _ ...func(*sns.Options)) (*sns.PublishBatchOutput, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	f.batchCalls = append(f.batchCalls, params)
+	out := &sns.PublishBatchOutput{}
+
+	for _, e := range params.PublishBatchRequestEntries {
+		if f.fail != nil {
+			if failure := f.fail(e); failure != nil {
+				failure.Id = e.Id
+				out.Failed = append(out.Failed, *failure)
+
+				continue
+			}
+		}
+
+		out.Successful = append(out.Successful, types.PublishBatchResultEntry{Id: e.Id, MessageId: aws.String("1")})
+	}
+
+	return out, nil
+}
@@ -0,0 +1,304 @@
+package auditrail
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// DefaultSQLTable is the default table of the SQL logger.
+const DefaultSQLTable = "audit_entries"
+
+// sqlColumns are the columns written by the SQL logger, in order.
+var sqlColumns = []string{
+	"idempotency_id",
+	"actor",
+	"action",
+	"module",
+	"correlation_id",
+	"causation_id",
+	"auth_method",
+	"occurred_at",
+	"details",
+}
+
+// sqlIdentifier matches the table names accepted by the SQL logger, which are
+// interpolated into statements. Names are kept short so the names of the
+// indexes derived from them fit the identifier limits of every database.
+var sqlIdentifier = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]{0,39}$`)
+
+// SQLDialect defines the SQL flavor spoken by a database.
+type SQLDialect int
+
+const (
+	// SQLDialectPostgres is the dialect of PostgreSQL 9.5 or later.
+	SQLDialectPostgres SQLDialect = iota + 1
+
+	// SQLDialectMySQL is the dialect of MySQL 5.7 or later.
+	SQLDialectMySQL
+
+	// SQLDialectSQLite is the dialect of SQLite 3.24 or later.
+	SQLDialectSQLite
+)
+
+// String returns the name of the dialect.
+func (d SQLDialect) String() string {
+	switch d {
+	case SQLDialectPostgres:
+		return "postgres"
+	case SQLDialectMySQL:
+		return "mysql"
+	case SQLDialectSQLite:
+		return "sqlite"
+	}
+
+	return "SQLDialect(" + strconv.Itoa(int(d)) + ")"
+}
+
+// valid reports whether d is a known dialect.
+func (d SQLDialect) valid() bool {
+	return d >= SQLDialectPostgres && d <= SQLDialectSQLite
+}
+
+// maxParameters returns the maximum number of parameters of a statement.
+// SQLite is limited to 999 parameters before 3.32.
+func (d SQLDialect) maxParameters() int {
+	if d == SQLDialectSQLite {
+		return 999
+	}
+
+	return 65535
+}
+
+// placeholder returns the placeholder of the n-th parameter of a statement,
+// starting at one.
+func (d SQLDialect) placeholder(n int) string {
+	if d == SQLDialectPostgres {
+		return "$" + strconv.Itoa(n)
+	}
+
+	return "?"
+}
+
+// insertIgnore returns a multi-row INSERT statement of the given columns
+// into table, for the given number of rows, that skips the rows whose unique
+// key already exists.
+func (d SQLDialect) insertIgnore(table string, columns []string, key string, rows int) string {
+	b := &strings.Builder{}
+
+	fmt.Fprintf(b, "INSERT INTO %s (%s) VALUES ", table, strings.Join(columns, ", "))
+
+	for r := 0; r < rows; r++ {
+		if r > 0 {
+			b.WriteString(", ")
+		}
+
+		b.WriteByte('(')
+
+		for c := range columns {
+			if c > 0 {
+				b.WriteString(", ")
+			}
+
+			b.WriteString(d.placeholder(r*len(columns) + c + 1))
+		}
+
+		b.WriteByte(')')
+	}
+
+	if d == SQLDialectMySQL {
+		// Unlike INSERT IGNORE, other errors such as truncations still fail.
+		fmt.Fprintf(b, " ON DUPLICATE KEY UPDATE %s = %s", key, key)
+	} else {
+		fmt.Fprintf(b, " ON CONFLICT (%s) DO NOTHING", key)
+	}
+
+	return b.String()
+}
+
+// SQLLoggerOption is a function that configures a SQL logger.
+type SQLLoggerOption func(options *sqlLogger)
+
+var _ BatchLogger = (*sqlLogger)(nil)
+
+type sqlLogger struct {
+	db           *sql.DB
+	dialect      SQLDialect
+	table        string
+	batchSize    int
+	closed       bool
+	closeChannel chan struct{}
+	mu           sync.RWMutex
+}
+
+// NewSQLLogger builds a new logger that inserts entries into a table of a SQL
+// database, as created by [MigrateSQL]. Batches are inserted using multi-row
+// INSERT statements of up to 500 rows, or 111 rows on SQLite to stay within
+// its parameter limit; combine it with [NewBatcher] or [NewQueue] so entries
+// are inserted in batches.
+//
+// The idempotency ID of entries is a unique key of the table, and entries
+// whose idempotency ID was already inserted are silently skipped, so writes
+// can be safely retried. Database errors wrap [ErrRetryable].
+//
+// The database driver is up to the caller, e.g. github.com/jackc/pgx for
+// PostgreSQL, github.com/go-sql-driver/mysql for MySQL, which requires the
+// parseTime parameter, or modernc.org/sqlite for SQLite.
+func NewSQLLogger(db *sql.DB, dialect SQLDialect, options ...SQLLoggerOption) (BatchLogger, error) {
+	l := &sqlLogger{
+		db:           db,
+		dialect:      dialect,
+		table:        DefaultSQLTable,
+		batchSize:    500,
+		closeChannel: make(chan struct{}),
+	}
+
+	for _, option := range options {
+		option(l)
+	}
+
+	if !dialect.valid() {
+		return nil, fmt.Errorf("unknown sql dialect %s", dialect)
+	}
+
+	if !sqlIdentifier.MatchString(l.table) {
+		return nil, fmt.Errorf("invalid sql table name %q", l.table)
+	}
+
+	return l, nil
+}
+
+func (l *sqlLogger) Log(ctx context.Context, entry *Entry) error {
+	if errs := l.LogBatch(ctx, []*Entry{entry}); errs != nil {
+		return errs[0]
+	}
+
+	return nil
+}
+
+func (l *sqlLogger) LogBatch(ctx context.Context, entries []*Entry) []error {
+	if l.IsClosed() {
+		return batchErrors(len(entries), ErrTrailClosed)
+	}
+
+	var errs []error
+
+	rows := make([]int, 0, len(entries))
+	args := make([]interface{}, 0, len(entries)*len(sqlColumns))
+
+	for i, entry := range entries {
+		values, err := sqlValues(entry)
+		if err != nil {
+			errs = setBatchError(errs, len(entries), i, err)
+
+			continue
+		}
+
+		rows = append(rows, i)
+		args = append(args, values...)
+	}
+
+	for len(rows) > 0 {
+		n := min(len(rows), l.batchSize, l.dialect.maxParameters()/len(sqlColumns))
+		query := l.dialect.insertIgnore(l.table, sqlColumns, "idempotency_id", n)
+
+		if _, err := l.db.ExecContext(ctx, query, args[:n*len(sqlColumns)]...); err != nil {
+			err = fmt.Errorf("%w: %w: could not insert entries into %s", ErrRetryable, err, l.table)
+
+			for _, i := range rows[:n] {
+				errs = setBatchError(errs, len(entries), i, err)
+			}
+		}
+
+		rows = rows[n:]
+		args = args[n*len(sqlColumns):]
+	}
+
+	return errs
+}
+
+func (l *sqlLogger) Close() error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if l.closed {
+		return nil
+	}
+
+	l.closed = true
+
+	close(l.closeChannel)
+
+	return nil
+}
+
+func (l *sqlLogger) Closed() <-chan struct{} {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+
+	return l.closeChannel
+}
+
+func (l *sqlLogger) IsClosed() bool {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+
+	return l.closed
+}
+
+// sqlValues returns the values of the columns of the given entry, in the order
+// of sqlColumns.
+func sqlValues(entry *Entry) ([]interface{}, error) {
+	var details sql.NullString
+
+	if d := entry.GetDetails(); len(d) > 0 {
+		raw, err := json.Marshal(d)
+		if err != nil {
+			return nil, fmt.Errorf("%w: %w: could not encode entry details", ErrPermanent, err)
+		}
+
+		details = sql.NullString{String: string(raw), Valid: true}
+	}
+
+	nullable := func(value string) sql.NullString {
+		return sql.NullString{String: value, Valid: value != ""}
+	}
+
+	return []interface{}{
+		entry.GetIdempotencyID(),
+		entry.GetActor(),
+		entry.GetAction(),
+		entry.GetModule(),
+		nullable(entry.GetCorrelationID()),
+		nullable(entry.GetCausationID()),
+		nullable(entry.GetAuthMethod()),
+		entry.GetOccurredAt().UTC(),
+		details,
+	}, nil
+}
+
+// WithSQLTable sets the table entries are inserted into. Defaults to
+// [DefaultSQLTable].
+func WithSQLTable(table string) SQLLoggerOption {
+	return func(options *sqlLogger) {
+		if table != "" {
+			options.table = table
+		}
+	}
+}
+
+// WithSQLBatchSize sets the maximum number of rows of each INSERT statement.
+// Defaults to 500. Statements are further capped by the parameter limit of
+// the dialect, as each row takes 9 parameters.
+func WithSQLBatchSize(size int) SQLLoggerOption {
+	return func(options *sqlLogger) {
+		if size > 0 {
+			options.batchSize = size
+		}
+	}
+}
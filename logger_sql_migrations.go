@@ -0,0 +1,197 @@
+package auditrail
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// sqlMigration is a versioned change of the schema of a table. Its
+// statements must be safe to run again, as MySQL does not roll back schema
+// changes.
+type sqlMigration struct {
+	version    int
+	statements func(d SQLDialect, table string) []string
+}
+
+// sqlMigrations are the migrations of the schema of the SQL logger, sorted by
+// version. Released migrations must never be changed; add new ones instead.
+var sqlMigrations = []sqlMigration{
+	{version: 1, statements: sqlCreateEntriesTable},
+}
+
+// sqlTypes are the column types of a dialect.
+type sqlTypes struct {
+	// id is the type of auto-incremented primary keys.
+	id string
+
+	// timestamp is the type of points in time, with microsecond precision.
+	timestamp string
+
+	// json is the type of JSON documents.
+	json string
+
+	// text is the type of unbounded strings.
+	text string
+}
+
+// types returns the column types of the dialect.
+func (d SQLDialect) types() sqlTypes {
+	switch d {
+	case SQLDialectMySQL:
+		return sqlTypes{id: "BIGINT AUTO_INCREMENT PRIMARY KEY", timestamp: "DATETIME(6)", json: "JSON", text: "MEDIUMTEXT"}
+	case SQLDialectSQLite:
+		return sqlTypes{id: "INTEGER PRIMARY KEY AUTOINCREMENT", timestamp: "DATETIME", json: "TEXT", text: "TEXT"}
+	}
+
+	return sqlTypes{id: "BIGSERIAL PRIMARY KEY", timestamp: "TIMESTAMPTZ", json: "JSONB", text: "TEXT"}
+}
+
+// sqlIndexedColumns are the columns of the entries table with an index.
+var sqlIndexedColumns = []string{"actor", "action", "module", "correlation_id", "occurred_at"}
+
+// sqlCreateEntriesTable returns the statements creating the entries table.
+func sqlCreateEntriesTable(d SQLDialect, table string) []string {
+	t := d.types()
+
+	columns := []string{
+		"id " + t.id,
+		"idempotency_id VARCHAR(255) NOT NULL",
+		"actor VARCHAR(255) NOT NULL",
+		"action VARCHAR(255) NOT NULL",
+		"module VARCHAR(255) NOT NULL",
+		"correlation_id VARCHAR(255)",
+		"causation_id VARCHAR(255)",
+		"auth_method VARCHAR(64)",
+		"occurred_at " + t.timestamp + " NOT NULL",
+		"details " + t.json,
+		fmt.Sprintf("CONSTRAINT %s_idempotency_id_key UNIQUE (idempotency_id)", table),
+	}
+
+	// MySQL lacks CREATE INDEX IF NOT EXISTS, so indexes are created along
+	// with the table.
+	if d == SQLDialectMySQL {
+		for _, column := range sqlIndexedColumns {
+			columns = append(columns, fmt.Sprintf("INDEX %s_%s_idx (%s)", table, column, column))
+		}
+	}
+
+	statements := []string{
+		fmt.Sprintf("CREATE TABLE IF NOT EXISTS %s (\n\t%s\n)", table, strings.Join(columns, ",\n\t")),
+	}
+
+	if d != SQLDialectMySQL {
+		for _, column := range sqlIndexedColumns {
+			statements = append(statements, fmt.Sprintf("CREATE INDEX IF NOT EXISTS %s_%s_idx ON %s (%s)", table, column, table, column))
+		}
+	}
+
+	return statements
+}
+
+// MigrateSQL creates or migrates the table used by [NewSQLLogger] to store
+// entries, which has indexed columns for the actor, action, module,
+// correlation ID and occurrence time of entries, the details of entries as
+// JSON and the idempotency ID of entries as unique key. If table is empty,
+// [DefaultSQLTable] is used.
+//
+// Applied migrations are recorded in a table named after the given one,
+// followed by "_migrations". Migrations are meant to be run from a single
+// process, e.g. at deployment time.
+func MigrateSQL(ctx context.Context, db *sql.DB, dialect SQLDialect, table string) error {
+	if table == "" {
+		table = DefaultSQLTable
+	}
+
+	return migrateSQL(ctx, db, dialect, table, sqlMigrations)
+}
+
+// migrateSQL applies the given migrations of table that were not applied
+// yet.
+func migrateSQL(ctx context.Context, db *sql.DB, dialect SQLDialect, table string, migrations []sqlMigration) error {
+	if !dialect.valid() {
+		return fmt.Errorf("unknown sql dialect %s", dialect)
+	}
+
+	if !sqlIdentifier.MatchString(table) {
+		return fmt.Errorf("invalid sql table name %q", table)
+	}
+
+	applied, err := sqlAppliedMigrations(ctx, db, dialect, table+"_migrations")
+	if err != nil {
+		return err
+	}
+
+	for _, m := range migrations {
+		if applied[m.version] {
+			continue
+		}
+
+		if err = sqlApplyMigration(ctx, db, dialect, table, m); err != nil {
+			return fmt.Errorf("%w: could not apply migration %d of %s", err, m.version, table)
+		}
+	}
+
+	return nil
+}
+
+// sqlAppliedMigrations returns the versions of the migrations recorded in the
+// given table, creating it if it does not exist.
+func sqlAppliedMigrations(ctx context.Context, db *sql.DB, dialect SQLDialect, table string) (map[int]bool, error) {
+	create := fmt.Sprintf("CREATE TABLE IF NOT EXISTS %s (version INTEGER PRIMARY KEY, applied_at %s NOT NULL)", table, dialect.types().timestamp)
+	if _, err := db.ExecContext(ctx, create); err != nil {
+		return nil, fmt.Errorf("%w: could not create table %s", err, table)
+	}
+
+	rows, err := db.QueryContext(ctx, fmt.Sprintf("SELECT version FROM %s", table))
+	if err != nil {
+		return nil, fmt.Errorf("%w: could not read table %s", err, table)
+	}
+
+	defer rows.Close()
+
+	applied := make(map[int]bool)
+
+	for rows.Next() {
+		var version int
+		if err = rows.Scan(&version); err != nil {
+			return nil, fmt.Errorf("%w: could not read table %s", err, table)
+		}
+
+		applied[version] = true
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("%w: could not read table %s", err, table)
+	}
+
+	return applied, nil
+}
+
+// sqlApplyMigration runs the statements of the given migration and records
+// it, within a transaction.
+func sqlApplyMigration(ctx context.Context, db *sql.DB, dialect SQLDialect, table string, m sqlMigration) error {
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+
+	defer func() { _ = tx.Rollback() }()
+
+	for _, statement := range m.statements(dialect, table) {
+		if _, err = tx.ExecContext(ctx, statement); err != nil {
+			return err
+		}
+	}
+
+	record := fmt.Sprintf("INSERT INTO %s_migrations (version, applied_at) VALUES (%s, %s)",
+		table, dialect.placeholder(1), dialect.placeholder(2))
+
+	if _, err = tx.ExecContext(ctx, record, m.version, time.Now().UTC()); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
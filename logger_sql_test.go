@@ -0,0 +1,195 @@
+package auditrail_test
+
+import (
+	"context"
+	"database/sql"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/botchris/go-auditrail"
+	"github.com/stretchr/testify/require"
+	"modernc.org/sqlite"
+	sqlite3 "modernc.org/sqlite/lib"
+)
+
+func TestSQLLogger(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	t.Run("GIVEN a database WHEN migrating it twice THEN the entries table and its indexes are created once", func(t *testing.T) {
+		db := newSQLiteDB(t)
+
+		require.NoError(t, auditrail.MigrateSQL(ctx, db, auditrail.SQLDialectSQLite, ""))
+		require.NoError(t, auditrail.MigrateSQL(ctx, db, auditrail.SQLDialectSQLite, ""))
+
+		var versions int
+		require.NoError(t, db.QueryRowContext(ctx, "SELECT COUNT(*) FROM audit_entries_migrations").Scan(&versions))
+		require.Equal(t, 1, versions)
+
+		rows, err := db.QueryContext(ctx, "SELECT name FROM sqlite_master WHERE type = 'index' AND tbl_name = 'audit_entries' AND sql IS NOT NULL")
+		require.NoError(t, err)
+
+		defer rows.Close()
+
+		var indexes []string
+
+		for rows.Next() {
+			var name string
+			require.NoError(t, rows.Scan(&name))
+			indexes = append(indexes, name)
+		}
+
+		require.ElementsMatch(t, []string{
+			"audit_entries_actor_idx",
+			"audit_entries_action_idx",
+			"audit_entries_module_idx",
+			"audit_entries_correlation_id_idx",
+			"audit_entries_occurred_at_idx",
+		}, indexes)
+	})
+
+	t.Run("GIVEN a sql logger WHEN logging an entry THEN it is stored in its columns", func(t *testing.T) {
+		db := newSQLiteDB(t)
+		require.NoError(t, auditrail.MigrateSQL(ctx, db, auditrail.SQLDialectSQLite, ""))
+
+		logger, err := auditrail.NewSQLLogger(db, auditrail.SQLDialectSQLite)
+		require.NoError(t, err)
+
+		defer checkClose(t, ctx, logger)
+
+		at := time.Date(2026, 10, 17, 10, 30, 0, 0, time.UTC)
+		entry := auditrail.NewEntry("john", "order_create", "orders").
+			WithCorrelation("flow-1").
+			WithOccurredAt(at).
+			AppendDetails("order_id", "1234")
+
+		require.NoError(t, logger.Log(ctx, entry))
+
+		var (
+			actor, action, module string
+			correlationID         string
+			causationID           sql.NullString
+			occurredAt            time.Time
+			details               string
+		)
+
+		require.NoError(t, db.QueryRowContext(ctx,
+			"SELECT actor, action, module, correlation_id, causation_id, occurred_at, details FROM audit_entries WHERE idempotency_id = ?",
+			entry.GetIdempotencyID(),
+		).Scan(&actor, &action, &module, &correlationID, &causationID, &occurredAt, &details))
+
+		require.Equal(t, "john", actor)
+		require.Equal(t, "order_create", action)
+		require.Equal(t, "orders", module)
+		require.Equal(t, "flow-1", correlationID)
+		require.False(t, causationID.Valid)
+		require.True(t, at.Equal(occurredAt))
+		require.JSONEq(t, `{"order_id":"1234"}`, details)
+	})
+
+	t.Run("GIVEN a batch with duplicates WHEN logging it THEN every entry is stored once", func(t *testing.T) {
+		db := newSQLiteDB(t)
+		require.NoError(t, auditrail.MigrateSQL(ctx, db, auditrail.SQLDialectSQLite, "trail"))
+
+		logger, err := auditrail.NewSQLLogger(db, auditrail.SQLDialectSQLite,
+			auditrail.WithSQLTable("trail"),
+			auditrail.WithSQLBatchSize(2),
+		)
+		require.NoError(t, err)
+
+		defer checkClose(t, ctx, logger)
+
+		entries := make([]*auditrail.Entry, 5)
+		for i := range entries {
+			entries[i] = newFakeEntry()
+		}
+
+		require.Nil(t, logger.LogBatch(ctx, entries))
+		require.Nil(t, logger.LogBatch(ctx, append(entries[3:], newFakeEntry())))
+		require.NoError(t, logger.Log(ctx, entries[0]))
+
+		var count int
+		require.NoError(t, db.QueryRowContext(ctx, "SELECT COUNT(*) FROM trail").Scan(&count))
+		require.Equal(t, 6, count)
+	})
+
+	t.Run("GIVEN a sqlite database limited to 999 parameters WHEN logging a large batch THEN every entry is stored", func(t *testing.T) {
+		db := newSQLiteDB(t)
+		db.SetMaxOpenConns(1)
+
+		conn, err := db.Conn(ctx)
+		require.NoError(t, err)
+
+		// the default limit of SQLite before 3.32.
+		_, err = sqlite.Limit(conn, sqlite3.SQLITE_LIMIT_VARIABLE_NUMBER, 999)
+		require.NoError(t, err)
+		require.NoError(t, conn.Close())
+
+		require.NoError(t, auditrail.MigrateSQL(ctx, db, auditrail.SQLDialectSQLite, ""))
+
+		logger, err := auditrail.NewSQLLogger(db, auditrail.SQLDialectSQLite)
+		require.NoError(t, err)
+
+		defer checkClose(t, ctx, logger)
+
+		entries := make([]*auditrail.Entry, 250)
+		for i := range entries {
+			entries[i] = newFakeEntry()
+		}
+
+		require.Nil(t, logger.LogBatch(ctx, entries))
+
+		var count int
+		require.NoError(t, db.QueryRowContext(ctx, "SELECT COUNT(*) FROM "+auditrail.DefaultSQLTable).Scan(&count))
+		require.Equal(t, 250, count)
+	})
+
+	t.Run("GIVEN an unavailable database WHEN logging a batch THEN every entry reports a retryable error", func(t *testing.T) {
+		db := newSQLiteDB(t)
+		require.NoError(t, auditrail.MigrateSQL(ctx, db, auditrail.SQLDialectSQLite, ""))
+
+		logger, err := auditrail.NewSQLLogger(db, auditrail.SQLDialectSQLite)
+		require.NoError(t, err)
+
+		defer checkClose(t, ctx, logger)
+
+		require.NoError(t, db.Close())
+
+		errs := logger.LogBatch(ctx, []*auditrail.Entry{newFakeEntry(), newFakeEntry()})
+		require.Len(t, errs, 2)
+
+		for _, err := range errs {
+			require.ErrorIs(t, err, auditrail.ErrRetryable)
+		}
+	})
+
+	t.Run("GIVEN an invalid table name WHEN building a logger THEN an error is returned", func(t *testing.T) {
+		_, err := auditrail.NewSQLLogger(newSQLiteDB(t), auditrail.SQLDialectSQLite, auditrail.WithSQLTable("audit; DROP TABLE users"))
+		require.Error(t, err)
+
+		_, err = auditrail.NewSQLLogger(newSQLiteDB(t), auditrail.SQLDialect(0))
+		require.Error(t, err)
+	})
+
+	t.Run("GIVEN a closed logger WHEN logging THEN an error is returned", func(t *testing.T) {
+		logger, err := auditrail.NewSQLLogger(newSQLiteDB(t), auditrail.SQLDialectSQLite)
+		require.NoError(t, err)
+
+		checkClose(t, ctx, logger)
+		require.ErrorIs(t, logger.Log(ctx, newFakeEntry()), auditrail.ErrTrailClosed)
+	})
+}
+
+// newSQLiteDB opens a SQLite database in a temporary directory, waiting for
+// locks held by concurrent writers.
+func newSQLiteDB(t *testing.T) *sql.DB {
+	t.Helper()
+
+	db, err := sql.Open("sqlite", filepath.Join(t.TempDir(), "audit.db")+"?_pragma=busy_timeout(5000)&_txlock=immediate")
+	require.NoError(t, err)
+
+	t.Cleanup(func() { _ = db.Close() })
+
+	return db
+}
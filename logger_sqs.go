@@ -0,0 +1,424 @@
+package auditrail
+
+import (
+	"context"
+	"encoding/base64"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/sqs"
+	"github.com/aws/aws-sdk-go-v2/service/sqs/types"
+)
+
+const (
+	// MessageAttributeAction is the message attribute carrying the action of
+	// entries sent to SQS and SNS, so subscriptions can filter on it.
+	MessageAttributeAction = "Action"
+
+	// MessageAttributeModule is the message attribute carrying the module of
+	// entries sent to SQS and SNS, so subscriptions can filter on it.
+	MessageAttributeModule = "Module"
+)
+
+const (
+	// awsMaxMessagesPerCall is the maximum number of messages of a
+	// SendMessageBatch or PublishBatch request.
+	awsMaxMessagesPerCall = 10
+
+	// awsMaxBytesPerCall is the maximum size of a SendMessageBatch or
+	// PublishBatch request, which is also the maximum size of a message,
+	// including its attributes.
+	awsMaxBytesPerCall = 256 << 10
+
+	// awsMaxMessageIDSize is the maximum size of message group and
+	// deduplication IDs.
+	awsMaxMessageIDSize = 128
+)
+
+// MessageGroupFunc returns the message group ID of an entry sent to a FIFO
+// queue or topic. Entries of the same group are delivered in order.
+type MessageGroupFunc func(entry *Entry) string
+
+// MessageGroupByActor groups entries by actor, so the entries of each actor
+// are delivered in order.
+func MessageGroupByActor(entry *Entry) string {
+	return entry.GetActor()
+}
+
+// MessageGroupByModule groups entries by module, so the entries of each module
+// are delivered in order.
+func MessageGroupByModule(entry *Entry) string {
+	return entry.GetModule()
+}
+
+// MessageGroupByActorAndModule groups entries by actor and module. This is the
+// default message group of FIFO queues and topics.
+func MessageGroupByActorAndModule(entry *Entry) string {
+	return entry.GetActor() + "/" + entry.GetModule()
+}
+
+// SQSAPI captures the SQS client part that we need.
+type SQSAPI interface {
+	SendMessage(ctx context.Context, params *sqs.SendMessageInput, optFns ...func(*sqs.Options)) (*sqs.SendMessageOutput, error)
+	SendMessageBatch(ctx context.Context, params *sqs.SendMessageBatchInput, optFns ...func(*sqs.Options)) (*sqs.SendMessageBatchOutput, error)
+}
+
+// SQSLoggerOption is a function that configures a SQS logger.
+type SQSLoggerOption func(options *sqsLogger)
+
+var _ BatchLogger = (*sqsLogger)(nil)
+
+type sqsLogger struct {
+	client       SQSAPI
+	queueURL     string
+	messages     awsMessageBuilder
+	attempts     int
+	backoff      ExponentialBackoffConfig
+	closed       bool
+	closeChannel chan struct{}
+	mu           sync.RWMutex
+}
+
+// NewSQSLogger builds a new logger that sends entries to a SQS queue as JSON
+// messages carrying the action and module of entries in the
+// [MessageAttributeAction] and [MessageAttributeModule] attributes. Single
+// entries are sent using SendMessage and batches using SendMessageBatch;
+// combine it with [NewBatcher] or [NewQueue] so entries are sent in batches.
+//
+// If the queue URL ends with ".fifo", messages are sent with the idempotency
+// ID of entries as deduplication ID and grouped as configured by
+// [WithSQSMessageGroup].
+//
+// Batches are split into requests of up to 10 messages and 256 KiB. Messages
+// reported as failed by SQS are retried on their own, as configured by
+// [WithSQSRetries], unless the failure is caused by the message itself, in
+// which case the entry is reported with an error wrapping [ErrPermanent].
+// Messages still failing afterward are reported per entry with an error
+// wrapping [ErrRetryable].
+func NewSQSLogger(client SQSAPI, queueURL string, options ...SQSLoggerOption) (BatchLogger, error) {
+	l := &sqsLogger{
+		client:   client,
+		queueURL: queueURL,
+		messages: awsMessageBuilder{
+			encoder: NewJSONCodec(),
+			fifo:    strings.HasSuffix(queueURL, ".fifo"),
+			group:   MessageGroupByActorAndModule,
+		},
+		attempts:     3,
+		backoff:      defaultAWSBatchBackoff,
+		closeChannel: make(chan struct{}),
+	}
+
+	for _, option := range options {
+		option(l)
+	}
+
+	return l, nil
+}
+
+func (l *sqsLogger) Log(ctx context.Context, entry *Entry) error {
+	if l.IsClosed() {
+		return ErrTrailClosed
+	}
+
+	m, err := l.messages.build(entry, 0)
+	if err != nil {
+		return err
+	}
+
+	_, err = l.client.SendMessage(ctx, &sqs.SendMessageInput{
+		QueueUrl:               &l.queueURL,
+		MessageBody:            &m.body,
+		MessageAttributes:      sqsAttributes(m.attributes),
+		MessageGroupId:         m.groupID,
+		MessageDeduplicationId: m.deduplicationID,
+	})
+	if err != nil {
+		return fmt.Errorf("%w: %w: could not send entry to %s", ErrRetryable, err, l.queueURL)
+	}
+
+	return nil
+}
+
+func (l *sqsLogger) LogBatch(ctx context.Context, entries []*Entry) []error {
+	if l.IsClosed() {
+		return batchErrors(len(entries), ErrTrailClosed)
+	}
+
+	return sendAWSMessages(ctx, l.messages, entries, awsBatchSender[awsMessage]{
+		limits:   awsMessageLimits,
+		attempts: l.attempts,
+		backoff:  l.backoff,
+		send:     l.sendMessageBatch,
+	})
+}
+
+// sendMessageBatch sends the given messages in a single SendMessageBatch
+// request, returning the failure of every message.
+func (l *sqsLogger) sendMessageBatch(ctx context.Context, messages []awsMessage) ([]error, error) {
+	input := &sqs.SendMessageBatchInput{
+		QueueUrl: &l.queueURL,
+		Entries:  make([]types.SendMessageBatchRequestEntry, len(messages)),
+	}
+
+	for i, m := range messages {
+		input.Entries[i] = types.SendMessageBatchRequestEntry{
+			Id:                     aws.String(m.id),
+			MessageBody:            aws.String(m.body),
+			MessageAttributes:      sqsAttributes(m.attributes),
+			MessageGroupId:         m.groupID,
+			MessageDeduplicationId: m.deduplicationID,
+		}
+	}
+
+	out, err := l.client.SendMessageBatch(ctx, input)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w: could not send messages to %s", ErrRetryable, err, l.queueURL)
+	}
+
+	failures := make(map[string]error, len(messages))
+
+	for _, m := range messages {
+		failures[m.id] = fmt.Errorf("%w: missing sqs message result", ErrRetryable)
+	}
+
+	for _, s := range out.Successful {
+		delete(failures, aws.ToString(s.Id))
+	}
+
+	for _, f := range out.Failed {
+		failures[aws.ToString(f.Id)] = awsMessageError("sqs", f.SenderFault, f.Code, f.Message)
+	}
+
+	return awsMessageFailures(messages, failures), nil
+}
+
+func (l *sqsLogger) Close() error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if l.closed {
+		return nil
+	}
+
+	l.closed = true
+
+	close(l.closeChannel)
+
+	return nil
+}
+
+func (l *sqsLogger) Closed() <-chan struct{} {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+
+	return l.closeChannel
+}
+
+func (l *sqsLogger) IsClosed() bool {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+
+	return l.closed
+}
+
+// sqsAttributes returns the SQS message attributes of the given values.
+func sqsAttributes(values map[string]string) map[string]types.MessageAttributeValue {
+	attributes := make(map[string]types.MessageAttributeValue, len(values))
+
+	for name, value := range values {
+		attributes[name] = types.MessageAttributeValue{
+			DataType:    aws.String("String"),
+			StringValue: aws.String(value),
+		}
+	}
+
+	return attributes
+}
+
+// awsMessage is a message sent to SQS or SNS, carrying a single entry.
+type awsMessage struct {
+	entry           int
+	id              string
+	body            string
+	attributes      map[string]string
+	groupID         *string
+	deduplicationID *string
+}
+
+// size returns the size the message counts against the request limits.
+func (m awsMessage) size() int {
+	size := len(m.body)
+
+	for name, value := range m.attributes {
+		size += len(name) + len("String") + len(value)
+	}
+
+	return size
+}
+
+// batchEntries returns the index of the entry carried by the message.
+func (m awsMessage) batchEntries() []int {
+	return []int{m.entry}
+}
+
+// awsMessageBuilder builds the SQS and SNS messages of entries.
+type awsMessageBuilder struct {
+	encoder Encoder
+	fifo    bool
+	group   MessageGroupFunc
+}
+
+// build returns the message of the given entry, identified within a batch by
+// its index i.
+func (b awsMessageBuilder) build(entry *Entry, i int) (awsMessage, error) {
+	data, err := b.encoder.Encode(entry)
+	if err != nil {
+		return awsMessage{}, fmt.Errorf("%w: %w: could not encode entry", ErrPermanent, err)
+	}
+
+	body := string(data)
+
+	// Message bodies must be text, so binary payloads are sent as base64.
+	if isBinaryEncoder(b.encoder) {
+		body = base64.StdEncoding.EncodeToString(data)
+	}
+
+	m := awsMessage{
+		entry:      i,
+		id:         strconv.Itoa(i),
+		body:       body,
+		attributes: make(map[string]string, 2),
+	}
+
+	// Empty attribute values are rejected, so they are left out.
+	if action := entry.GetAction(); action != "" {
+		m.attributes[MessageAttributeAction] = action
+	}
+
+	if module := entry.GetModule(); module != "" {
+		m.attributes[MessageAttributeModule] = module
+	}
+
+	if b.fifo {
+		m.groupID = aws.String(awsMessageID(b.group(entry)))
+		m.deduplicationID = aws.String(awsMessageID(entry.GetIdempotencyID()))
+	}
+
+	if size := m.size(); size > awsMaxBytesPerCall {
+		return awsMessage{}, fmt.Errorf("%w: message exceeds the 256 KiB limit: entry %s takes %d bytes",
+			ErrPermanent, entry.GetIdempotencyID(), size)
+	}
+
+	return m, nil
+}
+
+// awsMessageID returns the given value as a valid message group or
+// deduplication ID, which are made of up to 128 printable ASCII characters.
+func awsMessageID(value string) string {
+	id := strings.Map(func(r rune) rune {
+		if r < '!' || r > '~' {
+			return '_'
+		}
+
+		return r
+	}, value)
+
+	if len(id) > awsMaxMessageIDSize {
+		id = id[:awsMaxMessageIDSize]
+	}
+
+	if id == "" {
+		id = "_"
+	}
+
+	return id
+}
+
+// awsMessageError returns the error of a message reported as failed by a
+// batch request. Failures caused by the message itself are permanent.
+func awsMessageError(service string, senderFault bool, code, message *string) error {
+	reason := ErrRetryable
+	if senderFault {
+		reason = ErrPermanent
+	}
+
+	return fmt.Errorf("%w: %s message failed: %s: %s", reason, service, aws.ToString(code), aws.ToString(message))
+}
+
+// sendAWSMessages sends the messages of the given entries in batches using
+// sender.
+func sendAWSMessages(ctx context.Context, builder awsMessageBuilder, entries []*Entry, sender awsBatchSender[awsMessage]) []error {
+	var errs []error
+
+	messages := make([]awsMessage, 0, len(entries))
+
+	for i, entry := range entries {
+		m, err := builder.build(entry, i)
+		if err != nil {
+			errs = setBatchError(errs, len(entries), i, err)
+
+			continue
+		}
+
+		messages = append(messages, m)
+	}
+
+	return sender.sendAll(ctx, messages, errs, len(entries))
+}
+
+// awsMessageFailures returns the failure of every given message out of the
+// failures reported by a batch request by message ID.
+func awsMessageFailures(messages []awsMessage, failures map[string]error) []error {
+	errs := make([]error, len(messages))
+
+	for i, m := range messages {
+		errs[i] = failures[m.id]
+	}
+
+	return errs
+}
+
+// awsMessageLimits are the limits of SendMessageBatch and PublishBatch
+// requests.
+var awsMessageLimits = awsBatchLimits{items: awsMaxMessagesPerCall, bytes: awsMaxBytesPerCall}
+
+// WithSQSEncoder sets the encoder used to build the message bodies. Bodies
+// of binary encoders are encoded as base64. If encoder is nil, entries are
+// encoded as JSON.
+func WithSQSEncoder(encoder Encoder) SQSLoggerOption {
+	return func(options *sqsLogger) {
+		if encoder == nil {
+			encoder = NewJSONCodec()
+		}
+
+		options.messages.encoder = encoder
+	}
+}
+
+// WithSQSMessageGroup sets the function returning the message group ID of
+// entries sent to FIFO queues. Defaults to [MessageGroupByActorAndModule].
+func WithSQSMessageGroup(fn MessageGroupFunc) SQSLoggerOption {
+	return func(options *sqsLogger) {
+		if fn != nil {
+			options.messages.group = fn
+		}
+	}
+}
+
+// WithSQSRetries sets how many times messages reported as failed by a
+// SendMessageBatch request are sent, and the backoff between attempts.
+// Defaults to 3 attempts.
+func WithSQSRetries(attempts int, backoff ExponentialBackoffConfig) SQSLoggerOption {
+	return func(options *sqsLogger) {
+		if attempts < 1 {
+			attempts = 1
+		}
+
+		options.attempts = attempts
+		options.backoff = backoff
+	}
+}
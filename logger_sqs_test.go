@@ -0,0 +1,211 @@
+package auditrail_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/sqs"
+	"github.com/aws/aws-sdk-go-v2/service/sqs/types"
+	"github.com/botchris/go-auditrail"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSQSLogger(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	fastRetries := auditrail.WithSQSRetries(3, auditrail.ExponentialBackoffConfig{
+		Base:   time.Millisecond,
+		Factor: time.Millisecond,
+		Max:    time.Millisecond,
+	})
+
+	t.Run("GIVEN a sqs logger WHEN logging an entry THEN a JSON message with filtering attributes is sent", func(t *testing.T) {
+		api := &fakeSQSAPI{}
+		logger, err := auditrail.NewSQSLogger(api, "https://sqs.eu-west-1.amazonaws.com/123/audit")
+		require.NoError(t, err)
+
+		entry := auditrail.NewEntry("john", "order_create", "orders")
+		require.NoError(t, logger.Log(ctx, entry))
+
+		require.Len(t, api.sendCalls, 1)
+
+		sent := api.sendCalls[0]
+		require.True(t, json.Valid([]byte(*sent.MessageBody)))
+		require.Contains(t, *sent.MessageBody, entry.GetIdempotencyID())
+		require.Equal(t, "order_create", *sent.MessageAttributes[auditrail.MessageAttributeAction].StringValue)
+		require.Equal(t, "orders", *sent.MessageAttributes[auditrail.MessageAttributeModule].StringValue)
+		require.Nil(t, sent.MessageGroupId)
+		require.Nil(t, sent.MessageDeduplicationId)
+	})
+
+	t.Run("GIVEN a FIFO queue WHEN logging an entry THEN it is grouped and de-duplicated", func(t *testing.T) {
+		api := &fakeSQSAPI{}
+		logger, err := auditrail.NewSQSLogger(api, "https://sqs.eu-west-1.amazonaws.com/123/audit.fifo")
+		require.NoError(t, err)
+
+		entry := auditrail.NewEntry("john doe", "order_create", "orders")
+		require.NoError(t, logger.Log(ctx, entry))
+		require.Equal(t, "john_doe/orders", *api.sendCalls[0].MessageGroupId)
+		require.Equal(t, entry.GetIdempotencyID(), *api.sendCalls[0].MessageDeduplicationId)
+
+		t.Run("AND a module message group THEN entries are grouped by module", func(t *testing.T) {
+			api := &fakeSQSAPI{}
+			logger, err := auditrail.NewSQSLogger(api, "https://sqs.eu-west-1.amazonaws.com/123/audit.fifo",
+				auditrail.WithSQSMessageGroup(auditrail.MessageGroupByModule),
+			)
+			require.NoError(t, err)
+
+			require.Nil(t, logger.LogBatch(ctx, []*auditrail.Entry{entry}))
+			require.Equal(t, "orders", *api.batchCalls[0].Entries[0].MessageGroupId)
+			require.Equal(t, entry.GetIdempotencyID(), *api.batchCalls[0].Entries[0].MessageDeduplicationId)
+		})
+	})
+
+	t.Run("GIVEN a batch of entries WHEN logging it THEN requests are limited to 10 messages", func(t *testing.T) {
+		api := &fakeSQSAPI{}
+		logger, err := auditrail.NewSQSLogger(api, "audit")
+		require.NoError(t, err)
+
+		entries := make([]*auditrail.Entry, 25)
+		for i := range entries {
+			entries[i] = newFakeEntry()
+		}
+
+		require.Nil(t, logger.LogBatch(ctx, entries))
+		require.Empty(t, api.sendCalls)
+		require.Len(t, api.batchCalls, 3)
+		require.Len(t, api.batchCalls[0].Entries, 10)
+		require.Len(t, api.batchCalls[2].Entries, 5)
+	})
+
+	t.Run("GIVEN messages failing WHEN logging a batch THEN server faults are retried and sender faults are permanent", func(t *testing.T) {
+		api := &fakeSQSAPI{}
+		api.fail = func(e types.SendMessageBatchRequestEntry, attempt int) *types.BatchResultErrorEntry {
+			switch {
+			case strings.Contains(*e.MessageBody, `"actor":"throttled"`) && attempt == 1:
+				return &types.BatchResultErrorEntry{Code: aws.String("InternalError")}
+			case strings.Contains(*e.MessageBody, `"actor":"invalid"`):
+				return &types.BatchResultErrorEntry{Code: aws.String("InvalidMessageContents"), SenderFault: true}
+			}
+
+			return nil
+		}
+
+		logger, err := auditrail.NewSQSLogger(api, "audit", fastRetries)
+		require.NoError(t, err)
+
+		errs := logger.LogBatch(ctx, []*auditrail.Entry{
+			newFakeEntry(),
+			auditrail.NewEntry("throttled", "order_create", "orders"),
+			auditrail.NewEntry("invalid", "order_create", "orders"),
+		})
+		require.Len(t, errs, 3)
+		require.NoError(t, errs[0])
+		require.NoError(t, errs[1])
+		require.ErrorIs(t, errs[2], auditrail.ErrPermanent)
+		require.ErrorContains(t, errs[2], "InvalidMessageContents")
+
+		require.Len(t, api.batchCalls, 2)
+		require.Len(t, api.batchCalls[1].Entries, 1)
+		require.Contains(t, *api.batchCalls[1].Entries[0].MessageBody, `"actor":"throttled"`)
+	})
+
+	t.Run("GIVEN a failing request WHEN logging a batch THEN every entry reports a retryable error", func(t *testing.T) {
+		api := &fakeSQSAPI{err: errors.New("connection reset")}
+		logger, err := auditrail.NewSQSLogger(api, "audit")
+		require.NoError(t, err)
+
+		errs := logger.LogBatch(ctx, []*auditrail.Entry{newFakeEntry(), newFakeEntry()})
+		require.Len(t, errs, 2)
+
+		for _, err := range errs {
+			require.ErrorIs(t, err, auditrail.ErrRetryable)
+		}
+
+		require.ErrorIs(t, logger.Log(ctx, newFakeEntry()), auditrail.ErrRetryable)
+	})
+
+	t.Run("GIVEN an entry exceeding the message size limit WHEN logging it THEN it is rejected", func(t *testing.T) {
+		api := &fakeSQSAPI{}
+		logger, err := auditrail.NewSQSLogger(api, "audit")
+		require.NoError(t, err)
+
+		large := newFakeEntry().AppendDetails("blob", strings.Repeat("x", 300_000))
+
+		require.ErrorIs(t, logger.Log(ctx, large), auditrail.ErrPermanent)
+		require.Empty(t, api.sendCalls)
+	})
+
+	t.Run("GIVEN a closed logger WHEN logging THEN an error is returned", func(t *testing.T) {
+		logger, err := auditrail.NewSQSLogger(&fakeSQSAPI{}, "audit")
+		require.NoError(t, err)
+
+		checkClose(t, ctx, logger)
+		require.ErrorIs(t, logger.Log(ctx, newFakeEntry()), auditrail.ErrTrailClosed)
+	})
+}
+
+// fakeSQSAPI records the requests it receives. Messages for which fail
+// returns an error entry, given the attempt of the message, are reported as
+// failed.
+type fakeSQSAPI struct {
+	sendCalls  []*sqs.SendMessageInput
+	batchCalls []*sqs.SendMessageBatchInput
+	fail       func(e types.SendMessageBatchRequestEntry, attempt int) *types.BatchResultErrorEntry
+	err        error
+	attempts   map[string]int
+	mu         sync.Mutex
+}
+
+func (f *fakeSQSAPI) SendMessage(_ context.Context, params *sqs.SendMessageInput, _ ...func(*sqs.Options)) (*sqs.SendMessageOutput, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if f.err != nil {
+		return nil, f.err
+	}
+
+	f.sendCalls = append(f.sendCalls, params)
+
+	return &sqs.SendMessageOutput{MessageId: aws.String("1")}, nil
+}
+
+func (f *fakeSQSAPI) SendMessageBatch(_ context.Context, params *sqs.SendMessageBatchInput, _ ...func(*sqs.Options)) (*sqs.SendMessageBatchOutput, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if f.err != nil {
+		return nil, f.err
+	}
+
+	if f.attempts == nil {
+		f.attempts = make(map[string]int)
+	}
+
+	f.batchCalls = append(f.batchCalls, params)
+	out := &sqs.SendMessageBatchOutput{}
+
+	for _, e := range params.Entries {
+		f.attempts[*e.MessageBody]++
+
+		if f.fail != nil {
+			if failure := f.fail(e, f.attempts[*e.MessageBody]); failure != nil {
+				failure.Id = e.Id
+				out.Failed = append(out.Failed, *failure)
+
+				continue
+			}
+		}
+
+		out.Successful = append(out.Successful, types.SendMessageBatchResultEntry{Id: e.Id, MessageId: aws.String("1")})
+	}
+
+	return out, nil
+}
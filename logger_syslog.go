@@ -0,0 +1,454 @@
+package auditrail
+
+import (
+	"context"
+	"crypto/tls"
+	"fmt"
+	"net"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// SyslogFormat is the message format used by a syslog logger.
+type SyslogFormat int
+
+const (
+	// SyslogRFC5424 formats messages as described by RFC 5424, carrying the
+	// entry fields as structured data.
+	SyslogRFC5424 SyslogFormat = iota
+
+	// SyslogRFC3164 formats messages using the legacy BSD format described by
+	// RFC 3164.
+	SyslogRFC3164
+)
+
+// SyslogFacility is the facility of syslog messages.
+type SyslogFacility int
+
+// Syslog facilities as defined by RFC 5424.
+const (
+	SyslogFacilityKern SyslogFacility = iota
+	SyslogFacilityUser
+	SyslogFacilityMail
+	SyslogFacilityDaemon
+	SyslogFacilityAuth
+	SyslogFacilitySyslog
+	SyslogFacilityLPR
+	SyslogFacilityNews
+	SyslogFacilityUUCP
+	SyslogFacilityCron
+	SyslogFacilityAuthPriv
+	SyslogFacilityFTP
+	SyslogFacilityNTP
+	SyslogFacilityAudit
+	SyslogFacilityAlert
+	SyslogFacilityClock
+	SyslogFacilityLocal0
+	SyslogFacilityLocal1
+	SyslogFacilityLocal2
+	SyslogFacilityLocal3
+	SyslogFacilityLocal4
+	SyslogFacilityLocal5
+	SyslogFacilityLocal6
+	SyslogFacilityLocal7
+)
+
+// SyslogSeverity is the severity of syslog messages.
+type SyslogSeverity int
+
+// Syslog severities as defined by RFC 5424.
+const (
+	SyslogSeverityEmergency SyslogSeverity = iota
+	SyslogSeverityAlert
+	SyslogSeverityCritical
+	SyslogSeverityError
+	SyslogSeverityWarning
+	SyslogSeverityNotice
+	SyslogSeverityInformational
+	SyslogSeverityDebug
+)
+
+// DefaultSyslogStructuredDataID is the SD-ID of the structured data element
+// carrying the entry fields in RFC 5424 messages.
+const DefaultSyslogStructuredDataID = "auditrail@32473"
+
+// SyslogDialFunc dials the syslog server at the given address.
+type SyslogDialFunc func(ctx context.Context, network, addr string) (net.Conn, error)
+
+// SyslogLoggerOption is a function that configures a syslog logger.
+type SyslogLoggerOption func(options *syslogLogger)
+
+type syslogLogger struct {
+	network      string
+	addr         string
+	dial         SyslogDialFunc
+	tlsConfig    *tls.Config
+	format       SyslogFormat
+	facility     SyslogFacility
+	severity     SyslogSeverity
+	hostname     string
+	appName      string
+	procID       string
+	sdID         string
+	encoder      Encoder
+	conn         net.Conn
+	closed       bool
+	closeChannel chan struct{}
+	mu           sync.RWMutex
+}
+
+// NewSyslogLogger builds a new logger that sends log entries to a syslog
+// server. Supported networks are "udp", "tcp" and "unix" (and their variants
+// such as "udp4" or "unixgram"); use [WithSyslogTLS] for syslog over TLS. When
+// both network and addr are empty, the local syslog daemon socket is used.
+//
+// By default, messages follow RFC 5424: the entry fields are carried in a
+// structured data element and the message body is the JSON encoded entry. On
+// stream transports, messages are framed using octet counting as described
+// by RFC 6587.
+//
+// The connection is established eagerly. When a write fails, the connection
+// is dropped and reestablished on the next call, and the returned error wraps
+// [ErrRetryable] so it can be retried by [NewRetryer].
+func NewSyslogLogger(network, addr string, options ...SyslogLoggerOption) (Logger, error) {
+	hostname, _ := os.Hostname()
+
+	l := &syslogLogger{
+		network:      network,
+		addr:         addr,
+		dial:         (&net.Dialer{Timeout: 5 * time.Second}).DialContext,
+		format:       SyslogRFC5424,
+		facility:     SyslogFacilityAudit,
+		severity:     SyslogSeverityNotice,
+		hostname:     hostname,
+		appName:      "auditrail",
+		procID:       strconv.Itoa(os.Getpid()),
+		sdID:         DefaultSyslogStructuredDataID,
+		encoder:      NewJSONCodec(),
+		closeChannel: make(chan struct{}),
+	}
+
+	for _, option := range options {
+		option(l)
+	}
+
+	if isBinaryEncoder(l.encoder) {
+		return nil, fmt.Errorf("syslog logger requires a text encoder")
+	}
+
+	if err := l.connect(context.Background()); err != nil {
+		return nil, err
+	}
+
+	return l, nil
+}
+
+func (l *syslogLogger) Log(ctx context.Context, entry *Entry) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if l.closed {
+		return ErrTrailClosed
+	}
+
+	msg, err := l.message(entry)
+	if err != nil {
+		return err
+	}
+
+	if !l.datagram() {
+		msg = append([]byte(strconv.Itoa(len(msg))+" "), msg...)
+	}
+
+	if l.conn == nil {
+		if err = l.connect(ctx); err != nil {
+			return err
+		}
+	}
+
+	deadline, _ := ctx.Deadline()
+	if err = l.conn.SetWriteDeadline(deadline); err == nil {
+		_, err = l.conn.Write(msg)
+	}
+
+	if err != nil {
+		_ = l.conn.Close()
+		l.conn = nil
+
+		return fmt.Errorf("%w: %w: could not write syslog message", ErrRetryable, err)
+	}
+
+	return nil
+}
+
+func (l *syslogLogger) Close() error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if l.closed {
+		return nil
+	}
+
+	l.closed = true
+
+	close(l.closeChannel)
+
+	if l.conn == nil {
+		return nil
+	}
+
+	err := l.conn.Close()
+	l.conn = nil
+
+	return err
+}
+
+func (l *syslogLogger) Closed() <-chan struct{} {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+
+	return l.closeChannel
+}
+
+func (l *syslogLogger) IsClosed() bool {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+
+	return l.closed
+}
+
+// connect dials the syslog server, performing the TLS handshake if needed.
+// When no address was given, well known local syslog sockets are tried.
+func (l *syslogLogger) connect(ctx context.Context) error {
+	if l.network == "" && l.addr == "" {
+		return l.connectLocal(ctx)
+	}
+
+	conn, err := l.dial(ctx, l.network, l.addr)
+	if err != nil {
+		return fmt.Errorf("%w: %w: could not connect to syslog server", ErrRetryable, err)
+	}
+
+	if l.tlsConfig != nil {
+		tc := tls.Client(conn, l.tlsConfig)
+
+		if err = tc.HandshakeContext(ctx); err != nil {
+			_ = conn.Close()
+
+			return fmt.Errorf("%w: %w: syslog tls handshake failed", ErrRetryable, err)
+		}
+
+		conn = tc
+	}
+
+	l.conn = conn
+
+	return nil
+}
+
+func (l *syslogLogger) connectLocal(ctx context.Context) error {
+	for _, network := range []string{"unixgram", "unix"} {
+		for _, path := range []string{"/dev/log", "/var/run/syslog", "/var/run/log"} {
+			conn, err := l.dial(ctx, network, path)
+			if err == nil {
+				l.network = network
+				l.addr = path
+				l.conn = conn
+
+				return nil
+			}
+		}
+	}
+
+	return fmt.Errorf("%w: could not find a local syslog socket", ErrRetryable)
+}
+
+// datagram returns true if the logger uses a message oriented transport.
+func (l *syslogLogger) datagram() bool {
+	return strings.HasPrefix(l.network, "udp") || l.network == "unixgram"
+}
+
+// message formats the given entry as a syslog message.
+func (l *syslogLogger) message(entry *Entry) ([]byte, error) {
+	body, err := l.encoder.Encode(entry)
+	if err != nil {
+		return nil, err
+	}
+
+	body = []byte(strings.TrimRight(string(body), "\n"))
+	pri := int(l.facility)*8 + int(l.severity)
+	at := entry.GetOccurredAt()
+
+	if l.format == SyslogRFC3164 {
+		if at.IsZero() {
+			at = time.Now()
+		}
+
+		header := fmt.Sprintf("<%d>%s %s %s[%s]: ",
+			pri,
+			at.Format(time.Stamp),
+			syslogHeaderField(l.hostname, 255),
+			syslogHeaderField(l.appName, 32),
+			l.procID,
+		)
+
+		return append([]byte(header), body...), nil
+	}
+
+	timestamp := "-"
+	if !at.IsZero() {
+		timestamp = at.UTC().Format("2006-01-02T15:04:05.000000Z07:00")
+	}
+
+	b := strings.Builder{}
+
+	fmt.Fprintf(&b, "<%d>1 %s %s %s %s %s ",
+		pri,
+		timestamp,
+		syslogHeaderField(l.hostname, 255),
+		syslogHeaderField(l.appName, 48),
+		syslogHeaderField(l.procID, 128),
+		syslogHeaderField(entry.GetAction(), 32),
+	)
+
+	b.WriteString(l.structuredData(entry))
+
+	if len(body) > 0 {
+		b.WriteByte(' ')
+		b.Write(body)
+	}
+
+	return []byte(b.String()), nil
+}
+
+// structuredData returns the RFC 5424 structured data element carrying the
+// fields of the given entry.
+func (l *syslogLogger) structuredData(entry *Entry) string {
+	escaper := strings.NewReplacer(`\`, `\\`, `"`, `\"`, `]`, `\]`)
+
+	b := strings.Builder{}
+	b.WriteByte('[')
+	b.WriteString(l.sdID)
+
+	for _, param := range []struct {
+		name  string
+		value string
+	}{
+		{"idempotencyID", entry.GetIdempotencyID()},
+		{"actor", entry.GetActor()},
+		{"action", entry.GetAction()},
+		{"module", entry.GetModule()},
+		{"correlationID", entry.GetCorrelationID()},
+		{"causationID", entry.GetCausationID()},
+		{"authMethod", entry.GetAuthMethod()},
+	} {
+		if param.value == "" {
+			continue
+		}
+
+		fmt.Fprintf(&b, ` %s="%s"`, param.name, escaper.Replace(param.value))
+	}
+
+	b.WriteByte(']')
+
+	return b.String()
+}
+
+// syslogHeaderField sanitizes a header field, which is restricted to
+// printable US-ASCII characters, truncating it to the given length. The nil
+// value "-" is returned for empty fields.
+func syslogHeaderField(value string, size int) string {
+	b := strings.Builder{}
+
+	for i := 0; i < len(value) && b.Len() < size; i++ {
+		if c := value[i]; c >= 33 && c <= 126 {
+			b.WriteByte(c)
+		}
+	}
+
+	if b.Len() == 0 {
+		return "-"
+	}
+
+	return b.String()
+}
+
+// WithSyslogFormat sets the format of the messages, RFC 5424 by default.
+func WithSyslogFormat(format SyslogFormat) SyslogLoggerOption {
+	return func(options *syslogLogger) {
+		options.format = format
+	}
+}
+
+// WithSyslogPriority sets the facility and severity of the messages. By
+// default, messages are sent with the audit facility and notice severity.
+// Out of range values are ignored.
+func WithSyslogPriority(facility SyslogFacility, severity SyslogSeverity) SyslogLoggerOption {
+	return func(options *syslogLogger) {
+		if facility >= SyslogFacilityKern && facility <= SyslogFacilityLocal7 {
+			options.facility = facility
+		}
+
+		if severity >= SyslogSeverityEmergency && severity <= SyslogSeverityDebug {
+			options.severity = severity
+		}
+	}
+}
+
+// WithSyslogHostname sets the hostname reported in the messages. Defaults to
+// the hostname of the machine.
+func WithSyslogHostname(hostname string) SyslogLoggerOption {
+	return func(options *syslogLogger) {
+		options.hostname = hostname
+	}
+}
+
+// WithSyslogAppName sets the application name (or tag, in RFC 3164 messages)
+// reported in the messages. Defaults to "auditrail".
+func WithSyslogAppName(name string) SyslogLoggerOption {
+	return func(options *syslogLogger) {
+		options.appName = name
+	}
+}
+
+// WithSyslogStructuredDataID sets the SD-ID of the structured data element
+// carrying the entry fields. Defaults to [DefaultSyslogStructuredDataID].
+func WithSyslogStructuredDataID(id string) SyslogLoggerOption {
+	return func(options *syslogLogger) {
+		if id != "" {
+			options.sdID = id
+		}
+	}
+}
+
+// WithSyslogEncoder sets the encoder used to build the message body, such as
+// a SIEM format. If encoder is nil, entries are encoded as JSON.
+func WithSyslogEncoder(encoder Encoder) SyslogLoggerOption {
+	return func(options *syslogLogger) {
+		if encoder == nil {
+			encoder = NewJSONCodec()
+		}
+
+		options.encoder = encoder
+	}
+}
+
+// WithSyslogTLS enables syslog over TLS (RFC 5425) using the given
+// configuration. It must be used with a stream network such as "tcp".
+func WithSyslogTLS(config *tls.Config) SyslogLoggerOption {
+	return func(options *syslogLogger) {
+		options.tlsConfig = config
+	}
+}
+
+// WithSyslogDialer sets the function used to connect to the syslog server.
+func WithSyslogDialer(dial SyslogDialFunc) SyslogLoggerOption {
+	return func(options *syslogLogger) {
+		if dial != nil {
+			options.dial = dial
+		}
+	}
+}
@@ -0,0 +1,238 @@
+package auditrail_test
+
+import (
+	"bufio"
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"io"
+	"net"
+	"net/http/httptest"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/botchris/go-auditrail"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSyslogLogger(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	entry := auditrail.NewEntry("john doe", "order_create", "orders").
+		WithCorrelation("c0ffee").
+		WithOccurredAt(time.Date(2026, 10, 17, 10, 30, 0, 123456789, time.UTC)).
+		AppendDetails("note", `quote " and ] bracket`)
+
+	t.Run("GIVEN a syslog logger over UDP WHEN logging an entry THEN an RFC 5424 message is sent", func(t *testing.T) {
+		pc, err := net.ListenPacket("udp", "127.0.0.1:0")
+		require.NoError(t, err)
+		defer pc.Close()
+
+		logger, err := auditrail.NewSyslogLogger("udp", pc.LocalAddr().String(),
+			auditrail.WithSyslogHostname("host-1"),
+			auditrail.WithSyslogPriority(auditrail.SyslogFacilityLocal4, auditrail.SyslogSeverityInformational),
+		)
+		require.NoError(t, err)
+		defer checkClose(t, ctx, logger)
+
+		require.NoError(t, logger.Log(ctx, entry))
+
+		buf := make([]byte, 64*1024)
+		require.NoError(t, pc.SetReadDeadline(time.Now().Add(5*time.Second)))
+		n, _, err := pc.ReadFrom(buf)
+		require.NoError(t, err)
+
+		msg := string(buf[:n])
+		require.True(t, strings.HasPrefix(msg, "<166>1 2026-10-17T10:30:00.123456Z host-1 auditrail "), msg)
+		require.Contains(t, msg, " order_create [auditrail@32473 idempotencyID=\""+entry.GetIdempotencyID()+"\"")
+		require.Contains(t, msg, `actor="john doe" action="order_create" module="orders" correlationID="c0ffee"]`)
+		require.Contains(t, msg, `"note":"quote \" and ] bracket"`)
+	})
+
+	t.Run("GIVEN a syslog logger over TCP WHEN logging entries THEN messages are octet counted", func(t *testing.T) {
+		ln, err := net.Listen("tcp", "127.0.0.1:0")
+		require.NoError(t, err)
+		defer ln.Close()
+
+		messages := serveSyslogStream(t, ln)
+
+		logger, err := auditrail.NewSyslogLogger("tcp", ln.Addr().String())
+		require.NoError(t, err)
+		defer checkClose(t, ctx, logger)
+
+		require.NoError(t, logger.Log(ctx, entry))
+		require.NoError(t, logger.Log(ctx, entry))
+
+		for i := 0; i < 2; i++ {
+			select {
+			case msg := <-messages:
+				require.True(t, strings.HasPrefix(msg, "<109>1 "), msg)
+				require.True(t, strings.HasSuffix(msg, "}"), msg)
+			case <-ctx.Done():
+				t.Fatal("timeout waiting for syslog message")
+			}
+		}
+	})
+
+	t.Run("GIVEN a syslog logger over TLS WHEN logging an entry THEN the message is received", func(t *testing.T) {
+		srv := httptest.NewTLSServer(nil)
+		defer srv.Close()
+
+		ln, err := tls.Listen("tcp", "127.0.0.1:0", &tls.Config{Certificates: srv.TLS.Certificates})
+		require.NoError(t, err)
+		defer ln.Close()
+
+		messages := serveSyslogStream(t, ln)
+
+		pool := x509.NewCertPool()
+		pool.AddCert(srv.Certificate())
+
+		logger, err := auditrail.NewSyslogLogger("tcp", ln.Addr().String(),
+			auditrail.WithSyslogTLS(&tls.Config{RootCAs: pool, ServerName: "example.com", MinVersion: tls.VersionTLS12}),
+		)
+		require.NoError(t, err)
+		defer checkClose(t, ctx, logger)
+
+		require.NoError(t, logger.Log(ctx, entry))
+
+		select {
+		case msg := <-messages:
+			require.Contains(t, msg, `module="orders"`)
+		case <-ctx.Done():
+			t.Fatal("timeout waiting for syslog message")
+		}
+	})
+
+	t.Run("GIVEN an RFC 3164 syslog logger over a unix datagram socket WHEN logging THEN a BSD message is sent", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "log.sock")
+
+		pc, err := net.ListenPacket("unixgram", path)
+		require.NoError(t, err)
+		defer pc.Close()
+
+		logger, err := auditrail.NewSyslogLogger("unixgram", path,
+			auditrail.WithSyslogFormat(auditrail.SyslogRFC3164),
+			auditrail.WithSyslogHostname("host-1"),
+			auditrail.WithSyslogAppName("shop"),
+		)
+		require.NoError(t, err)
+		defer checkClose(t, ctx, logger)
+
+		require.NoError(t, logger.Log(ctx, entry))
+
+		buf := make([]byte, 64*1024)
+		require.NoError(t, pc.SetReadDeadline(time.Now().Add(5*time.Second)))
+		n, _, err := pc.ReadFrom(buf)
+		require.NoError(t, err)
+
+		msg := string(buf[:n])
+		require.True(t, strings.HasPrefix(msg, "<109>Oct 17 10:30:00 host-1 shop["), msg)
+		require.Contains(t, msg, `]: {"idempotency_id":"`+entry.GetIdempotencyID())
+	})
+
+	t.Run("GIVEN a broken connection WHEN logging THEN a retryable error is returned and the logger reconnects", func(t *testing.T) {
+		var dials atomic.Int32
+
+		client, server := net.Pipe()
+		defer server.Close()
+
+		go func() { _, _ = io.Copy(io.Discard, server) }()
+
+		dial := func(context.Context, string, string) (net.Conn, error) {
+			if dials.Add(1) == 1 {
+				return brokenConn{}, nil
+			}
+
+			return client, nil
+		}
+
+		logger, err := auditrail.NewSyslogLogger("tcp", "syslog.invalid:6514", auditrail.WithSyslogDialer(dial))
+		require.NoError(t, err)
+
+		err = logger.Log(ctx, entry)
+		require.ErrorIs(t, err, auditrail.ErrRetryable)
+		require.ErrorIs(t, err, syscall.EPIPE)
+		require.NotErrorIs(t, err, auditrail.ErrTrailClosed)
+
+		retryer := auditrail.NewRetryer(logger)
+		defer checkClose(t, ctx, retryer)
+
+		require.NoError(t, retryer.Log(ctx, entry))
+		require.EqualValues(t, 2, dials.Load())
+	})
+
+	t.Run("GIVEN a closed syslog logger WHEN logging THEN it fails", func(t *testing.T) {
+		pc, err := net.ListenPacket("udp", "127.0.0.1:0")
+		require.NoError(t, err)
+		defer pc.Close()
+
+		logger, err := auditrail.NewSyslogLogger("udp", pc.LocalAddr().String())
+		require.NoError(t, err)
+		require.NoError(t, logger.Close())
+		require.ErrorIs(t, logger.Log(ctx, entry), auditrail.ErrTrailClosed)
+	})
+}
+
+// serveSyslogStream accepts a single connection on the given listener and
+// returns a channel with the octet counted messages read from it.
+func serveSyslogStream(t *testing.T, ln net.Listener) <-chan string {
+	t.Helper()
+
+	messages := make(chan string, 16)
+
+	go func() {
+		conn, err := ln.Accept()
+		if err != nil {
+			return
+		}
+
+		defer conn.Close()
+
+		r := bufio.NewReader(conn)
+
+		for {
+			size, rErr := r.ReadString(' ')
+			if rErr != nil {
+				return
+			}
+
+			n, cErr := strconv.Atoi(strings.TrimSpace(size))
+			if cErr != nil {
+				return
+			}
+
+			msg := make([]byte, n)
+			if _, rErr = io.ReadFull(r, msg); rErr != nil {
+				return
+			}
+
+			messages <- string(msg)
+		}
+	}()
+
+	return messages
+}
+
+// brokenConn is a connection whose writes always fail.
+type brokenConn struct {
+	net.Conn
+}
+
+func (brokenConn) Write([]byte) (int, error) {
+	return 0, &net.OpError{Op: "write", Net: "tcp", Err: syscall.EPIPE}
+}
+
+func (brokenConn) SetWriteDeadline(time.Time) error {
+	return nil
+}
+
+func (brokenConn) Close() error {
+	return errors.New("already broken")
+}
@@ -0,0 +1,56 @@
+package auditrail_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/botchris/go-auditrail"
+	"github.com/stretchr/testify/require"
+)
+
+func TestShutdown(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	t.Run("GIVEN a chain of loggers WHEN shutting it down THEN reports of every logger are added up", func(t *testing.T) {
+		dst := auditrail.NewMemoryLogger()
+		chain := auditrail.NewRetryer(auditrail.NewQueue(auditrail.NewBatcher(dst, auditrail.WithBatchMaxLatency(time.Minute))))
+
+		for i := 0; i < 5; i++ {
+			require.NoError(t, chain.Log(ctx, newFakeEntry()))
+		}
+
+		report, err := auditrail.Shutdown(ctx, chain)
+		require.NoError(t, err)
+		require.GreaterOrEqual(t, report.Flushed, 5, "pending entries are flushed by the batcher")
+		require.Zero(t, report.Abandoned)
+		require.EqualValues(t, 5, dst.Size())
+	})
+
+	t.Run("GIVEN a logger without shutdown support WHEN the deadline is reached THEN it is closed in the background", func(t *testing.T) {
+		closing := &closeBlocked{Logger: auditrail.NewMemoryLogger(), release: make(chan struct{})}
+
+		deadline, cancelDeadline := context.WithTimeout(ctx, 20*time.Millisecond)
+		defer cancelDeadline()
+
+		report, err := auditrail.Shutdown(deadline, closing)
+		require.ErrorIs(t, err, context.DeadlineExceeded)
+		require.Zero(t, report)
+
+		close(closing.release)
+		require.Eventually(t, closing.IsClosed, 5*time.Second, time.Millisecond)
+	})
+}
+
+// closeBlocked is a logger whose Close blocks until released.
+type closeBlocked struct {
+	auditrail.Logger
+	release chan struct{}
+}
+
+func (c *closeBlocked) Close() error {
+	<-c.release
+
+	return c.Logger.Close()
+}
@@ -0,0 +1,408 @@
+package auditrail
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"math/bits"
+	"sync"
+)
+
+// ErrInvalidProof is returned when a Merkle proof does not verify.
+var ErrInvalidProof = errors.New("invalid merkle proof")
+
+// MerkleStore persists the leaf hashes of a [MerkleTree], so that the tree can
+// be restored after a restart.
+//
+// All methods should be goroutine safe.
+type MerkleStore interface {
+	// Append persists the given leaf hash after the ones already stored.
+	Append(leafHash []byte) error
+
+	// Leaves returns the stored leaf hashes at positions [from, to).
+	Leaves(from, to uint64) ([][]byte, error)
+
+	// Size returns the number of stored leaves.
+	Size() (uint64, error)
+}
+
+// MerkleTree is an append-only Merkle tree using the hashing scheme of RFC
+// 6962 (Certificate Transparency): leaves are hashed as SHA-256(0x00 || data)
+// and interior nodes as SHA-256(0x01 || left || right).
+//
+// Leaves are kept in a [MerkleStore], while the tree only keeps the roots of
+// the perfect subtrees along its right edge, so the current root is computed
+// in logarithmic time. Proofs and roots of previous sizes read the leaves back
+// from the store.
+//
+// This struct is safe for concurrent use.
+type MerkleTree struct {
+	store    MerkleStore
+	frontier [][]byte
+	size     uint64
+	mu       sync.RWMutex
+}
+
+// NewMerkleTree creates a new empty Merkle tree keeping its leaves in memory.
+func NewMerkleTree() *MerkleTree {
+	return &MerkleTree{
+		store: NewMemoryMerkleStore(),
+	}
+}
+
+// LoadMerkleTree creates a Merkle tree keeping its leaves in the given store,
+// starting with the leaves already stored.
+func LoadMerkleTree(store MerkleStore) (*MerkleTree, error) {
+	size, err := store.Size()
+	if err != nil {
+		return nil, fmt.Errorf("%w: could not load merkle tree", err)
+	}
+
+	t := &MerkleTree{store: store}
+
+	const chunk = 4096
+
+	for from := uint64(0); from < size; from += chunk {
+		leaves, err := store.Leaves(from, min(from+chunk, size))
+		if err != nil {
+			return nil, fmt.Errorf("%w: could not load merkle tree", err)
+		}
+
+		for _, leaf := range leaves {
+			t.push(leaf)
+		}
+	}
+
+	return t, nil
+}
+
+// Append adds the given leaf hash to the tree, returning its index. Use
+// [HashLeaf] to compute the hash of raw leaf data.
+func (t *MerkleTree) Append(leafHash []byte) (uint64, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if err := t.store.Append(leafHash); err != nil {
+		return 0, fmt.Errorf("%w: could not store merkle leaf", err)
+	}
+
+	t.push(leafHash)
+
+	return t.size - 1, nil
+}
+
+// Size returns the number of leaves in the tree.
+func (t *MerkleTree) Size() uint64 {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+
+	return t.size
+}
+
+// Root returns the root hash of the tree as it was when it had the given
+// number of leaves.
+func (t *MerkleTree) Root(size uint64) ([]byte, error) {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+
+	if size == t.size {
+		return t.root(), nil
+	}
+
+	leaves, err := t.leaves(size)
+	if err != nil {
+		return nil, err
+	}
+
+	return rootHash(leaves), nil
+}
+
+// InclusionProof returns the audit path proving that the leaf at the given
+// index is included in the tree of the given size.
+func (t *MerkleTree) InclusionProof(index, size uint64) ([][]byte, error) {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+
+	if index >= size {
+		return nil, fmt.Errorf("leaf index %d is out of range for tree size %d", index, size)
+	}
+
+	leaves, err := t.leaves(size)
+	if err != nil {
+		return nil, err
+	}
+
+	return inclusionPath(index, leaves), nil
+}
+
+// ConsistencyProof returns the proof that the tree of the first size is a
+// prefix of the tree of the second size.
+func (t *MerkleTree) ConsistencyProof(first, second uint64) ([][]byte, error) {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+
+	if first == 0 || first > second {
+		return nil, fmt.Errorf("invalid consistency range [%d, %d]", first, second)
+	}
+
+	leaves, err := t.leaves(second)
+	if err != nil {
+		return nil, err
+	}
+
+	return consistencySubproof(first, leaves, true), nil
+}
+
+// push adds the given leaf hash to the frontier, merging the perfect subtrees
+// completed by the new leaf. Must be called with the lock held.
+func (t *MerkleTree) push(leafHash []byte) {
+	t.frontier = append(t.frontier, leafHash)
+	t.size++
+
+	for merges := bits.TrailingZeros64(t.size); merges > 0; merges-- {
+		n := len(t.frontier)
+		t.frontier = append(t.frontier[:n-2], hashChildren(t.frontier[n-2], t.frontier[n-1]))
+	}
+}
+
+// root returns the root hash of the current tree, folding the frontier from
+// right to left. Must be called with the lock held.
+func (t *MerkleTree) root() []byte {
+	if len(t.frontier) == 0 {
+		return rootHash(nil)
+	}
+
+	r := t.frontier[len(t.frontier)-1]
+	for i := len(t.frontier) - 2; i >= 0; i-- {
+		r = hashChildren(t.frontier[i], r)
+	}
+
+	return r
+}
+
+// leaves returns the first size leaves of the tree. Must be called with the
+// lock held.
+func (t *MerkleTree) leaves(size uint64) ([][]byte, error) {
+	if size > t.size {
+		return nil, fmt.Errorf("tree size %d exceeds the number of leaves %d", size, t.size)
+	}
+
+	leaves, err := t.store.Leaves(0, size)
+	if err != nil {
+		return nil, fmt.Errorf("%w: could not read merkle leaves", err)
+	}
+
+	return leaves, nil
+}
+
+type memoryMerkleStore struct {
+	leaves [][]byte
+	mu     sync.RWMutex
+}
+
+// NewMemoryMerkleStore returns a [MerkleStore] that keeps leaf hashes in
+// memory. Useful for testing.
+func NewMemoryMerkleStore() MerkleStore {
+	return &memoryMerkleStore{}
+}
+
+func (s *memoryMerkleStore) Append(leafHash []byte) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.leaves = append(s.leaves, leafHash)
+
+	return nil
+}
+
+func (s *memoryMerkleStore) Leaves(from, to uint64) ([][]byte, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	if from > to || to > uint64(len(s.leaves)) {
+		return nil, fmt.Errorf("invalid leaf range [%d, %d) for %d leaves", from, to, len(s.leaves))
+	}
+
+	return s.leaves[from:to:to], nil
+}
+
+func (s *memoryMerkleStore) Size() (uint64, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	return uint64(len(s.leaves)), nil
+}
+
+// HashLeaf returns the Merkle leaf hash of the given data.
+func HashLeaf(data []byte) []byte {
+	h := sha256.New()
+	h.Write([]byte{0x00})
+	h.Write(data)
+
+	return h.Sum(nil)
+}
+
+// VerifyInclusion checks that the given inclusion proof proves that the leaf
+// hash at the given index is included in the tree of the given size and root.
+func VerifyInclusion(leafHash []byte, index, size uint64, proof [][]byte, root []byte) error {
+	if index >= size {
+		return fmt.Errorf("%w: leaf index %d is out of range for tree size %d", ErrInvalidProof, index, size)
+	}
+
+	fn, sn := index, size-1
+	r := leafHash
+
+	for _, p := range proof {
+		if sn == 0 {
+			return fmt.Errorf("%w: proof is too long", ErrInvalidProof)
+		}
+
+		if fn&1 == 1 || fn == sn {
+			r = hashChildren(p, r)
+
+			for fn&1 == 0 && fn != 0 {
+				fn >>= 1
+				sn >>= 1
+			}
+		} else {
+			r = hashChildren(r, p)
+		}
+
+		fn >>= 1
+		sn >>= 1
+	}
+
+	if sn != 0 || !bytes.Equal(r, root) {
+		return ErrInvalidProof
+	}
+
+	return nil
+}
+
+// VerifyConsistency checks that the given consistency proof proves that the
+// tree of the first size and root is a prefix of the tree of the second size
+// and root.
+func VerifyConsistency(first, second uint64, firstRoot, secondRoot []byte, proof [][]byte) error {
+	switch {
+	case first == 0 || first > second:
+		return fmt.Errorf("%w: invalid consistency range [%d, %d]", ErrInvalidProof, first, second)
+	case first == second:
+		if len(proof) != 0 || !bytes.Equal(firstRoot, secondRoot) {
+			return ErrInvalidProof
+		}
+
+		return nil
+	case len(proof) == 0:
+		return fmt.Errorf("%w: empty proof", ErrInvalidProof)
+	}
+
+	if bits.OnesCount64(first) == 1 {
+		proof = append([][]byte{firstRoot}, proof...)
+	}
+
+	fn, sn := first-1, second-1
+
+	for fn&1 == 1 {
+		fn >>= 1
+		sn >>= 1
+	}
+
+	fr, sr := proof[0], proof[0]
+
+	for _, c := range proof[1:] {
+		if sn == 0 {
+			return fmt.Errorf("%w: proof is too long", ErrInvalidProof)
+		}
+
+		if fn&1 == 1 || fn == sn {
+			fr = hashChildren(c, fr)
+			sr = hashChildren(c, sr)
+
+			for fn&1 == 0 && fn != 0 {
+				fn >>= 1
+				sn >>= 1
+			}
+		} else {
+			sr = hashChildren(sr, c)
+		}
+
+		fn >>= 1
+		sn >>= 1
+	}
+
+	if sn != 0 || !bytes.Equal(fr, firstRoot) || !bytes.Equal(sr, secondRoot) {
+		return ErrInvalidProof
+	}
+
+	return nil
+}
+
+// hashChildren returns the hash of an interior node.
+func hashChildren(left, right []byte) []byte {
+	h := sha256.New()
+	h.Write([]byte{0x01})
+	h.Write(left)
+	h.Write(right)
+
+	return h.Sum(nil)
+}
+
+// split returns the largest power of two smaller than n, n must be greater
+// than one.
+func split(n int) int {
+	return 1 << (bits.Len(uint(n-1)) - 1)
+}
+
+// rootHash computes the root hash of the given leaves.
+func rootHash(leaves [][]byte) []byte {
+	switch len(leaves) {
+	case 0:
+		sum := sha256.Sum256(nil)
+
+		return sum[:]
+	case 1:
+		return leaves[0]
+	}
+
+	k := split(len(leaves))
+
+	return hashChildren(rootHash(leaves[:k]), rootHash(leaves[k:]))
+}
+
+// inclusionPath computes the audit path of the leaf at index m.
+func inclusionPath(m uint64, leaves [][]byte) [][]byte {
+	if len(leaves) <= 1 {
+		return [][]byte{}
+	}
+
+	k := split(len(leaves))
+
+	if m < uint64(k) {
+		return append(inclusionPath(m, leaves[:k]), rootHash(leaves[k:]))
+	}
+
+	return append(inclusionPath(m-uint64(k), leaves[k:]), rootHash(leaves[:k]))
+}
+
+// consistencySubproof computes the consistency proof between the first m
+// leaves and the given leaves.
+func consistencySubproof(m uint64, leaves [][]byte, complete bool) [][]byte {
+	n := uint64(len(leaves))
+
+	if m == n {
+		if complete {
+			return [][]byte{}
+		}
+
+		return [][]byte{rootHash(leaves)}
+	}
+
+	k := uint64(split(len(leaves)))
+
+	if m <= k {
+		return append(consistencySubproof(m, leaves[:k], complete), rootHash(leaves[k:]))
+	}
+
+	return append(consistencySubproof(m-k, leaves[k:], false), rootHash(leaves[:k]))
+}
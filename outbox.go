@@ -0,0 +1,130 @@
+package auditrail
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// DefaultOutboxTable is the default table of the outbox.
+const DefaultOutboxTable = "audit_outbox"
+
+// outboxColumns are the columns written by [Outbox.Write], in order.
+var outboxColumns = []string{"idempotency_id", "payload", "created_at"}
+
+// sqlOutboxMigrations are the migrations of the schema of the outbox, sorted
+// by version. Released migrations must never be changed; add new ones
+// instead.
+var sqlOutboxMigrations = []sqlMigration{
+	{version: 1, statements: sqlCreateOutboxTable},
+}
+
+// sqlCreateOutboxTable returns the statements creating the outbox table.
+func sqlCreateOutboxTable(d SQLDialect, table string) []string {
+	t := d.types()
+
+	columns := []string{
+		"id " + t.id,
+		"idempotency_id VARCHAR(255) NOT NULL",
+		"payload " + t.text + " NOT NULL",
+		"created_at " + t.timestamp + " NOT NULL",
+		"claimed_until " + t.timestamp,
+		"attempts INTEGER NOT NULL DEFAULT 0",
+		"delivered_at " + t.timestamp,
+		"last_error " + t.text,
+		fmt.Sprintf("CONSTRAINT %s_idempotency_id_key UNIQUE (idempotency_id)", table),
+	}
+
+	if d == SQLDialectMySQL {
+		columns = append(columns, fmt.Sprintf("INDEX %s_delivered_at_idx (delivered_at, id)", table))
+
+		return []string{fmt.Sprintf("CREATE TABLE IF NOT EXISTS %s (\n\t%s\n)", table, strings.Join(columns, ",\n\t"))}
+	}
+
+	return []string{
+		fmt.Sprintf("CREATE TABLE IF NOT EXISTS %s (\n\t%s\n)", table, strings.Join(columns, ",\n\t")),
+		fmt.Sprintf("CREATE INDEX IF NOT EXISTS %s_delivered_at_idx ON %s (delivered_at, id)", table, table),
+	}
+}
+
+// MigrateSQLOutbox creates or migrates the table used by [Outbox] to hold
+// entries until they are relayed. If table is empty, [DefaultOutboxTable] is
+// used. See [MigrateSQL] for how migrations are recorded.
+func MigrateSQLOutbox(ctx context.Context, db *sql.DB, dialect SQLDialect, table string) error {
+	if table == "" {
+		table = DefaultOutboxTable
+	}
+
+	return migrateSQL(ctx, db, dialect, table, sqlOutboxMigrations)
+}
+
+// OutboxOption is a function that configures an outbox.
+type OutboxOption func(options *Outbox)
+
+// Outbox implements the transactional outbox pattern for audit entries: they
+// are written into an outbox table within the transaction of the business
+// change they audit, so both are committed or rolled back together, and an
+// [OutboxRelay] forwards them later to a logger.
+//
+// Entries are relayed at least once; downstream loggers should discard
+// duplicates using their idempotency ID, as [NewSQLLogger] does, to achieve
+// an effectively exactly-once delivery.
+type Outbox struct {
+	dialect SQLDialect
+	table   string
+	codec   Codec
+}
+
+// NewOutbox creates an outbox stored in a table created by
+// [MigrateSQLOutbox], written using the given dialect.
+func NewOutbox(dialect SQLDialect, options ...OutboxOption) (*Outbox, error) {
+	o := &Outbox{
+		dialect: dialect,
+		table:   DefaultOutboxTable,
+		codec:   NewJSONCodec(),
+	}
+
+	for _, option := range options {
+		option(o)
+	}
+
+	if !dialect.valid() {
+		return nil, fmt.Errorf("unknown sql dialect %s", dialect)
+	}
+
+	if !sqlIdentifier.MatchString(o.table) {
+		return nil, fmt.Errorf("invalid sql table name %q", o.table)
+	}
+
+	return o, nil
+}
+
+// Write adds the given entry to the outbox within the given transaction. The
+// entry is relayed only if the transaction is committed. Writing an entry
+// whose idempotency ID is already in the outbox has no effect.
+func (o *Outbox) Write(ctx context.Context, tx *sql.Tx, entry *Entry) error {
+	payload, err := o.codec.Encode(entry)
+	if err != nil {
+		return fmt.Errorf("%w: %w: could not encode entry", ErrPermanent, err)
+	}
+
+	query := o.dialect.insertIgnore(o.table, outboxColumns, "idempotency_id", 1)
+
+	if _, err = tx.ExecContext(ctx, query, entry.GetIdempotencyID(), string(payload), time.Now().UTC()); err != nil {
+		return fmt.Errorf("%w: could not write entry into %s", err, o.table)
+	}
+
+	return nil
+}
+
+// WithOutboxTable sets the table of the outbox. Defaults to
+// [DefaultOutboxTable].
+func WithOutboxTable(table string) OutboxOption {
+	return func(options *Outbox) {
+		if table != "" {
+			options.table = table
+		}
+	}
+}
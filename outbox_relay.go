@@ -0,0 +1,359 @@
+package auditrail
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// ErrOutboxMaxAttempts is passed to the drop handler of an [OutboxRelay] along
+// with the entries that failed to be forwarded too many times.
+var ErrOutboxMaxAttempts = errors.New("outbox entry exceeded its delivery attempts")
+
+// OutboxRelayOption is a function that configures an outbox relay.
+type OutboxRelayOption func(options *OutboxRelay)
+
+// OutboxRelay forwards the entries of an [Outbox] to a logger.
+//
+// Relays claim batches of pending entries for a lease period, so many relays
+// can share an outbox and the entries claimed by a relay that crashed are
+// claimed again once their lease expires. Pending entries are claimed using
+// SELECT ... FOR UPDATE SKIP LOCKED, so relays do not wait for each other;
+// on MySQL, relays thus require MySQL 8.0 or later, unlike the outbox itself.
+// SQLite serializes writes instead, so a single relay should be run and the
+// database should be opened with a busy timeout and immediate transactions,
+// e.g. "_pragma=busy_timeout(5000)&_txlock=immediate" with modernc.org/sqlite.
+type OutboxRelay struct {
+	db              *sql.DB
+	outbox          *Outbox
+	dst             Logger
+	batchSize       int
+	interval        time.Duration
+	lease           time.Duration
+	maxAttempts     int
+	deleteDelivered bool
+	dropHandler     DropHandlerFunc
+}
+
+// outboxRow is a claimed row of the outbox.
+type outboxRow struct {
+	id       int64
+	payload  string
+	attempts int
+}
+
+// NewOutboxRelay creates a relay forwarding the entries of the given outbox,
+// stored in db, to dst. When dst is a [BatchLogger], claimed entries are
+// forwarded in a single batch.
+func NewOutboxRelay(db *sql.DB, outbox *Outbox, dst Logger, options ...OutboxRelayOption) *OutboxRelay {
+	r := &OutboxRelay{
+		db:          db,
+		outbox:      outbox,
+		dst:         dst,
+		batchSize:   100,
+		interval:    time.Second,
+		lease:       time.Minute,
+		dropHandler: func(*Entry, error) {},
+	}
+
+	for _, option := range options {
+		option(r)
+	}
+
+	return r
+}
+
+// Run relays entries until ctx is done, in which case it returns nil. The
+// outbox is polled at the configured interval while there are no pending
+// entries. Database errors stop the relay.
+func (r *OutboxRelay) Run(ctx context.Context) error {
+	for {
+		n, err := r.RelayOnce(ctx)
+
+		switch {
+		case ctx.Err() != nil:
+			return nil
+		case err != nil:
+			return err
+		case n == r.batchSize:
+			continue
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-time.After(r.interval):
+		}
+	}
+}
+
+// RelayOnce claims a batch of pending entries and forwards them to the
+// destination logger, returning how many entries were claimed.
+//
+// Forwarded entries are marked as delivered. Entries that cannot be decoded
+// or that are rejected with an error wrapping [ErrPermanent] are passed to the
+// drop handler and marked as delivered too, along with the error. Entries
+// rejected with any other error are claimed again once their lease expires,
+// unless they reached the maximum number of attempts, in which case they are
+// dropped the same way with an error wrapping [ErrOutboxMaxAttempts].
+func (r *OutboxRelay) RelayOnce(ctx context.Context) (int, error) {
+	rows, err := r.claim(ctx)
+	if err != nil || len(rows) == 0 {
+		return 0, err
+	}
+
+	entries := make([]*Entry, 0, len(rows))
+	claimed := make([]outboxRow, 0, len(rows))
+	delivered := make([]int64, 0, len(rows))
+
+	for _, row := range rows {
+		entry, err := r.outbox.codec.Decode([]byte(row.payload))
+		if err != nil {
+			err = fmt.Errorf("%w: %w: could not decode outbox entry %d", ErrPermanent, err, row.id)
+			r.dropHandler(nil, err)
+
+			if err = r.fail(ctx, row.id, err, true); err != nil {
+				return len(rows), err
+			}
+
+			continue
+		}
+
+		entries = append(entries, entry)
+		claimed = append(claimed, row)
+	}
+
+	errs := logBatch(ctx, r.dst, entries)
+
+	for i, row := range claimed {
+		if errs == nil || errs[i] == nil {
+			delivered = append(delivered, row.id)
+
+			continue
+		}
+
+		reason := errs[i]
+		permanent := errors.Is(reason, ErrPermanent)
+
+		if !permanent && r.maxAttempts > 0 && row.attempts >= r.maxAttempts {
+			reason = fmt.Errorf("%w: %w: outbox entry %d failed %d times", ErrOutboxMaxAttempts, reason, row.id, row.attempts)
+			permanent = true
+		}
+
+		if permanent {
+			r.dropHandler(entries[i], reason)
+		}
+
+		if err = r.fail(ctx, row.id, reason, permanent); err != nil {
+			return len(rows), err
+		}
+	}
+
+	return len(rows), r.deliver(ctx, delivered)
+}
+
+// claim claims a batch of pending entries for the lease period.
+func (r *OutboxRelay) claim(ctx context.Context) ([]outboxRow, error) {
+	d, table := r.outbox.dialect, r.outbox.table
+	now := time.Now().UTC()
+
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, fmt.Errorf("%w: could not claim entries of %s", err, table)
+	}
+
+	defer func() { _ = tx.Rollback() }()
+
+	query := fmt.Sprintf(
+		"SELECT id, payload, attempts FROM %s WHERE delivered_at IS NULL AND (claimed_until IS NULL OR claimed_until < %s) ORDER BY id LIMIT %d",
+		table, d.placeholder(1), r.batchSize,
+	)
+
+	if d != SQLDialectSQLite {
+		query += " FOR UPDATE SKIP LOCKED"
+	}
+
+	result, err := tx.QueryContext(ctx, query, now)
+	if err != nil {
+		return nil, fmt.Errorf("%w: could not claim entries of %s", err, table)
+	}
+
+	var rows []outboxRow
+
+	for result.Next() {
+		var row outboxRow
+		if err = result.Scan(&row.id, &row.payload, &row.attempts); err != nil {
+			_ = result.Close()
+
+			return nil, fmt.Errorf("%w: could not claim entries of %s", err, table)
+		}
+
+		// the attempt being claimed.
+		row.attempts++
+		rows = append(rows, row)
+	}
+
+	if err = result.Err(); err != nil {
+		return nil, fmt.Errorf("%w: could not claim entries of %s", err, table)
+	}
+
+	if len(rows) == 0 {
+		return nil, nil
+	}
+
+	ids := make([]interface{}, len(rows))
+	for i, row := range rows {
+		ids[i] = row.id
+	}
+
+	update := fmt.Sprintf("UPDATE %s SET claimed_until = %s, attempts = attempts + 1 WHERE id IN (%s)",
+		table, d.placeholder(1), d.placeholders(2, len(ids)))
+
+	if _, err = tx.ExecContext(ctx, update, append([]interface{}{now.Add(r.lease)}, ids...)...); err != nil {
+		return nil, fmt.Errorf("%w: could not claim entries of %s", err, table)
+	}
+
+	if err = tx.Commit(); err != nil {
+		return nil, fmt.Errorf("%w: could not claim entries of %s", err, table)
+	}
+
+	return rows, nil
+}
+
+// deliver marks the given entries as delivered, or deletes them.
+func (r *OutboxRelay) deliver(ctx context.Context, ids []int64) error {
+	if len(ids) == 0 {
+		return nil
+	}
+
+	d, table := r.outbox.dialect, r.outbox.table
+
+	args := make([]interface{}, 0, len(ids)+1)
+	query := fmt.Sprintf("DELETE FROM %s WHERE id IN (%s)", table, d.placeholders(1, len(ids)))
+
+	if !r.deleteDelivered {
+		args = append(args, time.Now().UTC())
+		query = fmt.Sprintf("UPDATE %s SET delivered_at = %s, claimed_until = NULL, last_error = NULL WHERE id IN (%s)",
+			table, d.placeholder(1), d.placeholders(2, len(ids)))
+	}
+
+	for _, id := range ids {
+		args = append(args, id)
+	}
+
+	if _, err := r.db.ExecContext(ctx, query, args...); err != nil {
+		return fmt.Errorf("%w: could not mark entries of %s as delivered", err, table)
+	}
+
+	return nil
+}
+
+// fail records the error of the given entry. Entries failing permanently are
+// marked as delivered, so they are not claimed again.
+func (r *OutboxRelay) fail(ctx context.Context, id int64, reason error, permanent bool) error {
+	d, table := r.outbox.dialect, r.outbox.table
+
+	query := fmt.Sprintf("UPDATE %s SET last_error = %s WHERE id = %s", table, d.placeholder(1), d.placeholder(2))
+	args := []interface{}{reason.Error(), id}
+
+	if permanent {
+		query = fmt.Sprintf("UPDATE %s SET last_error = %s, delivered_at = %s, claimed_until = NULL WHERE id = %s",
+			table, d.placeholder(1), d.placeholder(2), d.placeholder(3))
+		args = []interface{}{reason.Error(), time.Now().UTC(), id}
+	}
+
+	if _, err := r.db.ExecContext(ctx, query, args...); err != nil {
+		return fmt.Errorf("%w: could not record failure of entry %d of %s", err, id, table)
+	}
+
+	return nil
+}
+
+// placeholders returns the comma separated placeholders of n parameters of a
+// statement, starting at the from-th one.
+func (d SQLDialect) placeholders(from, n int) string {
+	p := make([]string, n)
+	for i := range p {
+		p[i] = d.placeholder(from + i)
+	}
+
+	return strings.Join(p, ", ")
+}
+
+// WithOutboxRelayBatchSize sets the maximum number of entries claimed at once.
+// If size is less than or equal to zero, it will be set to 100.
+func WithOutboxRelayBatchSize(size int) OutboxRelayOption {
+	return func(options *OutboxRelay) {
+		if size <= 0 {
+			size = 100
+		}
+
+		options.batchSize = size
+	}
+}
+
+// WithOutboxRelayInterval sets how often the outbox is polled while there are
+// no pending entries. If interval is less than or equal to zero, it will be
+// set to 1 second.
+func WithOutboxRelayInterval(interval time.Duration) OutboxRelayOption {
+	return func(options *OutboxRelay) {
+		if interval <= 0 {
+			interval = time.Second
+		}
+
+		options.interval = interval
+	}
+}
+
+// WithOutboxRelayLease sets how long claimed entries are reserved for the
+// relay before other relays can claim them, which is also the delay before
+// failed entries are retried. It must exceed the time needed to forward a
+// batch. If lease is less than or equal to zero, it will be set to 1 minute.
+func WithOutboxRelayLease(lease time.Duration) OutboxRelayOption {
+	return func(options *OutboxRelay) {
+		if lease <= 0 {
+			lease = time.Minute
+		}
+
+		options.lease = lease
+	}
+}
+
+// WithOutboxRelayMaxAttempts sets how many times an entry is forwarded before
+// giving up on it, passing it to the drop handler and marking it as delivered.
+// If attempts is less than or equal to zero, entries are retried until they
+// are forwarded, which is the default.
+func WithOutboxRelayMaxAttempts(attempts int) OutboxRelayOption {
+	return func(options *OutboxRelay) {
+		if attempts <= 0 {
+			attempts = 0
+		}
+
+		options.maxAttempts = attempts
+	}
+}
+
+// WithOutboxRelayDeleteDelivered deletes forwarded entries from the outbox,
+// instead of marking them as delivered.
+func WithOutboxRelayDeleteDelivered() OutboxRelayOption {
+	return func(options *OutboxRelay) {
+		options.deleteDelivered = true
+	}
+}
+
+// WithOutboxRelayDropHandler sets the handler of the entries that are dropped
+// because they are permanently rejected or reached the maximum number of
+// attempts. Entries that cannot be decoded are passed as nil. If handler is
+// nil, a no-op handler is used.
+func WithOutboxRelayDropHandler(handler DropHandlerFunc) OutboxRelayOption {
+	return func(options *OutboxRelay) {
+		if handler == nil {
+			handler = func(*Entry, error) {}
+		}
+
+		options.dropHandler = handler
+	}
+}
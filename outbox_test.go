@@ -0,0 +1,204 @@
+package auditrail_test
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/botchris/go-auditrail"
+	"github.com/stretchr/testify/require"
+)
+
+func TestOutbox(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	setup := func(t *testing.T) (*sql.DB, *auditrail.Outbox) {
+		db := newSQLiteDB(t)
+		require.NoError(t, auditrail.MigrateSQLOutbox(ctx, db, auditrail.SQLDialectSQLite, ""))
+
+		outbox, err := auditrail.NewOutbox(auditrail.SQLDialectSQLite)
+		require.NoError(t, err)
+
+		return db, outbox
+	}
+
+	write := func(t *testing.T, db *sql.DB, outbox *auditrail.Outbox, commit bool, entries ...*auditrail.Entry) {
+		tx, err := db.BeginTx(ctx, nil)
+		require.NoError(t, err)
+
+		for _, entry := range entries {
+			require.NoError(t, outbox.Write(ctx, tx, entry))
+		}
+
+		if commit {
+			require.NoError(t, tx.Commit())
+		} else {
+			require.NoError(t, tx.Rollback())
+		}
+	}
+
+	t.Run("GIVEN entries written in transactions WHEN relaying THEN only the committed ones are forwarded and delivered", func(t *testing.T) {
+		db, outbox := setup(t)
+
+		committed, rolledBack := newFakeEntry().AppendDetails("order_id", "1234"), newFakeEntry()
+		write(t, db, outbox, true, committed, committed)
+		write(t, db, outbox, false, rolledBack)
+
+		dst := auditrail.NewMemoryLogger()
+		relay := auditrail.NewOutboxRelay(db, outbox, dst)
+
+		n, err := relay.RelayOnce(ctx)
+		require.NoError(t, err)
+		require.Equal(t, 1, n)
+		require.True(t, dst.Has(committed.GetIdempotencyID()))
+		require.False(t, dst.Has(rolledBack.GetIdempotencyID()))
+		require.Equal(t, "1234", dst.Trail()[0].GetDetails()["order_id"])
+
+		var pending int
+		require.NoError(t, db.QueryRowContext(ctx, "SELECT COUNT(*) FROM audit_outbox WHERE delivered_at IS NULL").Scan(&pending))
+		require.Zero(t, pending)
+
+		n, err = relay.RelayOnce(ctx)
+		require.NoError(t, err)
+		require.Zero(t, n)
+	})
+
+	t.Run("GIVEN a relay failing to forward WHEN the lease expires THEN entries are claimed again", func(t *testing.T) {
+		db, outbox := setup(t)
+		write(t, db, outbox, true, newFakeEntry(), newFakeEntry())
+
+		failing := &failingLogger{Logger: auditrail.NewMemoryLogger(), err: fmt.Errorf("%w: unavailable", auditrail.ErrRetryable)}
+		crashed := auditrail.NewOutboxRelay(db, outbox, failing, auditrail.WithOutboxRelayLease(200*time.Millisecond))
+
+		n, err := crashed.RelayOnce(ctx)
+		require.NoError(t, err)
+		require.Equal(t, 2, n)
+
+		dst := auditrail.NewMemoryLogger()
+		relay := auditrail.NewOutboxRelay(db, outbox, dst)
+
+		n, err = relay.RelayOnce(ctx)
+		require.NoError(t, err)
+		require.Zero(t, n, "claimed entries must not be claimed by other relays")
+
+		time.Sleep(250 * time.Millisecond)
+
+		n, err = relay.RelayOnce(ctx)
+		require.NoError(t, err)
+		require.Equal(t, 2, n)
+		require.Equal(t, 2, dst.Size())
+
+		var attempts int
+		require.NoError(t, db.QueryRowContext(ctx, "SELECT MAX(attempts) FROM audit_outbox").Scan(&attempts))
+		require.Equal(t, 2, attempts)
+	})
+
+	t.Run("GIVEN an entry rejected permanently WHEN relaying THEN it is dropped and not claimed again", func(t *testing.T) {
+		db, outbox := setup(t)
+
+		entry := newFakeEntry()
+		write(t, db, outbox, true, entry)
+
+		var dropped []*auditrail.Entry
+
+		rejecting := &failingLogger{Logger: auditrail.NewMemoryLogger(), err: fmt.Errorf("%w: invalid entry", auditrail.ErrPermanent)}
+		relay := auditrail.NewOutboxRelay(db, outbox, rejecting,
+			auditrail.WithOutboxRelayLease(time.Millisecond),
+			auditrail.WithOutboxRelayDropHandler(func(e *auditrail.Entry, err error) {
+				require.ErrorIs(t, err, auditrail.ErrPermanent)
+				dropped = append(dropped, e)
+			}),
+		)
+
+		_, err := relay.RelayOnce(ctx)
+		require.NoError(t, err)
+		require.Len(t, dropped, 1)
+		require.Equal(t, entry.GetIdempotencyID(), dropped[0].GetIdempotencyID())
+
+		var lastError string
+		require.NoError(t, db.QueryRowContext(ctx, "SELECT last_error FROM audit_outbox").Scan(&lastError))
+		require.Contains(t, lastError, "invalid entry")
+
+		time.Sleep(5 * time.Millisecond)
+
+		n, err := relay.RelayOnce(ctx)
+		require.NoError(t, err)
+		require.Zero(t, n)
+	})
+
+	t.Run("GIVEN a relay with a maximum of attempts WHEN an entry keeps failing THEN it is dead-lettered through the drop handler", func(t *testing.T) {
+		db, outbox := setup(t)
+
+		entry := newFakeEntry()
+		write(t, db, outbox, true, entry)
+
+		var dropped []error
+
+		failing := &failingLogger{Logger: auditrail.NewMemoryLogger(), err: fmt.Errorf("%w: unavailable", auditrail.ErrRetryable)}
+		relay := auditrail.NewOutboxRelay(db, outbox, failing,
+			auditrail.WithOutboxRelayLease(time.Millisecond),
+			auditrail.WithOutboxRelayMaxAttempts(3),
+			auditrail.WithOutboxRelayDropHandler(func(e *auditrail.Entry, err error) {
+				require.Equal(t, entry.GetIdempotencyID(), e.GetIdempotencyID())
+				dropped = append(dropped, err)
+			}),
+		)
+
+		for attempt := 1; attempt <= 3; attempt++ {
+			require.Empty(t, dropped)
+
+			n, err := relay.RelayOnce(ctx)
+			require.NoError(t, err)
+			require.Equal(t, 1, n)
+
+			time.Sleep(5 * time.Millisecond)
+		}
+
+		require.Len(t, dropped, 1)
+		require.ErrorIs(t, dropped[0], auditrail.ErrOutboxMaxAttempts)
+		require.ErrorIs(t, dropped[0], auditrail.ErrRetryable)
+
+		n, err := relay.RelayOnce(ctx)
+		require.NoError(t, err)
+		require.Zero(t, n)
+	})
+
+	t.Run("GIVEN a running relay WHEN entries are written THEN they are forwarded and deleted until the context is done", func(t *testing.T) {
+		db, outbox := setup(t)
+
+		dst := auditrail.NewMemoryLogger()
+		relay := auditrail.NewOutboxRelay(db, outbox, dst,
+			auditrail.WithOutboxRelayBatchSize(2),
+			auditrail.WithOutboxRelayInterval(time.Millisecond),
+			auditrail.WithOutboxRelayDeleteDelivered(),
+		)
+
+		runCtx, stop := context.WithCancel(ctx)
+
+		done := make(chan error)
+		go func() { done <- relay.Run(runCtx) }()
+
+		for i := 0; i < 5; i++ {
+			write(t, db, outbox, true, newFakeEntry())
+		}
+
+		require.Eventually(t, func() bool { return dst.Size() == 5 }, 5*time.Second, time.Millisecond)
+		stop()
+		require.NoError(t, <-done)
+
+		var rows int
+		require.NoError(t, db.QueryRowContext(ctx, "SELECT COUNT(*) FROM audit_outbox").Scan(&rows))
+		require.Zero(t, rows)
+	})
+
+	t.Run("GIVEN an unavailable database WHEN running a relay THEN the error is returned", func(t *testing.T) {
+		db, outbox := setup(t)
+		require.NoError(t, db.Close())
+
+		err := auditrail.NewOutboxRelay(db, outbox, auditrail.NewMemoryLogger()).Run(ctx)
+		require.ErrorContains(t, err, "could not claim entries")
+	})
+}
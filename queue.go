@@ -3,6 +3,7 @@ package auditrail
 import (
 	"container/list"
 	"context"
+	"errors"
 	"fmt"
 	"sync"
 	"time"
@@ -12,31 +13,108 @@ import (
 // from the queue.
 type DropHandlerFunc func(*Entry, error)
 
+// ErrQueueFull is reported when an entry does not fit in a bounded queue. It
+// is passed to the drop handler for every entry dropped because of an
+// overflow, and returned by Log under the [QueueOverflowReject] policy.
+var ErrQueueFull = errors.New("queue is full")
+
+// Reasons of the entries dropped because of an overflow, all of which wrap
+// [ErrQueueFull], so drop handlers can tell them apart using [errors.Is].
+var (
+	// ErrQueueDroppedNewest is reported for the entries dropped under the
+	// [QueueOverflowDropNewest] policy.
+	ErrQueueDroppedNewest = fmt.Errorf("%w: dropped newest entry", ErrQueueFull)
+
+	// ErrQueueDroppedOldest is reported for the entries dropped under the
+	// [QueueOverflowDropOldest] policy.
+	ErrQueueDroppedOldest = fmt.Errorf("%w: dropped oldest entry", ErrQueueFull)
+
+	// ErrQueueRejected is reported for the entries rejected under the
+	// [QueueOverflowReject] policy.
+	ErrQueueRejected = fmt.Errorf("%w: rejected entry", ErrQueueFull)
+
+	// ErrQueueWaitAborted is reported for the entries whose context was done
+	// while waiting for room under the [QueueOverflowBlock] policy.
+	ErrQueueWaitAborted = fmt.Errorf("%w: dropped entry while waiting for room", ErrQueueFull)
+)
+
+// QueueOverflowPolicy defines what a bounded queue does with an entry that does
+// not fit in it.
+type QueueOverflowPolicy int
+
+const (
+	// QueueOverflowBlock blocks Log until there is room for the entry, or until
+	// the context of the caller is done, in which case the entry is dropped.
+	QueueOverflowBlock QueueOverflowPolicy = iota
+
+	// QueueOverflowDropNewest drops the entry being logged.
+	QueueOverflowDropNewest
+
+	// QueueOverflowDropOldest drops the oldest buffered entries until there is
+	// room for the entry being logged.
+	QueueOverflowDropOldest
+
+	// QueueOverflowReject drops the entry being logged and returns an error
+	// wrapping [ErrQueueFull] to the caller.
+	QueueOverflowReject
+)
+
+// String returns the name of the policy.
+func (p QueueOverflowPolicy) String() string {
+	switch p {
+	case QueueOverflowBlock:
+		return "block"
+	case QueueOverflowDropNewest:
+		return "drop newest"
+	case QueueOverflowDropOldest:
+		return "drop oldest"
+	case QueueOverflowReject:
+		return "reject"
+	default:
+		return fmt.Sprintf("QueueOverflowPolicy(%d)", int(p))
+	}
+}
+
 // QueueOption is a function that configures a queue.
 type QueueOption func(options *queueOptions)
 
 type queueEnvelope struct {
 	message *Entry
-	closed  bool
+	size    int
 }
 
 type queueOptions struct {
 	timeout      time.Duration
 	dropHandling DropHandlerFunc
 	throughput   int
+	batchSize    int
+	maxEntries   int
+	maxBytes     int
+	overflow     QueueOverflowPolicy
 }
 
 var defaultQueueOptions = queueOptions{
 	timeout:      3 * time.Second,
 	dropHandling: func(*Entry, error) {},
 	throughput:   1,
+	batchSize:    100,
+	overflow:     QueueOverflowBlock,
 }
 
+var _ ShutdownLogger = (*queue)(nil)
+
 type queue struct {
 	dst          Logger
 	opts         queueOptions
 	list         *list.List
+	bytes        int
+	inflight     int
+	flushed      int
+	stop         context.Context
+	cancel       context.CancelCauseFunc
 	cond         *sync.Cond
+	space        chan struct{}
+	waiting      int
 	closed       bool
 	closeChannel chan struct{}
 	mu           sync.RWMutex
@@ -44,12 +122,18 @@ type queue struct {
 }
 
 // NewQueue builds a new logger queue which provides a buffer for entries to be
-// processed asynchronously. See options for configuration.
+// processed asynchronously. When the destination is a [BatchLogger], workers
+// write the buffered entries in batches.
+//
+// The buffer is unbounded unless its capacity is limited by number of entries
+// or by size, in which case entries that do not fit are handled according to
+// the overflow policy. See options for configuration.
 func NewQueue(dst Logger, options ...QueueOption) Logger {
 	opts := defaultQueueOptions
 	q := &queue{
 		dst:          dst,
 		list:         list.New(),
+		space:        make(chan struct{}),
 		closeChannel: make(chan struct{}),
 	}
 
@@ -59,6 +143,7 @@ func NewQueue(dst Logger, options ...QueueOption) Logger {
 
 	q.opts = opts
 	q.cond = sync.NewCond(&q.mu)
+	q.stop, q.cancel = context.WithCancelCause(context.Background())
 
 	q.wg.Add(q.opts.throughput)
 
@@ -70,40 +155,176 @@ func NewQueue(dst Logger, options ...QueueOption) Logger {
 }
 
 // Log writes the given log entry to the queue for asynchronous processing.
-func (q *queue) Log(_ context.Context, entry *Entry) error {
+// When the queue is full, the entry is handled according to the overflow
+// policy, and every dropped entry is passed to the drop handler along with an
+// error wrapping [ErrQueueFull] and the reason it was dropped, such as
+// [ErrQueueDroppedOldest].
+func (q *queue) Log(ctx context.Context, entry *Entry) error {
+	envelope := queueEnvelope{message: entry}
+
+	if q.opts.maxBytes > 0 {
+		envelope.size = entrySize(entry)
+	}
+
 	q.mu.Lock()
-	defer q.mu.Unlock()
+
+	var dropped []*Entry
+
+	for !q.fits(envelope.size) {
+		if q.closed {
+			break
+		}
+
+		switch q.opts.overflow {
+		case QueueOverflowDropNewest:
+			q.mu.Unlock()
+			q.opts.dropHandling(entry, ErrQueueDroppedNewest)
+
+			return nil
+		case QueueOverflowDropOldest:
+			dropped = append(dropped, q.pop().message)
+
+			continue
+		case QueueOverflowReject:
+			q.mu.Unlock()
+
+			q.opts.dropHandling(entry, ErrQueueRejected)
+
+			return ErrQueueRejected
+		}
+
+		if err := q.wait(ctx); err != nil {
+			q.mu.Unlock()
+
+			err = fmt.Errorf("%w: %w", ErrQueueWaitAborted, err)
+			q.opts.dropHandling(entry, err)
+
+			return err
+		}
+	}
 
 	if q.closed {
+		q.mu.Unlock()
+
 		return fmt.Errorf("%w: queue is closed", ErrTrailClosed)
 	}
 
-	q.list.PushBack(queueEnvelope{message: entry}) // add to queue
-	q.cond.Signal()                                // signal waiters
+	q.list.PushBack(envelope) // add to queue
+	q.bytes += envelope.size
+	q.cond.Signal() // signal waiters
+	q.mu.Unlock()
+
+	for _, oldest := range dropped {
+		q.opts.dropHandling(oldest, ErrQueueDroppedOldest)
+	}
 
 	return nil
 }
 
-// Close shutdown the logger queue.
+// fits reports whether an entry of the given size fits in the queue. Entries
+// larger than the size limit are only accepted by an empty queue, so they are
+// not rejected forever. Must be called with the lock held.
+func (q *queue) fits(size int) bool {
+	if q.list.Len() == 0 {
+		return true
+	}
+
+	if q.opts.maxEntries > 0 && q.list.Len() >= q.opts.maxEntries {
+		return false
+	}
+
+	return q.opts.maxBytes <= 0 || q.bytes+size <= q.opts.maxBytes
+}
+
+// wait releases the lock until entries are removed from the queue, the queue
+// is closed or ctx is done, in which case its error is returned. Must be
+// called with the lock held, which is held again on return.
+func (q *queue) wait(ctx context.Context) error {
+	space := q.space
+	q.waiting++
+	q.mu.Unlock()
+
+	var err error
+
+	select {
+	case <-space:
+	case <-q.closeChannel:
+	case <-ctx.Done():
+		err = ctx.Err()
+	}
+
+	q.mu.Lock()
+	q.waiting--
+
+	return err
+}
+
+// pop removes the oldest entry of the queue, waking up the callers waiting
+// for room. Must be called with the lock held on a non-empty queue.
+func (q *queue) pop() queueEnvelope {
+	front := q.list.Front()
+	q.list.Remove(front)
+
+	envelope, _ := front.Value.(queueEnvelope)
+	q.bytes -= envelope.size
+
+	if q.waiting > 0 {
+		close(q.space)
+		q.space = make(chan struct{})
+	}
+
+	return envelope
+}
+
+// Close shutdown the logger queue, waiting for workers to write every entry.
 func (q *queue) Close() error {
+	_, err := q.Shutdown(context.Background())
+
+	return err
+}
+
+// Shutdown shutdowns the logger queue, letting workers write buffered entries
+// until ctx is done. Then, buffered entries are abandoned, and the writes in
+// progress are cancelled and counted as abandoned, their entries being passed
+// to the drop handler by workers if they fail. The destination logger is
+// shut down within ctx once workers finish.
+func (q *queue) Shutdown(ctx context.Context) (ShutdownReport, error) {
 	q.mu.Lock()
 	if q.closed {
 		q.mu.Unlock()
 
-		return nil
+		return ShutdownReport{}, nil
 	}
 
 	// set closing flag
 	q.closed = true
+	flushed := q.flushed
 
-	q.cond.Signal() // signal flushes queue
-	q.cond.Wait()   // wait for signal from last flush
-	q.mu.Unlock()   // unlock to allow workers to finish
-	q.wg.Wait()     // wait for all worker goroutines to finish
+	q.cond.Broadcast() // wake up workers to flush the queue
+	q.mu.Unlock()
+
+	drained := make(chan struct{})
+
+	go func() {
+		q.wg.Wait() // wait for all worker goroutines to finish
+		close(drained)
+	}()
+
+	select {
+	case <-drained:
+	case <-ctx.Done():
+		return q.abandon(ctx.Err(), flushed)
+	}
 
 	defer close(q.closeChannel)
 
-	return q.dst.Close()
+	q.mu.RLock()
+	report := ShutdownReport{Flushed: q.flushed - flushed}
+	q.mu.RUnlock()
+
+	dst, err := Shutdown(ctx, q.dst)
+
+	return report.add(dst), err
 }
 
 func (q *queue) Closed() <-chan struct{} {
@@ -121,31 +342,87 @@ func (q *queue) IsClosed() bool {
 	return q.closed
 }
 
+// abandon drops the buffered entries and cancels the writes in progress
+// because of the given cause. The destination logger is closed in the
+// background once workers finish.
+func (q *queue) abandon(cause error, flushed int) (ShutdownReport, error) {
+	q.mu.Lock()
+
+	var abandoned []*Entry
+
+	for q.list.Len() > 0 {
+		abandoned = append(abandoned, q.pop().message)
+	}
+
+	report := ShutdownReport{
+		Flushed:   q.flushed - flushed,
+		Abandoned: len(abandoned) + q.inflight,
+	}
+
+	q.mu.Unlock()
+	q.cancel(cause)
+
+	err := abandonedError(cause)
+	for _, entry := range abandoned {
+		q.opts.dropHandling(entry, err)
+	}
+
+	go func() {
+		q.wg.Wait()
+		_ = q.dst.Close()
+		close(q.closeChannel)
+	}()
+
+	return report, fmt.Errorf("%w: queue could not be drained before the deadline", cause)
+}
+
 // run is the main goroutine to flush messages to the target logger.
 func (q *queue) run() {
 	defer q.wg.Done()
 
-	baseCtx := context.Background()
+	size := 1
+
+	if _, ok := q.dst.(BatchLogger); ok {
+		size = q.opts.batchSize
+	}
 
 	for {
-		envelope := q.next()
-		if envelope.closed {
-			return // queueClosed block means event queue is closed.
+		entries, closed := q.next(size)
+		if closed {
+			return // queue is closed and drained.
 		}
 
-		ctx, cancel := context.WithTimeout(baseCtx, q.opts.timeout)
-		if err := q.dst.Log(ctx, envelope.message); err != nil {
-			q.opts.dropHandling(envelope.message, err)
-		}
+		ctx, cancel := context.WithTimeout(q.stop, q.opts.timeout)
+		errs := logBatch(ctx, q.dst, entries)
 
 		cancel()
+
+		written := len(entries)
+
+		for i, err := range errs {
+			if err == nil {
+				continue
+			}
+
+			if q.stop.Err() != nil {
+				err = abandonedError(context.Cause(q.stop))
+			}
+
+			q.opts.dropHandling(entries[i], err)
+			written--
+		}
+
+		q.mu.Lock()
+		q.inflight -= len(entries)
+		q.flushed += written
+		q.mu.Unlock()
 	}
 }
 
 // next encompasses the critical section of the run loop. When the queue is
 // empty, it will block on the condition. If new data arrives, it will wake
-// and return a block. When closed, queueClosed constant will be returned.
-func (q *queue) next() queueEnvelope {
+// and return up to size entries. When closed and drained, it returns true.
+func (q *queue) next(size int) ([]*Entry, bool) {
 	q.mu.Lock()
 	defer q.mu.Unlock()
 
@@ -153,22 +430,21 @@ func (q *queue) next() queueEnvelope {
 		if q.closed {
 			q.cond.Broadcast()
 
-			return queueEnvelope{closed: true}
+			return nil, true
 		}
 
 		q.cond.Wait()
 	}
 
-	front := q.list.Front()
-	block, ok := front.Value.(queueEnvelope)
+	entries := make([]*Entry, 0, min(size, q.list.Len()))
 
-	if !ok {
-		return queueEnvelope{closed: true}
+	for len(entries) < size && q.list.Len() > 0 {
+		entries = append(entries, q.pop().message)
 	}
 
-	q.list.Remove(front)
+	q.inflight += len(entries)
 
-	return block
+	return entries, false
 }
 
 // WithQueueTimeout controls the maximum amount of time a worker will wait for the target
@@ -207,3 +483,54 @@ func WithQueueThroughput(throughput int) QueueOption {
 		opts.throughput = throughput
 	}
 }
+
+// WithQueueBatchSize controls the maximum number of entries a worker writes at
+// once when the destination is a [BatchLogger]. If size is less than or equal
+// to zero, it will be set to 100.
+func WithQueueBatchSize(size int) QueueOption {
+	return func(opts *queueOptions) {
+		if size <= 0 {
+			size = 100
+		}
+
+		opts.batchSize = size
+	}
+}
+
+// WithQueueMaxEntries limits the number of entries buffered by the queue. If n
+// is less than or equal to zero, the number of entries is not limited, which
+// is the default.
+func WithQueueMaxEntries(n int) QueueOption {
+	return func(opts *queueOptions) {
+		if n < 0 {
+			n = 0
+		}
+
+		opts.maxEntries = n
+	}
+}
+
+// WithQueueMaxBytes limits the size of the entries buffered by the queue,
+// estimated from their JSON representation. If n is less than or equal to
+// zero, the size is not limited, which is the default.
+func WithQueueMaxBytes(n int) QueueOption {
+	return func(opts *queueOptions) {
+		if n < 0 {
+			n = 0
+		}
+
+		opts.maxBytes = n
+	}
+}
+
+// WithQueueOverflowPolicy sets what the queue does with entries that do not
+// fit in it once its capacity is limited. Defaults to [QueueOverflowBlock].
+func WithQueueOverflowPolicy(policy QueueOverflowPolicy) QueueOption {
+	return func(opts *queueOptions) {
+		if policy < QueueOverflowBlock || policy > QueueOverflowReject {
+			policy = QueueOverflowBlock
+		}
+
+		opts.overflow = policy
+	}
+}
@@ -3,6 +3,7 @@ package auditrail_test
 import (
 	"context"
 	"errors"
+	"fmt"
 	"sync"
 	"sync/atomic"
 	"testing"
@@ -90,6 +91,244 @@ func TestQueueDrop(t *testing.T) {
 	checkClose(t, ctx, eq)
 }
 
+func TestQueueBatches(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	dst := newBatchRecorder()
+	gate := make(chan struct{})
+	dst.fail = func(*auditrail.Entry) error {
+		<-gate
+
+		return nil
+	}
+
+	queue := auditrail.NewQueue(dst, auditrail.WithQueueBatchSize(50))
+
+	n := 120
+	for i := 0; i < n; i++ {
+		require.NoError(t, queue.Log(ctx, newFakeEntry()))
+	}
+
+	close(gate)
+	checkClose(t, ctx, queue)
+
+	require.EqualValues(t, n, dst.Size())
+
+	for _, size := range dst.Batches() {
+		require.LessOrEqual(t, size, 50)
+	}
+
+	require.Less(t, len(dst.Batches()), n)
+}
+
+func TestQueueOverflow(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	// setup returns a queue whose only worker is stuck writing a first entry,
+	// so the following entries are buffered until the returned function is
+	// called.
+	setup := func(t *testing.T, options ...auditrail.QueueOption) (auditrail.Logger, *auditrail.MemoryLogger, *overflows, func()) {
+		dst := auditrail.NewMemoryLogger()
+		stuck := &blocked{Logger: dst, release: make(chan struct{}), received: make(chan struct{}, 1)}
+		dropped := &overflows{}
+
+		queue := auditrail.NewQueue(stuck, append(options, auditrail.WithQueueDropHandler(dropped.add))...)
+
+		require.NoError(t, queue.Log(ctx, newFakeEntry()))
+
+		select {
+		case <-stuck.received:
+		case <-ctx.Done():
+			t.Fatal("the worker did not take the first entry")
+		}
+
+		return queue, dst, dropped, func() { close(stuck.release) }
+	}
+
+	t.Run("GIVEN a full queue dropping newest entries WHEN logging THEN the entry is dropped", func(t *testing.T) {
+		queue, dst, dropped, release := setup(t,
+			auditrail.WithQueueMaxEntries(2),
+			auditrail.WithQueueOverflowPolicy(auditrail.QueueOverflowDropNewest),
+		)
+
+		buffered, newest := []*auditrail.Entry{newFakeEntry(), newFakeEntry()}, newFakeEntry()
+		for _, entry := range append(buffered, newest) {
+			require.NoError(t, queue.Log(ctx, entry))
+		}
+
+		release()
+		checkClose(t, ctx, queue)
+
+		require.Equal(t, []string{newest.GetIdempotencyID()}, dropped.ids())
+		require.ErrorIs(t, dropped.reasons()[0], auditrail.ErrQueueDroppedNewest)
+		require.True(t, dst.Has(buffered[0].GetIdempotencyID(), buffered[1].GetIdempotencyID()))
+		require.False(t, dst.Has(newest.GetIdempotencyID()))
+	})
+
+	t.Run("GIVEN a full queue dropping oldest entries WHEN logging THEN the oldest buffered entry is dropped", func(t *testing.T) {
+		queue, dst, dropped, release := setup(t,
+			auditrail.WithQueueMaxEntries(2),
+			auditrail.WithQueueOverflowPolicy(auditrail.QueueOverflowDropOldest),
+		)
+
+		entries := []*auditrail.Entry{newFakeEntry(), newFakeEntry(), newFakeEntry()}
+		for _, entry := range entries {
+			require.NoError(t, queue.Log(ctx, entry))
+		}
+
+		release()
+		checkClose(t, ctx, queue)
+
+		require.Equal(t, []string{entries[0].GetIdempotencyID()}, dropped.ids())
+		require.ErrorIs(t, dropped.reasons()[0], auditrail.ErrQueueDroppedOldest)
+		require.True(t, dst.Has(entries[1].GetIdempotencyID(), entries[2].GetIdempotencyID()))
+	})
+
+	t.Run("GIVEN a queue full by size rejecting entries WHEN logging THEN ErrQueueFull is returned", func(t *testing.T) {
+		queue, dst, dropped, release := setup(t,
+			auditrail.WithQueueMaxBytes(1),
+			auditrail.WithQueueOverflowPolicy(auditrail.QueueOverflowReject),
+		)
+
+		buffered, rejected := newFakeEntry(), newFakeEntry()
+
+		require.NoError(t, queue.Log(ctx, buffered), "entries larger than the limit fit in an empty queue")
+		require.ErrorIs(t, queue.Log(ctx, rejected), auditrail.ErrQueueRejected)
+
+		release()
+		checkClose(t, ctx, queue)
+
+		require.Equal(t, []string{rejected.GetIdempotencyID()}, dropped.ids())
+		require.ErrorIs(t, dropped.reasons()[0], auditrail.ErrQueueFull)
+		require.True(t, dst.Has(buffered.GetIdempotencyID()))
+		require.False(t, dst.Has(rejected.GetIdempotencyID()))
+	})
+
+	t.Run("GIVEN a full blocking queue WHEN logging THEN it waits for room until the context is done", func(t *testing.T) {
+		queue, dst, dropped, release := setup(t, auditrail.WithQueueMaxEntries(1))
+
+		buffered, expired, waiting := newFakeEntry(), newFakeEntry(), newFakeEntry()
+		require.NoError(t, queue.Log(ctx, buffered))
+
+		expiring, cancelExpiring := context.WithTimeout(ctx, 20*time.Millisecond)
+		defer cancelExpiring()
+
+		err := queue.Log(expiring, expired)
+		require.ErrorIs(t, err, auditrail.ErrQueueWaitAborted)
+		require.ErrorIs(t, err, context.DeadlineExceeded)
+
+		done := make(chan error)
+		go func() { done <- queue.Log(ctx, waiting) }()
+
+		select {
+		case err = <-done:
+			t.Fatalf("log returned while the queue is full: %v", err)
+		case <-time.After(20 * time.Millisecond):
+		}
+
+		release()
+		require.NoError(t, <-done)
+		checkClose(t, ctx, queue)
+
+		require.Equal(t, []string{expired.GetIdempotencyID()}, dropped.ids())
+		require.EqualValues(t, 3, dst.Size())
+		require.True(t, dst.Has(buffered.GetIdempotencyID(), waiting.GetIdempotencyID()))
+	})
+}
+
+func TestQueueShutdown(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	t.Run("GIVEN a queue with buffered entries WHEN shutting down in time THEN they are flushed", func(t *testing.T) {
+		dst := auditrail.NewMemoryLogger()
+		queue := auditrail.NewQueue(&delayed{Logger: dst, delay: time.Millisecond})
+
+		for i := 0; i < 10; i++ {
+			require.NoError(t, queue.Log(ctx, newFakeEntry()))
+		}
+
+		report, err := auditrail.Shutdown(ctx, queue)
+		require.NoError(t, err)
+		require.Equal(t, auditrail.ShutdownReport{Flushed: 10}, report)
+		require.EqualValues(t, 10, dst.Size())
+		require.True(t, queue.IsClosed())
+	})
+
+	t.Run("GIVEN a stalled destination WHEN the shutdown deadline is reached THEN entries are abandoned", func(t *testing.T) {
+		var abandoned atomic.Int64
+
+		queue := auditrail.NewQueue(&stalled{Logger: auditrail.NewMemoryLogger()},
+			auditrail.WithQueueTimeout(time.Minute),
+			auditrail.WithQueueDropHandler(func(_ *auditrail.Entry, err error) {
+				require.ErrorIs(t, err, auditrail.ErrTrailClosed)
+				abandoned.Add(1)
+			}),
+		)
+
+		for i := 0; i < 4; i++ {
+			require.NoError(t, queue.Log(ctx, newFakeEntry()))
+		}
+
+		time.Sleep(20 * time.Millisecond) // let the worker take the first entry.
+
+		deadline, cancelDeadline := context.WithTimeout(ctx, 20*time.Millisecond)
+		defer cancelDeadline()
+
+		report, err := auditrail.Shutdown(deadline, queue)
+		require.ErrorIs(t, err, context.DeadlineExceeded)
+		require.Equal(t, auditrail.ShutdownReport{Abandoned: 4}, report)
+
+		require.Eventually(t, func() bool { return abandoned.Load() == 4 }, 5*time.Second, time.Millisecond)
+
+		select {
+		case <-queue.Closed():
+		case <-ctx.Done():
+			t.Fatal("queue was not closed after abandoning its entries")
+		}
+	})
+}
+
+// overflows records the entries dropped because of a full queue.
+type overflows struct {
+	entries []*auditrail.Entry
+	errs    []error
+	mu      sync.Mutex
+}
+
+func (o *overflows) add(e *auditrail.Entry, err error) {
+	if !errors.Is(err, auditrail.ErrQueueFull) {
+		return
+	}
+
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	o.entries = append(o.entries, e)
+	o.errs = append(o.errs, err)
+}
+
+func (o *overflows) reasons() []error {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	return append([]error(nil), o.errs...)
+}
+
+func (o *overflows) ids() []string {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	ids := make([]string, len(o.entries))
+	for i, e := range o.entries {
+		ids[i] = e.GetIdempotencyID()
+	}
+
+	return ids
+}
+
 type dropper struct {
 	auditrail.Logger
 	err    error
@@ -124,3 +363,35 @@ func (d *delayed) Log(ctx context.Context, e *auditrail.Entry) error {
 
 	return d.Logger.Log(ctx, e)
 }
+
+// blocked is a logger whose writes wait for release to be closed. If set,
+// received is signaled whenever a write starts waiting.
+type blocked struct {
+	auditrail.Logger
+	release  chan struct{}
+	received chan struct{}
+}
+
+func (b *blocked) Log(ctx context.Context, e *auditrail.Entry) error {
+	if b.received != nil {
+		select {
+		case b.received <- struct{}{}:
+		default:
+		}
+	}
+
+	<-b.release
+
+	return b.Logger.Log(ctx, e)
+}
+
+// stalled is a logger whose writes never complete until their context is done.
+type stalled struct {
+	auditrail.Logger
+}
+
+func (s *stalled) Log(ctx context.Context, _ *auditrail.Entry) error {
+	<-ctx.Done()
+
+	return fmt.Errorf("%w: %w", auditrail.ErrRetryable, ctx.Err())
+}
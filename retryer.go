@@ -32,17 +32,24 @@ type RetryStrategy interface {
 	Success(*Entry)
 }
 
+var _ ShutdownLogger = (*retryer)(nil)
+
 type retryer struct {
 	dst          Logger
 	strategy     RetryStrategy
 	dropHandling DropHandlerFunc
+	inflight     sync.WaitGroup
+	abandoned    atomic.Int64
 	closed       bool
 	closedChan   chan struct{}
 	mu           sync.RWMutex
 }
 
 // NewRetryer creates a new retryer that will retry failed log writes using the
-// provided strategy.
+// provided strategy. Writes failing with an error wrapping [ErrPermanent] are
+// not retried and go straight to the drop handler. Writes stop being retried
+// once their context is done, returning the context error, and are abandoned
+// to the drop handler once the retryer is shut down.
 func NewRetryer(dst Logger, opts ...RetryerOption) Logger {
 	r := &retryer{
 		dst:          dst,
@@ -59,7 +66,6 @@ func NewRetryer(dst Logger, opts ...RetryerOption) Logger {
 }
 
 func (r *retryer) Log(ctx context.Context, entry *Entry) error {
-retry:
 	r.mu.RLock()
 
 	if r.closed {
@@ -68,34 +74,54 @@ retry:
 		return fmt.Errorf("%w: retriyer could not log the given entry", ErrTrailClosed)
 	}
 
+	r.inflight.Add(1)
 	r.mu.RUnlock()
 
-	if backoff := r.strategy.Proceed(entry); backoff > 0 {
-		select {
-		case <-time.After(backoff):
-			// TODO: This branch holds up the next try. Before, we
-			// would simply break to the "retry" label and then possibly wait
-			// again. However, this requires all retry strategies to have a
-			// large probability of probing the sync for success, rather than
-			// just backing off and sending the request.
-		case <-r.Closed():
-			return ErrTrailClosed
+	defer r.inflight.Done()
+
+	var last error
+
+	for {
+		if backoff := r.strategy.Proceed(entry); backoff > 0 {
+			select {
+			case <-time.After(backoff):
+				// TODO: This branch holds up the next try. Before, we
+				// would simply break to the "retry" label and then possibly wait
+				// again. However, this requires all retry strategies to have a
+				// large probability of probing the sync for success, rather than
+				// just backing off and sending the request.
+			case <-r.Closed():
+				return r.abandon(entry, last)
+			case <-ctx.Done():
+				return r.expired(ctx, last)
+			}
+		}
+
+		err := r.dst.Log(ctx, entry)
+		if err == nil {
+			break
 		}
-	}
 
-	if err := r.dst.Log(ctx, entry); err != nil {
 		if errors.Is(err, ErrTrailClosed) {
 			// terminal!
 			return err
 		}
 
-		if r.strategy.Failure(entry, err) {
+		if errors.Is(err, ErrPermanent) || r.strategy.Failure(entry, err) {
 			r.dropHandling(entry, err)
 
 			return nil
 		}
 
-		goto retry
+		if r.IsClosed() {
+			return r.abandon(entry, err)
+		}
+
+		if ctx.Err() != nil {
+			return r.expired(ctx, err)
+		}
+
+		last = err
 	}
 
 	r.strategy.Success(entry)
@@ -103,23 +129,71 @@ retry:
 	return nil
 }
 
+// abandon stops retrying the given entry because the retryer is shutting
+// down, passing it to the drop handler along with the cause.
+func (r *retryer) abandon(entry *Entry, last error) error {
+	err := abandonedError(last)
+
+	r.abandoned.Add(1)
+	r.dropHandling(entry, err)
+
+	return err
+}
+
+// expired returns the error of a write whose context is done before the entry
+// could be logged, because of the given last failure. The entry is left to the
+// caller, which may retry it or pass it to its own drop handler.
+func (r *retryer) expired(ctx context.Context, last error) error {
+	if last == nil {
+		return fmt.Errorf("%w: retryer could not log the given entry", ctx.Err())
+	}
+
+	return fmt.Errorf("%w: %w: retryer could not log the given entry", ctx.Err(), last)
+}
+
 func (r *retryer) Close() error {
+	_, err := r.Shutdown(context.Background())
+
+	return err
+}
+
+// Shutdown stops retrying failed writes, abandoning their entries, and waits
+// until ctx is done for the writes in progress before shutting down the
+// destination logger within ctx.
+func (r *retryer) Shutdown(ctx context.Context) (ShutdownReport, error) {
 	r.mu.Lock()
-	defer r.mu.Unlock()
 
 	if r.closed {
-		return nil
-	}
+		r.mu.Unlock()
 
-	if err := r.dst.Close(); err != nil {
-		return fmt.Errorf("%w: retrying sink could not close underlying sink", err)
+		return ShutdownReport{}, nil
 	}
 
 	r.closed = true
 
 	close(r.closedChan)
+	r.mu.Unlock()
 
-	return nil
+	done := make(chan struct{})
+
+	go func() {
+		r.inflight.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+	case <-ctx.Done():
+	}
+
+	report := ShutdownReport{Abandoned: int(r.abandoned.Load())}
+
+	dst, err := Shutdown(ctx, r.dst)
+	if err != nil {
+		return report.add(dst), fmt.Errorf("%w: retrying sink could not close underlying sink", err)
+	}
+
+	return report.add(dst), nil
 }
 
 func (r *retryer) Closed() <-chan struct{} {
@@ -6,11 +6,13 @@ import (
 	"fmt"
 	"math/rand/v2"
 	"sync"
+	"sync/atomic"
 	"testing"
 	"time"
 
 	"github.com/botchris/go-auditrail"
 	"github.com/brianvoe/gofakeit/v6"
+	"github.com/stretchr/testify/require"
 )
 
 func TestNewRetryerSinkBreaker(t *testing.T) {
@@ -68,6 +70,93 @@ func TestRetryerExponentialBackoffV2(t *testing.T) {
 	}
 }
 
+func TestRetryerShutdown(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	t.Run("GIVEN a retryer backing off WHEN shutting it down THEN the entry is abandoned", func(t *testing.T) {
+		var abandoned atomic.Int64
+
+		failing := &flakyLogger{rate: 1.0, Logger: auditrail.NewMemoryLogger()}
+		retryer := auditrail.NewRetryer(failing,
+			auditrail.WithRetryStrategy(auditrail.NewBreakerStrategy(1, time.Minute)),
+			auditrail.WithRetryDropHandler(func(_ *auditrail.Entry, err error) {
+				require.ErrorIs(t, err, auditrail.ErrTrailClosed)
+				abandoned.Add(1)
+			}),
+		)
+
+		done := make(chan error)
+		go func() { done <- retryer.Log(ctx, newFakeEntry()) }()
+
+		time.Sleep(20 * time.Millisecond) // let the retryer back off.
+
+		report, err := auditrail.Shutdown(ctx, retryer)
+		require.NoError(t, err)
+		require.Equal(t, auditrail.ShutdownReport{Abandoned: 1}, report)
+		require.ErrorIs(t, <-done, auditrail.ErrTrailClosed)
+		require.EqualValues(t, 1, abandoned.Load())
+		require.True(t, retryer.IsClosed())
+	})
+
+	t.Run("GIVEN a retryer of a failing destination WHEN the context of a write is done THEN the context error is returned AND the entry is not abandoned", func(t *testing.T) {
+		var dropped atomic.Int64
+
+		retryer := auditrail.NewRetryer(&dropper{err: fmt.Errorf("%w: unavailable", auditrail.ErrRetryable)},
+			auditrail.WithRetryStrategy(auditrail.NewBreakerStrategy(1, time.Minute)),
+			auditrail.WithRetryDropHandler(func(*auditrail.Entry, error) { dropped.Add(1) }),
+		)
+
+		deadline, cancelDeadline := context.WithTimeout(ctx, 20*time.Millisecond)
+		defer cancelDeadline()
+
+		err := retryer.Log(deadline, newFakeEntry())
+		require.ErrorIs(t, err, context.DeadlineExceeded)
+		require.ErrorIs(t, err, auditrail.ErrRetryable)
+		require.NotErrorIs(t, err, auditrail.ErrTrailClosed)
+
+		report, err := auditrail.Shutdown(ctx, retryer)
+		require.NoError(t, err)
+		require.Zero(t, report.Abandoned)
+		require.Zero(t, dropped.Load())
+	})
+
+	t.Run("GIVEN a queue writing to a retryer of a failing destination WHEN the shutdown deadline is reached THEN retries stop AND the entry is dropped once", func(t *testing.T) {
+		var retryerDropped, queueDropped atomic.Int64
+
+		retryer := auditrail.NewRetryer(&dropper{err: fmt.Errorf("%w: unavailable", auditrail.ErrRetryable)},
+			auditrail.WithRetryStrategy(auditrail.NewBreakerStrategy(1, time.Minute)),
+			auditrail.WithRetryDropHandler(func(*auditrail.Entry, error) { retryerDropped.Add(1) }),
+		)
+		queue := auditrail.NewQueue(retryer,
+			auditrail.WithQueueTimeout(time.Minute),
+			auditrail.WithQueueDropHandler(func(_ *auditrail.Entry, err error) {
+				require.ErrorIs(t, err, context.DeadlineExceeded)
+				queueDropped.Add(1)
+			}),
+		)
+
+		require.NoError(t, queue.Log(ctx, newFakeEntry()))
+
+		deadline, cancelDeadline := context.WithTimeout(ctx, 20*time.Millisecond)
+		defer cancelDeadline()
+
+		report, err := auditrail.Shutdown(deadline, queue)
+		require.ErrorIs(t, err, context.DeadlineExceeded)
+		require.Equal(t, auditrail.ShutdownReport{Abandoned: 1}, report)
+
+		select {
+		case <-queue.Closed():
+		case <-ctx.Done():
+			t.Fatal("the retryer kept retrying after the queue abandoned its entries")
+		}
+
+		require.EqualValues(t, 1, queueDropped.Load())
+		require.Zero(t, retryerDropped.Load())
+		require.True(t, retryer.IsClosed())
+	})
+}
+
 func testRetryerStrategy(t *testing.T, ctx context.Context, strategy auditrail.RetryStrategy) {
 	const nm = 100
 
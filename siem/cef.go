@@ -0,0 +1,92 @@
+package siem
+
+import (
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/botchris/go-auditrail"
+)
+
+var (
+	cefHeaderEscaper    = strings.NewReplacer(`\`, `\\`, `|`, `\|`, "\n", " ", "\r", " ")
+	cefExtensionEscaper = strings.NewReplacer(`\`, `\\`, `=`, `\=`, "\n", `\n`, "\r", `\r`)
+)
+
+// DefaultCEFMapping returns the default mapping of entry fields to ArcSight
+// CEF extension keys.
+func DefaultCEFMapping() Mapping {
+	return Mapping{
+		FieldIdempotencyID:   "externalId",
+		FieldActor:           "suser",
+		FieldModule:          "cat",
+		FieldOccurredAt:      "rt",
+		FieldHTTPMethod:      "requestMethod",
+		FieldHTTPUserAgent:   "requestClientApplication",
+		FieldHTTPPath:        "request",
+		FieldHTTPHost:        "dhost",
+		FieldClientIP:        "src",
+		FieldClientLatitude:  "slat",
+		FieldClientLongitude: "slong",
+	}
+}
+
+type cefEncoder struct {
+	opts options
+}
+
+// NewCEFEncoder returns an encoder that formats entries as ArcSight Common
+// Event Format (CEF) messages:
+//
+//	CEF:0|Vendor|Product|Version|Action|Action|Severity|key=value ...
+//
+// Extension fields are taken from [DefaultCEFMapping] unless a different
+// mapping is given, and the default severity is 3.
+func NewCEFEncoder(opts ...Option) auditrail.Encoder {
+	return cefEncoder{opts: newOptions(DefaultCEFMapping(), 3, opts...)}
+}
+
+func (c cefEncoder) Encode(entry *auditrail.Entry) ([]byte, error) {
+	f, err := entryFields(entry)
+	if err != nil {
+		return nil, err
+	}
+
+	b := strings.Builder{}
+	b.WriteString("CEF:0")
+
+	for _, h := range []string{
+		c.opts.vendor,
+		c.opts.product,
+		c.opts.version,
+		entry.GetAction(),
+		entry.GetAction(),
+		strconv.Itoa(c.opts.severity(entry)),
+	} {
+		b.WriteByte('|')
+		b.WriteString(cefHeaderEscaper.Replace(h))
+	}
+
+	b.WriteByte('|')
+
+	ext := f.mapped(entry, c.opts.mapping)
+	keys := make([]string, 0, len(ext))
+
+	for k := range ext {
+		keys = append(keys, k)
+	}
+
+	sort.Strings(keys)
+
+	for i, k := range keys {
+		if i > 0 {
+			b.WriteByte(' ')
+		}
+
+		b.WriteString(k)
+		b.WriteByte('=')
+		b.WriteString(cefExtensionEscaper.Replace(stringify(ext[k])))
+	}
+
+	return []byte(b.String()), nil
+}
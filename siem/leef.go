@@ -0,0 +1,90 @@
+package siem
+
+import (
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/botchris/go-auditrail"
+)
+
+var (
+	leefHeaderEscaper    = strings.NewReplacer(`\`, `\\`, `|`, `\|`, "\n", " ", "\r", " ")
+	leefAttributeEscaper = strings.NewReplacer("\t", " ", "\n", " ", "\r", " ")
+)
+
+// DefaultLEEFMapping returns the default mapping of entry fields to IBM LEEF
+// attributes.
+func DefaultLEEFMapping() Mapping {
+	return Mapping{
+		FieldIdempotencyID:  "identSrc",
+		FieldActor:          "usrName",
+		FieldModule:         "cat",
+		FieldOccurredAt:     "devTime",
+		FieldHTTPMethod:     "method",
+		FieldHTTPUserAgent:  "userAgent",
+		FieldHTTPPath:       "url",
+		FieldHTTPStatusCode: "httpStatus",
+		FieldClientIP:       "src",
+	}
+}
+
+type leefEncoder struct {
+	opts options
+}
+
+// NewLEEFEncoder returns an encoder that formats entries as IBM Log Event
+// Extended Format (LEEF) 1.0 messages, with tab separated attributes:
+//
+//	LEEF:1.0|Vendor|Product|Version|Action|key=value<TAB>...
+//
+// Attributes are taken from [DefaultLEEFMapping] unless a different mapping is
+// given, the default severity (sev attribute) is 3 and devTime is expressed in
+// milliseconds since epoch.
+func NewLEEFEncoder(opts ...Option) auditrail.Encoder {
+	return leefEncoder{opts: newOptions(DefaultLEEFMapping(), 3, opts...)}
+}
+
+func (l leefEncoder) Encode(entry *auditrail.Entry) ([]byte, error) {
+	f, err := entryFields(entry)
+	if err != nil {
+		return nil, err
+	}
+
+	b := strings.Builder{}
+	b.WriteString("LEEF:1.0")
+
+	for _, h := range []string{
+		l.opts.vendor,
+		l.opts.product,
+		l.opts.version,
+		entry.GetAction(),
+	} {
+		b.WriteByte('|')
+		b.WriteString(leefHeaderEscaper.Replace(h))
+	}
+
+	b.WriteByte('|')
+
+	attrs := f.mapped(entry, l.opts.mapping)
+	attrs["sev"] = strconv.Itoa(l.opts.severity(entry))
+
+	keys := make([]string, 0, len(attrs))
+	for k := range attrs {
+		keys = append(keys, k)
+	}
+
+	sort.Strings(keys)
+
+	for i, k := range keys {
+		if i > 0 {
+			b.WriteByte('\t')
+		}
+
+		b.WriteString(k)
+		b.WriteByte('=')
+		b.WriteString(leefAttributeEscaper.Replace(stringify(attrs[k])))
+	}
+
+	return []byte(b.String()), nil
+}
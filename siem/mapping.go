@@ -0,0 +1,182 @@
+package siem
+
+import (
+	"encoding/json"
+	"strconv"
+	"strings"
+
+	"github.com/botchris/go-auditrail"
+)
+
+// Source fields of an entry, expressed as dotted paths into its JSON
+// representation. Details attached by the [httpd.Decorator] and
+// [networkd.Decorator] live under the "http" and "client" details.
+const (
+	FieldIdempotencyID   = "idempotency_id"
+	FieldActor           = "actor"
+	FieldAction          = "action"
+	FieldModule          = "module"
+	FieldCorrelationID   = "correlation_id"
+	FieldCausationID     = "causation_id"
+	FieldAuthMethod      = "auth_method"
+	FieldOccurredAt      = "occurred_at"
+	FieldHTTPMethod      = "details.http.method"
+	FieldHTTPStatusCode  = "details.http.status_code"
+	FieldHTTPUserAgent   = "details.http.user_agent"
+	FieldHTTPHost        = "details.http.url.host"
+	FieldHTTPPath        = "details.http.url.path"
+	FieldClientIP        = "details.client.client.ip"
+	FieldClientCountry   = "details.client.client.geoip.country.code"
+	FieldClientCity      = "details.client.client.geoip.city.name"
+	FieldClientLatitude  = "details.client.client.geoip.location.latitude"
+	FieldClientLongitude = "details.client.client.geoip.location.longitude"
+)
+
+// Mapping maps source fields of an entry to target fields of an output
+// format. Source fields are dotted paths into the JSON representation of the
+// entry, such as [FieldClientIP]; target fields are format specific keys, such
+// as "src" for CEF or "src_endpoint.ip" for OCSF.
+type Mapping map[string]string
+
+// Option is a function that configures a SIEM encoder.
+type Option func(options *options)
+
+type options struct {
+	vendor   string
+	product  string
+	version  string
+	mapping  Mapping
+	severity func(*auditrail.Entry) int
+}
+
+func newOptions(mapping Mapping, severity int, opts ...Option) options {
+	o := options{
+		vendor:  "auditrail",
+		product: "auditrail",
+		version: "1.0",
+		mapping: mapping,
+		severity: func(*auditrail.Entry) int {
+			return severity
+		},
+	}
+
+	for _, opt := range opts {
+		opt(&o)
+	}
+
+	return o
+}
+
+// WithProduct sets the vendor, product and version reported in the output.
+func WithProduct(vendor, product, version string) Option {
+	return func(options *options) {
+		options.vendor = vendor
+		options.product = product
+		options.version = version
+	}
+}
+
+// WithMapping replaces the default field mapping of the encoder.
+func WithMapping(mapping Mapping) Option {
+	return func(options *options) {
+		options.mapping = mapping
+	}
+}
+
+// WithField adds (or overrides) a single field to the mapping of the encoder.
+func WithField(source, target string) Option {
+	return func(options *options) {
+		m := make(Mapping, len(options.mapping)+1)
+		for k, v := range options.mapping {
+			m[k] = v
+		}
+
+		m[source] = target
+		options.mapping = m
+	}
+}
+
+// WithSeverity sets a function computing the severity of each entry. The
+// scale depends on the output format: 0-10 for CEF, 1-10 for LEEF and 0-6
+// (severity_id) for OCSF.
+func WithSeverity(fn func(*auditrail.Entry) int) Option {
+	return func(options *options) {
+		if fn != nil {
+			options.severity = fn
+		}
+	}
+}
+
+// fields is the generic JSON representation of an entry.
+type fields map[string]interface{}
+
+// entryFields returns the generic JSON representation of the given entry.
+func entryFields(entry *auditrail.Entry) (fields, error) {
+	raw, err := json.Marshal(entry)
+	if err != nil {
+		return nil, err
+	}
+
+	out := make(fields)
+	if err = json.Unmarshal(raw, &out); err != nil {
+		return nil, err
+	}
+
+	return out, nil
+}
+
+// lookup returns the value at the given dotted path, and whether it exists.
+func (f fields) lookup(path string) (interface{}, bool) {
+	var current interface{} = map[string]interface{}(f)
+
+	for _, part := range strings.Split(path, ".") {
+		m, ok := current.(map[string]interface{})
+		if !ok {
+			return nil, false
+		}
+
+		if current, ok = m[part]; !ok || current == nil {
+			return nil, false
+		}
+	}
+
+	return current, true
+}
+
+// mapped returns the values of the mapped fields indexed by target field,
+// skipping source fields that are absent or empty.
+func (f fields) mapped(entry *auditrail.Entry, mapping Mapping) map[string]interface{} {
+	out := make(map[string]interface{}, len(mapping))
+
+	for source, target := range mapping {
+		if source == FieldOccurredAt {
+			out[target] = entry.GetOccurredAt().UnixMilli()
+
+			continue
+		}
+
+		if v, ok := f.lookup(source); ok && v != "" {
+			out[target] = v
+		}
+	}
+
+	return out
+}
+
+// stringify renders a generic JSON value as a flat string.
+func stringify(v interface{}) string {
+	switch value := v.(type) {
+	case string:
+		return value
+	case float64:
+		return strconv.FormatFloat(value, 'f', -1, 64)
+	case int64:
+		return strconv.FormatInt(value, 10)
+	case bool:
+		return strconv.FormatBool(value)
+	default:
+		b, _ := json.Marshal(value)
+
+		return string(b)
+	}
+}
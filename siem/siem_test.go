@@ -0,0 +1,159 @@
+package siem_test
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/botchris/go-auditrail"
+	"github.com/botchris/go-auditrail/httpd"
+	"github.com/botchris/go-auditrail/networkd"
+	"github.com/botchris/go-auditrail/siem"
+	"github.com/stretchr/testify/require"
+)
+
+func TestEncoders(t *testing.T) {
+	occurredAt := time.Date(2026, 10, 17, 10, 30, 0, 0, time.UTC)
+	entry := auditrail.NewEntry("john=doe", "order_create", "orders").
+		WithIdempotency("b7c4d7e2").
+		WithCorrelation("c0ffee").
+		WithOccurredAt(occurredAt).
+		AppendDetails("http", httpd.Details{
+			Method:    "POST",
+			UserAgent: "curl/8.0",
+			URL:       httpd.URL{Host: "api.example.com", Path: "/orders"},
+		}).
+		AppendDetails("client", networkd.Details{
+			Client: networkd.Client{
+				IP: "203.0.113.7",
+				GeoIP: &networkd.GeoIP{
+					Country:  networkd.Country{Code: "ES"},
+					City:     networkd.City{Name: "Madrid"},
+					Location: networkd.Location{Latitude: 40.4, Longitude: -3.7},
+				},
+			},
+		})
+
+	t.Run("GIVEN an entry WHEN encoding as CEF THEN header and mapped extensions are produced", func(t *testing.T) {
+		b, err := siem.NewCEFEncoder(siem.WithProduct("Acme", "Shop|Audit", "2.1")).Encode(entry)
+		require.NoError(t, err)
+
+		out := string(b)
+		require.True(t, strings.HasPrefix(out, `CEF:0|Acme|Shop\|Audit|2.1|order_create|order_create|3|`), out)
+		require.Contains(t, out, `suser=john\=doe`)
+		require.Contains(t, out, "src=203.0.113.7")
+		require.Contains(t, out, "requestMethod=POST")
+		require.Contains(t, out, "request=/orders")
+		require.Contains(t, out, "externalId=b7c4d7e2")
+		require.Contains(t, out, "slat=40.4")
+		require.Contains(t, out, "rt=1792233000000")
+	})
+
+	t.Run("GIVEN a custom mapping WHEN encoding as CEF THEN only mapped fields are produced", func(t *testing.T) {
+		enc := siem.NewCEFEncoder(
+			siem.WithMapping(siem.Mapping{siem.FieldActor: "duser"}),
+			siem.WithField(siem.FieldCorrelationID, "cs1"),
+			siem.WithSeverity(func(*auditrail.Entry) int { return 8 }),
+		)
+
+		b, err := enc.Encode(entry)
+		require.NoError(t, err)
+		require.Equal(t, `CEF:0|auditrail|auditrail|1.0|order_create|order_create|8|cs1=c0ffee duser=john\=doe`, string(b))
+	})
+
+	t.Run("GIVEN an entry WHEN encoding as LEEF THEN tab separated attributes are produced", func(t *testing.T) {
+		b, err := siem.NewLEEFEncoder().Encode(entry)
+		require.NoError(t, err)
+
+		parts := strings.SplitN(string(b), "|", 6)
+		require.Len(t, parts, 6)
+		require.Equal(t, []string{"LEEF:1.0", "auditrail", "auditrail", "1.0", "order_create"}, parts[:5])
+
+		attrs := make(map[string]string)
+		for _, kv := range strings.Split(parts[5], "\t") {
+			k, v, ok := strings.Cut(kv, "=")
+			require.True(t, ok, kv)
+			attrs[k] = v
+		}
+
+		require.Equal(t, "john=doe", attrs["usrName"])
+		require.Equal(t, "203.0.113.7", attrs["src"])
+		require.Equal(t, "orders", attrs["cat"])
+		require.Equal(t, "1792233000000", attrs["devTime"])
+		require.Equal(t, "3", attrs["sev"])
+	})
+
+	t.Run("GIVEN an entry WHEN encoding as OCSF THEN an API activity event is produced", func(t *testing.T) {
+		b, err := siem.NewOCSFEncoder().Encode(entry)
+		require.NoError(t, err)
+
+		var event struct {
+			ClassUID   int   `json:"class_uid"`
+			TypeUID    int   `json:"type_uid"`
+			SeverityID int   `json:"severity_id"`
+			Time       int64 `json:"time"`
+			Metadata   struct {
+				UID            string `json:"uid"`
+				CorrelationUID string `json:"correlation_uid"`
+			} `json:"metadata"`
+			Actor struct {
+				User struct {
+					Name string `json:"name"`
+				} `json:"user"`
+			} `json:"actor"`
+			API struct {
+				Operation string `json:"operation"`
+			} `json:"api"`
+			SrcEndpoint struct {
+				IP       string `json:"ip"`
+				Location struct {
+					Country string  `json:"country"`
+					Lat     float64 `json:"lat"`
+				} `json:"location"`
+			} `json:"src_endpoint"`
+			HTTPRequest struct {
+				HTTPMethod string `json:"http_method"`
+				URL        struct {
+					Hostname string `json:"hostname"`
+				} `json:"url"`
+			} `json:"http_request"`
+		}
+
+		require.NoError(t, json.Unmarshal(b, &event))
+		require.Equal(t, 6003, event.ClassUID)
+		require.Equal(t, 600399, event.TypeUID)
+		require.Equal(t, 1, event.SeverityID)
+		require.Equal(t, occurredAt.UnixMilli(), event.Time)
+		require.Equal(t, "b7c4d7e2", event.Metadata.UID)
+		require.Equal(t, "c0ffee", event.Metadata.CorrelationUID)
+		require.Equal(t, "john=doe", event.Actor.User.Name)
+		require.Equal(t, "order_create", event.API.Operation)
+		require.Equal(t, "203.0.113.7", event.SrcEndpoint.IP)
+		require.Equal(t, "ES", event.SrcEndpoint.Location.Country)
+		require.Equal(t, 40.4, event.SrcEndpoint.Location.Lat)
+		require.Equal(t, "POST", event.HTTPRequest.HTTPMethod)
+		require.Equal(t, "api.example.com", event.HTTPRequest.URL.Hostname)
+	})
+
+	t.Run("GIVEN a file logger with a CEF encoder WHEN logging THEN one CEF line is written per entry", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "audit.cef")
+
+		logger, err := auditrail.NewFilePathLogger(path, auditrail.WithFileEncoder(siem.NewCEFEncoder()))
+		require.NoError(t, err)
+
+		require.NoError(t, logger.Log(context.Background(), entry))
+		require.NoError(t, logger.Log(context.Background(), entry))
+		require.NoError(t, logger.Close())
+
+		raw, err := os.ReadFile(path)
+		require.NoError(t, err)
+
+		lines := strings.Split(strings.TrimSpace(string(raw)), "\n")
+		require.Len(t, lines, 2)
+		require.True(t, strings.HasPrefix(lines[0], "CEF:0|"))
+	})
+}
@@ -0,0 +1,436 @@
+package auditrail
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sync"
+)
+
+// signatureDetailsKey is the detail key under which signatures are attached.
+const signatureDetailsKey = "signature"
+
+var (
+	// ErrMissingSignature is returned when verifying an entry that was not
+	// signed.
+	ErrMissingSignature = errors.New("entry is not signed")
+
+	// ErrInvalidSignature is returned when the signature of an entry does not
+	// match its content.
+	ErrInvalidSignature = errors.New("invalid entry signature")
+
+	// ErrUnknownKey is returned when a signing key cannot be found.
+	ErrUnknownKey = errors.New("unknown signing key")
+
+	// ErrRevokedKey is returned when an entry was signed with a key that has
+	// been revoked.
+	ErrRevokedKey = errors.New("revoked signing key")
+)
+
+// SignatureAlgorithm identifies the algorithm used to sign an entry.
+type SignatureAlgorithm string
+
+const (
+	// SignatureEd25519 signs entries using Ed25519.
+	SignatureEd25519 SignatureAlgorithm = "Ed25519"
+
+	// SignatureES256 signs entries using ECDSA over the P-256 curve and
+	// SHA-256, with ASN.1 encoded signatures.
+	SignatureES256 SignatureAlgorithm = "ES256"
+)
+
+// Signature is the signature attached to every entry that passes through a
+// signing logger. It is stored under the "signature" detail of the entry.
+type Signature struct {
+	// KeyID identifies the key that produced the signature.
+	KeyID string `json:"key_id"`
+
+	// Algorithm is the algorithm used to produce the signature.
+	Algorithm SignatureAlgorithm `json:"algorithm"`
+
+	// Value is the base64 encoded signature.
+	Value string `json:"value,omitempty"`
+}
+
+// Signer signs messages with a private key.
+//
+// All methods should be goroutine safe.
+type Signer interface {
+	// KeyID returns the identifier of the signing key.
+	KeyID() string
+
+	// Algorithm returns the signature algorithm.
+	Algorithm() SignatureAlgorithm
+
+	// Public returns the public key that verifies the produced signatures.
+	Public() crypto.PublicKey
+
+	// Sign signs the given message.
+	Sign(message []byte) ([]byte, error)
+}
+
+// PublicKey is a key that can be used to verify signatures.
+type PublicKey struct {
+	// ID identifies the key.
+	ID string
+
+	// Algorithm is the algorithm the key is used with.
+	Algorithm SignatureAlgorithm
+
+	// Key is either an ed25519.PublicKey or an *ecdsa.PublicKey.
+	Key crypto.PublicKey
+
+	// Revoked indicates that the key must no longer be trusted.
+	Revoked bool
+}
+
+// KeyProvider gives access to signing and verification keys, allowing keys to
+// be rotated while older keys remain available for verification.
+//
+// All methods should be goroutine safe.
+type KeyProvider interface {
+	// Signer returns the signer of the currently active key.
+	Signer() (Signer, error)
+
+	// PublicKey returns the verification key with the given ID, or an error
+	// wrapping ErrUnknownKey if there is none.
+	PublicKey(keyID string) (PublicKey, error)
+}
+
+type signingLogger struct {
+	dst  Logger
+	keys KeyProvider
+}
+
+// NewSigningLogger builds a new logger that signs every entry with the active
+// key of the given provider before writing it to the given logger.
+//
+// The [Signature], including the ID of the key that produced it, is attached
+// to the entry. Use a [Verifier] to validate entries read back from a sink.
+func NewSigningLogger(dst Logger, keys KeyProvider) Logger {
+	return &signingLogger{
+		dst:  dst,
+		keys: keys,
+	}
+}
+
+// Log signs the given entry and writes it to the underlying logger.
+func (s *signingLogger) Log(ctx context.Context, entry *Entry) error {
+	if entry == nil {
+		return s.dst.Log(ctx, entry)
+	}
+
+	signer, err := s.keys.Signer()
+	if err != nil {
+		return fmt.Errorf("%w: could not get signing key", err)
+	}
+
+	sig := Signature{
+		KeyID:     signer.KeyID(),
+		Algorithm: signer.Algorithm(),
+	}
+
+	message, err := entry.canonicalWith(signatureDetailsKey, sig)
+	if err != nil {
+		return fmt.Errorf("%w: could not encode entry", err)
+	}
+
+	value, err := signer.Sign(message)
+	if err != nil {
+		return fmt.Errorf("%w: could not sign entry", err)
+	}
+
+	sig.Value = base64.StdEncoding.EncodeToString(value)
+	entry.AppendDetails(signatureDetailsKey, sig)
+
+	return s.dst.Log(ctx, entry)
+}
+
+func (s *signingLogger) Close() error {
+	return s.dst.Close()
+}
+
+// Shutdown shuts down the destination logger within ctx.
+func (s *signingLogger) Shutdown(ctx context.Context) (ShutdownReport, error) {
+	return Shutdown(ctx, s.dst)
+}
+
+func (s *signingLogger) Closed() <-chan struct{} {
+	return s.dst.Closed()
+}
+
+func (s *signingLogger) IsClosed() bool {
+	return s.dst.IsClosed()
+}
+
+// Verifier validates the signatures of entries produced by a signing logger.
+type Verifier struct {
+	keys KeyProvider
+}
+
+// NewVerifier builds a new verifier that looks up verification keys in the
+// given provider.
+func NewVerifier(keys KeyProvider) *Verifier {
+	return &Verifier{keys: keys}
+}
+
+// Verify checks the signature of the given entry, returning the signature so
+// callers can tell which key signed the entry.
+//
+// The returned error is nil if the signature is valid, or wraps one of
+// ErrMissingSignature, ErrUnknownKey, ErrInvalidSignature or ErrRevokedKey. A
+// revoked key is only reported for otherwise valid signatures.
+func (v *Verifier) Verify(entry *Entry) (Signature, error) {
+	sig, ok := entrySignature(entry)
+	if !ok {
+		return Signature{}, ErrMissingSignature
+	}
+
+	key, err := v.keys.PublicKey(sig.KeyID)
+	if err != nil {
+		return sig, err
+	}
+
+	value, err := base64.StdEncoding.DecodeString(sig.Value)
+	if err != nil {
+		return sig, fmt.Errorf("%w: malformed signature value", ErrInvalidSignature)
+	}
+
+	message, err := entry.canonicalWith(signatureDetailsKey, Signature{KeyID: sig.KeyID, Algorithm: sig.Algorithm})
+	if err != nil {
+		return sig, fmt.Errorf("%w: could not encode entry", err)
+	}
+
+	if err = verifySignature(key, sig.Algorithm, message, value); err != nil {
+		return sig, err
+	}
+
+	if key.Revoked {
+		return sig, fmt.Errorf("%w: %s", ErrRevokedKey, sig.KeyID)
+	}
+
+	return sig, nil
+}
+
+// entrySignature extracts the signature attached to the given entry, either
+// as set by a signing logger or as read back from a sink.
+func entrySignature(entry *Entry) (Signature, bool) {
+	if entry == nil || entry.data == nil {
+		return Signature{}, false
+	}
+
+	switch raw := entry.data.Details[signatureDetailsKey].(type) {
+	case nil:
+		return Signature{}, false
+	case Signature:
+		return raw, true
+	default:
+		sig := Signature{}
+
+		encoded, err := json.Marshal(raw)
+		if err != nil {
+			return Signature{}, false
+		}
+
+		if err = json.Unmarshal(encoded, &sig); err != nil || sig.Value == "" {
+			return Signature{}, false
+		}
+
+		return sig, true
+	}
+}
+
+// verifySignature checks the given signature of message against the key.
+func verifySignature(key PublicKey, alg SignatureAlgorithm, message, signature []byte) error {
+	if key.Algorithm != alg {
+		return fmt.Errorf("%w: key %s does not use %s", ErrInvalidSignature, key.ID, alg)
+	}
+
+	valid := false
+
+	switch pub := key.Key.(type) {
+	case ed25519.PublicKey:
+		valid = alg == SignatureEd25519 && ed25519.Verify(pub, message, signature)
+	case *ecdsa.PublicKey:
+		digest := sha256.Sum256(message)
+		valid = alg == SignatureES256 && ecdsa.VerifyASN1(pub, digest[:], signature)
+	default:
+		return fmt.Errorf("%w: unsupported key type %T", ErrInvalidSignature, key.Key)
+	}
+
+	if !valid {
+		return ErrInvalidSignature
+	}
+
+	return nil
+}
+
+type ed25519Signer struct {
+	id  string
+	key ed25519.PrivateKey
+}
+
+// NewEd25519Signer returns a [Signer] that signs using the given Ed25519
+// private key, identified by the given key ID.
+func NewEd25519Signer(keyID string, key ed25519.PrivateKey) Signer {
+	return &ed25519Signer{
+		id:  keyID,
+		key: key,
+	}
+}
+
+func (s *ed25519Signer) KeyID() string {
+	return s.id
+}
+
+func (s *ed25519Signer) Algorithm() SignatureAlgorithm {
+	return SignatureEd25519
+}
+
+func (s *ed25519Signer) Public() crypto.PublicKey {
+	return s.key.Public()
+}
+
+func (s *ed25519Signer) Sign(message []byte) ([]byte, error) {
+	return ed25519.Sign(s.key, message), nil
+}
+
+type ecdsaSigner struct {
+	id  string
+	key *ecdsa.PrivateKey
+}
+
+// NewECDSASigner returns a [Signer] that signs using the given ECDSA private
+// key, identified by the given key ID. The key must use the P-256 curve.
+func NewECDSASigner(keyID string, key *ecdsa.PrivateKey) (Signer, error) {
+	if key == nil || key.Curve != elliptic.P256() {
+		return nil, fmt.Errorf("ecdsa key must use the P-256 curve")
+	}
+
+	return &ecdsaSigner{
+		id:  keyID,
+		key: key,
+	}, nil
+}
+
+func (s *ecdsaSigner) KeyID() string {
+	return s.id
+}
+
+func (s *ecdsaSigner) Algorithm() SignatureAlgorithm {
+	return SignatureES256
+}
+
+func (s *ecdsaSigner) Public() crypto.PublicKey {
+	return &s.key.PublicKey
+}
+
+func (s *ecdsaSigner) Sign(message []byte) ([]byte, error) {
+	digest := sha256.Sum256(message)
+
+	return ecdsa.SignASN1(rand.Reader, s.key, digest[:])
+}
+
+var _ KeyProvider = (*KeyRing)(nil)
+
+// KeyRing is an in-memory [KeyProvider] holding the active signer and every
+// key that was ever used, so entries signed before a rotation can still be
+// verified.
+//
+// This struct is safe for concurrent use.
+type KeyRing struct {
+	current Signer
+	keys    map[string]PublicKey
+	mu      sync.RWMutex
+}
+
+// NewKeyRing creates a new empty key ring. Use [KeyRing.Rotate] to set the
+// active signer, or [KeyRing.AddPublicKey] to build a verification only ring.
+func NewKeyRing() *KeyRing {
+	return &KeyRing{
+		keys: make(map[string]PublicKey),
+	}
+}
+
+// Rotate makes the given signer the active one. The public key of the previous
+// signer remains available for verification.
+func (k *KeyRing) Rotate(signer Signer) error {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+
+	if existing, ok := k.keys[signer.KeyID()]; ok && existing.Revoked {
+		return fmt.Errorf("%w: %s", ErrRevokedKey, signer.KeyID())
+	}
+
+	k.keys[signer.KeyID()] = PublicKey{
+		ID:        signer.KeyID(),
+		Algorithm: signer.Algorithm(),
+		Key:       signer.Public(),
+	}
+
+	k.current = signer
+
+	return nil
+}
+
+// AddPublicKey registers a verification key.
+func (k *KeyRing) AddPublicKey(key PublicKey) {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+
+	k.keys[key.ID] = key
+}
+
+// Revoke flags the key with the given ID as revoked. If it is the active key,
+// signing is disabled until a new key is rotated in.
+func (k *KeyRing) Revoke(keyID string) error {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+
+	key, ok := k.keys[keyID]
+	if !ok {
+		return fmt.Errorf("%w: %s", ErrUnknownKey, keyID)
+	}
+
+	key.Revoked = true
+	k.keys[keyID] = key
+
+	if k.current != nil && k.current.KeyID() == keyID {
+		k.current = nil
+	}
+
+	return nil
+}
+
+// Signer returns the active signer.
+func (k *KeyRing) Signer() (Signer, error) {
+	k.mu.RLock()
+	defer k.mu.RUnlock()
+
+	if k.current == nil {
+		return nil, fmt.Errorf("%w: no active signing key", ErrUnknownKey)
+	}
+
+	return k.current, nil
+}
+
+// PublicKey returns the verification key with the given ID.
+func (k *KeyRing) PublicKey(keyID string) (PublicKey, error) {
+	k.mu.RLock()
+	defer k.mu.RUnlock()
+
+	key, ok := k.keys[keyID]
+	if !ok {
+		return PublicKey{}, fmt.Errorf("%w: %s", ErrUnknownKey, keyID)
+	}
+
+	return key, nil
+}
@@ -0,0 +1,135 @@
+package auditrail_test
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/botchris/go-auditrail"
+	"github.com/brianvoe/gofakeit/v6"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSigningLogger(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	t.Run("GIVEN a signing file logger with an ed25519 key", func(t *testing.T) {
+		_, edKey, err := ed25519.GenerateKey(rand.Reader)
+		require.NoError(t, err)
+
+		ecKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+		require.NoError(t, err)
+
+		ecSigner, err := auditrail.NewECDSASigner("key-2", ecKey)
+		require.NoError(t, err)
+
+		ring := auditrail.NewKeyRing()
+		require.NoError(t, ring.Rotate(auditrail.NewEd25519Signer("key-1", edKey)))
+
+		path := t.TempDir() + "/audit.log"
+		fl, err := auditrail.NewFilePathLogger(path)
+		require.NoError(t, err)
+
+		logger := auditrail.NewSigningLogger(fl, ring)
+
+		t.Run("WHEN logging entries AND rotating to an ecdsa key halfway", func(t *testing.T) {
+			for i := 0; i < 10; i++ {
+				if i == 5 {
+					require.NoError(t, ring.Rotate(ecSigner))
+				}
+
+				entry := auditrail.NewEntry(gofakeit.Username(), gofakeit.VerbAction(), gofakeit.AppName()).
+					WithCorrelation(gofakeit.UUID()).
+					AppendDetails("amount", gofakeit.Price(1, 1000))
+
+				require.NoError(t, logger.Log(ctx, entry))
+			}
+
+			entries := readEntries(t, path)
+			verifier := auditrail.NewVerifier(ring)
+
+			t.Run("THEN every entry read back is valid AND reports the key that signed it", func(t *testing.T) {
+				require.Len(t, entries, 10)
+
+				for i, entry := range entries {
+					sig, vErr := verifier.Verify(entry)
+					require.NoError(t, vErr)
+
+					if i < 5 {
+						require.Equal(t, "key-1", sig.KeyID)
+						require.Equal(t, auditrail.SignatureEd25519, sig.Algorithm)
+					} else {
+						require.Equal(t, "key-2", sig.KeyID)
+						require.Equal(t, auditrail.SignatureES256, sig.Algorithm)
+					}
+				}
+			})
+
+			t.Run("THEN a modified entry is flagged as invalid", func(t *testing.T) {
+				b, mErr := json.Marshal(entries[0])
+				require.NoError(t, mErr)
+
+				modified := strings.Replace(string(b), entries[0].GetActor(), "mallory", 1)
+				tampered := &auditrail.Entry{}
+				require.NoError(t, json.Unmarshal([]byte(modified), tampered))
+
+				_, vErr := verifier.Verify(tampered)
+				require.ErrorIs(t, vErr, auditrail.ErrInvalidSignature)
+			})
+
+			t.Run("THEN entries signed with a revoked key are flagged", func(t *testing.T) {
+				require.NoError(t, ring.Revoke("key-1"))
+
+				sig, vErr := verifier.Verify(entries[0])
+				require.ErrorIs(t, vErr, auditrail.ErrRevokedKey)
+				require.Equal(t, "key-1", sig.KeyID)
+
+				_, vErr = verifier.Verify(entries[9])
+				require.NoError(t, vErr)
+			})
+
+			t.Run("THEN a verifier missing the key flags it as unknown", func(t *testing.T) {
+				other := auditrail.NewKeyRing()
+				other.AddPublicKey(auditrail.PublicKey{
+					ID:        "key-2",
+					Algorithm: auditrail.SignatureES256,
+					Key:       ecSigner.Public(),
+				})
+
+				_, vErr := auditrail.NewVerifier(other).Verify(entries[0])
+				require.ErrorIs(t, vErr, auditrail.ErrUnknownKey)
+
+				_, vErr = auditrail.NewVerifier(other).Verify(entries[9])
+				require.NoError(t, vErr)
+			})
+		})
+	})
+
+	t.Run("GIVEN an unsigned entry WHEN verifying THEN it is reported as missing a signature", func(t *testing.T) {
+		entry := auditrail.NewEntry(gofakeit.Username(), gofakeit.VerbAction(), gofakeit.AppName())
+
+		_, err := auditrail.NewVerifier(auditrail.NewKeyRing()).Verify(entry)
+		require.ErrorIs(t, err, auditrail.ErrMissingSignature)
+	})
+}
+
+func readEntries(t *testing.T, path string) []*auditrail.Entry {
+	lines := readLines(t, path)
+	out := make([]*auditrail.Entry, 0, len(lines))
+
+	for _, line := range lines {
+		entry := &auditrail.Entry{}
+		require.NoError(t, json.Unmarshal([]byte(line), entry))
+
+		out = append(out, entry)
+	}
+
+	return out
+}
@@ -0,0 +1,755 @@
+package auditrail
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+)
+
+// SpoolSyncPolicy defines when the segment files of a spool are flushed to
+// stable storage.
+type SpoolSyncPolicy int
+
+const (
+	// SpoolSyncAlways flushes every entry before Log returns, so entries
+	// survive power failures once logged.
+	SpoolSyncAlways SpoolSyncPolicy = iota
+
+	// SpoolSyncInterval flushes entries periodically, so entries logged
+	// during the last interval may be lost on power failures.
+	SpoolSyncInterval
+
+	// SpoolSyncNever leaves flushing to the operating system, so entries only
+	// survive crashes of the process.
+	SpoolSyncNever
+)
+
+// SpoolOption is a function that configures a spool.
+type SpoolOption func(options *spoolOptions)
+
+type spoolOptions struct {
+	timeout      time.Duration
+	dropHandling DropHandlerFunc
+	throughput   int
+	batchSize    int
+	segmentSize  int64
+	syncPolicy   SpoolSyncPolicy
+	syncInterval time.Duration
+	retryDelay   time.Duration
+}
+
+var defaultSpoolOptions = spoolOptions{
+	timeout:      3 * time.Second,
+	dropHandling: func(*Entry, error) {},
+	throughput:   1,
+	batchSize:    100,
+	segmentSize:  16 << 20,
+	syncPolicy:   SpoolSyncAlways,
+	syncInterval: time.Second,
+	retryDelay:   time.Second,
+}
+
+// spoolDispatch is an entry read from the spool, along with its segment.
+type spoolDispatch struct {
+	entry   *Entry
+	segment *spoolSegment
+}
+
+var _ ShutdownLogger = (*spool)(nil)
+
+type spool struct {
+	dst          Logger
+	dir          string
+	opts         spoolOptions
+	codec        Codec
+	segments     []*spoolSegment
+	active       *os.File
+	reading      *spoolSegment
+	reader       *os.File
+	nextSeq      uint64
+	retries      []spoolDispatch
+	corrupted    []error
+	flushed      int
+	cond         *sync.Cond
+	stop         context.Context
+	cancel       context.CancelCauseFunc
+	closed       bool
+	closeChannel chan struct{}
+	stopSync     chan struct{}
+	mu           sync.RWMutex
+	wg           sync.WaitGroup
+}
+
+// NewSpool builds a new logger queue which, unlike [NewQueue], persists the
+// buffered entries in a write-ahead log of segment files stored in dir, so
+// they survive crashes and restarts. Entries are durable once Log returns, as
+// configured by [WithSpoolSync], and workers write them to the destination in
+// the background.
+//
+// Segments are deleted once every entry they hold has been written to the
+// destination or rejected with an error wrapping [ErrPermanent], in which
+// case the entry is passed to the drop handler. Entries failing with any
+// other error are written again after the delay set by
+// [WithSpoolRetryDelay], and the ones still pending on close are kept in dir.
+// When the spool is created, the entries left in dir by a previous spool are
+// written to the destination again, so entries are delivered at least once:
+// the ones already handled before a crash may be delivered twice, and should
+// be de-duplicated using their idempotency ID.
+//
+// Records carry a CRC-32C checksum. Records that cannot be read back, e.g.
+// because a crash tore the last write, are reported to the drop handler with
+// an error wrapping [ErrSpoolCorrupted] and a nil entry.
+//
+// The directory is created if needed, and must not be shared with other
+// spools.
+func NewSpool(dst Logger, dir string, options ...SpoolOption) (Logger, error) {
+	opts := defaultSpoolOptions
+
+	for _, option := range options {
+		option(&opts)
+	}
+
+	if err := os.MkdirAll(dir, 0o750); err != nil {
+		return nil, fmt.Errorf("%w: could not create spool directory %s", err, dir)
+	}
+
+	segments, err := spoolSegments(dir)
+	if err != nil {
+		return nil, fmt.Errorf("%w: could not read spool directory %s", err, dir)
+	}
+
+	s := &spool{
+		dst:          dst,
+		dir:          dir,
+		opts:         opts,
+		codec:        NewJSONCodec(),
+		segments:     segments,
+		nextSeq:      1,
+		closeChannel: make(chan struct{}),
+		stopSync:     make(chan struct{}),
+	}
+
+	if n := len(segments); n > 0 {
+		s.nextSeq = segments[n-1].seq + 1
+	}
+
+	s.cond = sync.NewCond(&s.mu)
+	s.stop, s.cancel = context.WithCancelCause(context.Background())
+
+	if err = s.roll(); err != nil {
+		return nil, err
+	}
+
+	// segments left empty by a crash are no longer needed.
+	for _, segment := range segments {
+		s.collect(segment)
+	}
+
+	s.wg.Add(s.opts.throughput)
+
+	for i := 0; i < s.opts.throughput; i++ {
+		go s.run()
+	}
+
+	if s.opts.syncPolicy == SpoolSyncInterval {
+		go s.syncEvery(s.opts.syncInterval)
+	}
+
+	return s, nil
+}
+
+// Log appends the given entry to the spool for asynchronous processing.
+func (s *spool) Log(_ context.Context, entry *Entry) error {
+	if s.IsClosed() {
+		return fmt.Errorf("%w: spool is closed", ErrTrailClosed)
+	}
+
+	payload, err := s.codec.Encode(entry)
+	if err != nil {
+		return fmt.Errorf("%w: %w: could not encode entry", ErrPermanent, err)
+	}
+
+	record := spoolRecord(payload)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.closed {
+		return fmt.Errorf("%w: spool is closed", ErrTrailClosed)
+	}
+
+	segment := s.segments[len(s.segments)-1]
+
+	if segment.size > 0 && segment.size+int64(len(record)) > s.opts.segmentSize {
+		if err = s.roll(); err != nil {
+			return err
+		}
+
+		segment = s.segments[len(s.segments)-1]
+	}
+
+	if _, err = s.active.Write(record); err != nil {
+		// drop the partial record, so the next ones are framed correctly.
+		_ = s.discard(segment.size)
+
+		return fmt.Errorf("%w: %w: could not write entry to spool", ErrRetryable, err)
+	}
+
+	if s.opts.syncPolicy == SpoolSyncAlways {
+		if err = s.active.Sync(); err != nil {
+			// drop the record, so retrying the entry does not duplicate it.
+			if s.discard(segment.size) == nil {
+				return fmt.Errorf("%w: %w: could not sync spool", ErrRetryable, err)
+			}
+
+			err = fmt.Errorf("%w: %w: entry was spooled but could not be synced", ErrPermanent, err)
+		}
+	}
+
+	segment.size += int64(len(record))
+	s.cond.Signal()
+
+	return err
+}
+
+// discard truncates the active segment to the given size, dropping the
+// records written past it. Must be called with the lock held.
+func (s *spool) discard(size int64) error {
+	if err := s.active.Truncate(size); err != nil {
+		return err
+	}
+
+	_, err := s.active.Seek(size, 0)
+
+	return err
+}
+
+// Close shutdown the spool, waiting for workers to write every entry to the
+// destination. Entries failing to be written are kept for the next spool.
+func (s *spool) Close() error {
+	_, err := s.Shutdown(context.Background())
+
+	return err
+}
+
+// Shutdown shutdowns the spool, letting workers write spooled entries until
+// ctx is done. Then, the writes in progress are cancelled and the entries not
+// written yet are counted as abandoned, although they are kept in the segment
+// files and resumed by the next spool rather than passed to the drop handler.
+// The destination logger is shut down within ctx once workers finish.
+func (s *spool) Shutdown(ctx context.Context) (ShutdownReport, error) {
+	s.mu.Lock()
+	if s.closed {
+		s.mu.Unlock()
+
+		return ShutdownReport{}, nil
+	}
+
+	s.closed = true
+	flushed := s.flushed
+
+	close(s.stopSync)
+	s.cond.Broadcast()
+	s.mu.Unlock()
+
+	drained := make(chan struct{})
+
+	go func() {
+		s.wg.Wait()
+		close(drained)
+	}()
+
+	select {
+	case <-drained:
+	case <-ctx.Done():
+		return s.abandon(ctx.Err(), flushed)
+	}
+
+	defer close(s.closeChannel)
+
+	err := s.release()
+
+	s.mu.RLock()
+	report := ShutdownReport{Flushed: s.flushed - flushed}
+	s.mu.RUnlock()
+
+	dst, dErr := Shutdown(ctx, s.dst)
+
+	return report.add(dst), errors.Join(err, dErr)
+}
+
+// abandon cancels the writes in progress because of the given cause, counting
+// the entries left in the segments. The segments and the destination logger
+// are closed in the background once workers finish.
+func (s *spool) abandon(cause error, flushed int) (ShutdownReport, error) {
+	s.cancel(cause)
+
+	s.mu.Lock()
+	report := ShutdownReport{Flushed: s.flushed - flushed, Abandoned: s.pending()}
+	s.mu.Unlock()
+
+	go func() {
+		s.wg.Wait()
+		_ = s.release()
+		_ = s.dst.Close()
+		close(s.closeChannel)
+	}()
+
+	return report, fmt.Errorf("%w: spool could not be drained before the deadline, entries are kept in %s", cause, s.dir)
+}
+
+// release seals the active segment and deletes the drained ones once workers
+// finished.
+func (s *spool) release() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	err := s.seal()
+
+	for _, segment := range append([]*spoolSegment(nil), s.segments...) {
+		s.collect(segment)
+	}
+
+	if s.reader != nil {
+		_ = s.reader.Close()
+		s.reader, s.reading = nil, nil
+	}
+
+	return err
+}
+
+// pending returns the number of entries not written yet: the ones being
+// written or waiting to be retried, and the ones not read yet. Must be called
+// with the lock held.
+func (s *spool) pending() int {
+	n := 0
+
+	for _, segment := range s.segments {
+		n += segment.dispatched - segment.acked
+
+		if segment.offset < segment.size {
+			n += countSpoolRecords(segment.path, segment.offset, segment.size)
+		}
+	}
+
+	return n
+}
+
+func (s *spool) Closed() <-chan struct{} {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	return s.closeChannel
+}
+
+// IsClosed returns true if the spool is closed.
+func (s *spool) IsClosed() bool {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	return s.closed
+}
+
+// run is the main goroutine to flush entries to the target logger.
+func (s *spool) run() {
+	defer s.wg.Done()
+
+	size := 1
+
+	if _, ok := s.dst.(BatchLogger); ok {
+		size = s.opts.batchSize
+	}
+
+	for {
+		dispatches, corrupted, closed := s.next(size)
+
+		for _, err := range corrupted {
+			s.opts.dropHandling(nil, err)
+		}
+
+		if closed {
+			return // spool is closed and drained.
+		}
+
+		if len(dispatches) == 0 {
+			continue
+		}
+
+		entries := make([]*Entry, len(dispatches))
+		for i, d := range dispatches {
+			entries[i] = d.entry
+		}
+
+		ctx, cancel := context.WithTimeout(s.stop, s.opts.timeout)
+		errs := logBatch(ctx, s.dst, entries)
+
+		cancel()
+
+		handled := make([]spoolDispatch, 0, len(dispatches))
+		dropped := 0
+
+		var failed []spoolDispatch
+
+		for i, d := range dispatches {
+			switch {
+			case errs == nil || errs[i] == nil:
+				handled = append(handled, d)
+			case errors.Is(errs[i], ErrPermanent):
+				s.opts.dropHandling(entries[i], errs[i])
+				handled = append(handled, d)
+				dropped++
+			default:
+				failed = append(failed, d)
+			}
+		}
+
+		s.ack(handled, len(dispatches)-len(failed)-dropped)
+		s.retry(failed)
+	}
+}
+
+// retry dispatches the given entries again after the retry delay. Once the
+// spool is closed, they are left unacknowledged instead, so their segments
+// are kept and they are resumed by the next spool.
+func (s *spool) retry(dispatches []spoolDispatch) {
+	if len(dispatches) == 0 {
+		return
+	}
+
+	timer := time.NewTimer(s.opts.retryDelay)
+	defer timer.Stop()
+
+	select {
+	case <-s.stopSync:
+		return
+	case <-timer.C:
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.closed {
+		return
+	}
+
+	s.retries = append(s.retries, dispatches...)
+	s.cond.Broadcast()
+}
+
+// next blocks until there are entries to write, returning up to size of
+// them along with the errors of the records that could not be read. When
+// closed and drained, it returns true.
+func (s *spool) next(size int) ([]spoolDispatch, []error, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for {
+		if s.stop.Err() != nil {
+			// shut down past its deadline, the rest is kept for the next spool.
+			return nil, nil, true
+		}
+
+		dispatches := s.read(size)
+		corrupted := s.corrupted
+		s.corrupted = nil
+
+		if len(dispatches) > 0 || len(corrupted) > 0 {
+			return dispatches, corrupted, false
+		}
+
+		if s.closed {
+			s.cond.Broadcast()
+
+			return nil, nil, true
+		}
+
+		s.cond.Wait()
+	}
+}
+
+// read reads up to size entries, starting with the ones to retry, from the
+// segments. Must be called with the lock held.
+func (s *spool) read(size int) []spoolDispatch {
+	n := min(size, len(s.retries))
+	dispatches := append([]spoolDispatch(nil), s.retries[:n]...)
+	s.retries = s.retries[n:]
+
+	for len(dispatches) < size {
+		segment := s.readable()
+		if segment == nil {
+			break
+		}
+
+		payload, next, err := readSpoolRecord(s.reader, segment.offset, segment.size)
+		segment.offset = next
+
+		if err == nil {
+			var entry *Entry
+
+			if entry, err = s.codec.Decode(payload); err == nil {
+				segment.dispatched++
+				dispatches = append(dispatches, spoolDispatch{entry: entry, segment: segment})
+
+				continue
+			}
+
+			err = fmt.Errorf("%w: %w: could not decode record of %s", ErrSpoolCorrupted, err, segment.path)
+		}
+
+		s.corrupted = append(s.corrupted, fmt.Errorf("%w: %s", err, segment.path))
+		s.collect(segment)
+	}
+
+	return dispatches
+}
+
+// readable returns the segment holding the next record to read, opening it
+// if needed, or nil if every record was read. Must be called with the lock
+// held.
+func (s *spool) readable() *spoolSegment {
+	for _, segment := range s.segments {
+		if segment.offset >= segment.size {
+			if segment.sealed {
+				continue
+			}
+
+			return nil
+		}
+
+		if s.reading != segment {
+			if s.reader != nil {
+				_ = s.reader.Close()
+			}
+
+			reader, err := os.Open(segment.path)
+			if err != nil {
+				s.corrupted = append(s.corrupted, fmt.Errorf("%w: %w: could not open %s", ErrSpoolCorrupted, err, segment.path))
+				segment.offset = segment.size
+				s.reader, s.reading = nil, nil
+
+				continue
+			}
+
+			s.reader, s.reading = reader, segment
+		}
+
+		return segment
+	}
+
+	return nil
+}
+
+// ack acknowledges the given entries, of which the given number were
+// written, deleting the segments that are no longer needed.
+func (s *spool) ack(dispatches []spoolDispatch, written int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.flushed += written
+
+	for _, d := range dispatches {
+		d.segment.acked++
+		s.collect(d.segment)
+	}
+}
+
+// collect deletes the given segment if it is drained. Must be called with the
+// lock held.
+func (s *spool) collect(segment *spoolSegment) {
+	if !segment.drained() {
+		return
+	}
+
+	if s.reading == segment {
+		_ = s.reader.Close()
+		s.reader, s.reading = nil, nil
+	}
+
+	if err := os.Remove(segment.path); err != nil && !os.IsNotExist(err) {
+		return
+	}
+
+	// a deletion lost by a crash only delivers the entries again.
+	_ = syncDir(s.dir)
+
+	for i, candidate := range s.segments {
+		if candidate == segment {
+			s.segments = append(s.segments[:i], s.segments[i+1:]...)
+
+			break
+		}
+	}
+}
+
+// roll seals the active segment, if any, and creates a new one. Must be
+// called with the lock held.
+func (s *spool) roll() error {
+	if err := s.seal(); err != nil {
+		return err
+	}
+
+	segment := &spoolSegment{seq: s.nextSeq, path: spoolSegmentPath(s.dir, s.nextSeq)}
+
+	active, err := os.OpenFile(segment.path, os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0o640)
+	if err != nil {
+		return fmt.Errorf("%w: could not create spool segment %s", err, segment.path)
+	}
+
+	// the segment must survive crashes along with the entries written to it.
+	if err = syncDir(s.dir); err != nil {
+		_ = active.Close()
+		_ = os.Remove(segment.path)
+
+		return fmt.Errorf("%w: could not sync spool directory %s", err, s.dir)
+	}
+
+	s.nextSeq++
+	s.active = active
+	s.segments = append(s.segments, segment)
+
+	return nil
+}
+
+// seal flushes and closes the active segment. Must be called with the lock
+// held.
+func (s *spool) seal() error {
+	if s.active == nil {
+		return nil
+	}
+
+	err := s.active.Sync()
+	if closeErr := s.active.Close(); err == nil {
+		err = closeErr
+	}
+
+	s.active = nil
+
+	for _, segment := range s.segments {
+		if !segment.sealed {
+			segment.sealed = true
+			s.collect(segment)
+		}
+	}
+
+	if err != nil {
+		return fmt.Errorf("%w: could not seal spool segment", err)
+	}
+
+	return nil
+}
+
+// syncEvery flushes the active segment at the given interval until the spool
+// is closed.
+func (s *spool) syncEvery(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-s.stopSync:
+			return
+		case <-ticker.C:
+			s.mu.Lock()
+			if s.active != nil {
+				_ = s.active.Sync()
+			}
+			s.mu.Unlock()
+		}
+	}
+}
+
+// WithSpoolTimeout controls the maximum amount of time a worker will wait for
+// the target logger to process entries. If the timeout is less than or equal
+// to zero, it will be set to 3 seconds.
+func WithSpoolTimeout(timeout time.Duration) SpoolOption {
+	return func(opts *spoolOptions) {
+		if timeout <= 0 {
+			timeout = 3 * time.Second
+		}
+
+		opts.timeout = timeout
+	}
+}
+
+// WithSpoolDropHandler sets a function that will be called when an entry is
+// dropped, either because the target logger rejected it with an error
+// wrapping [ErrPermanent] or because it could not be read back from disk, in
+// which case the entry is nil.
+func WithSpoolDropHandler(handler DropHandlerFunc) SpoolOption {
+	return func(opts *spoolOptions) {
+		if handler == nil {
+			handler = func(*Entry, error) {}
+		}
+
+		opts.dropHandling = handler
+	}
+}
+
+// WithSpoolRetryDelay controls how long entries that failed with an error not
+// wrapping [ErrPermanent] wait before being written again. If delay is less
+// than or equal to zero, it will be set to 1 second.
+func WithSpoolRetryDelay(delay time.Duration) SpoolOption {
+	return func(opts *spoolOptions) {
+		if delay <= 0 {
+			delay = time.Second
+		}
+
+		opts.retryDelay = delay
+	}
+}
+
+// WithSpoolThroughput controls the number of concurrent workers that will
+// write entries to the target logger. If throughput is less than or equal to
+// zero, it will be set to 1.
+func WithSpoolThroughput(throughput int) SpoolOption {
+	return func(opts *spoolOptions) {
+		if throughput <= 0 {
+			throughput = 1
+		}
+
+		opts.throughput = throughput
+	}
+}
+
+// WithSpoolBatchSize controls the maximum number of entries a worker writes
+// at once when the destination is a [BatchLogger]. If size is less than or
+// equal to zero, it will be set to 100.
+func WithSpoolBatchSize(size int) SpoolOption {
+	return func(opts *spoolOptions) {
+		if size <= 0 {
+			size = 100
+		}
+
+		opts.batchSize = size
+	}
+}
+
+// WithSpoolSegmentSize controls the size in bytes after which a new segment
+// file is started. Smaller segments are deleted sooner, at the cost of more
+// files. If size is less than or equal to zero, it will be set to 16 MiB.
+func WithSpoolSegmentSize(size int64) SpoolOption {
+	return func(opts *spoolOptions) {
+		if size <= 0 {
+			size = 16 << 20
+		}
+
+		opts.segmentSize = size
+	}
+}
+
+// WithSpoolSync sets when entries are flushed to stable storage. The interval
+// is only used by [SpoolSyncInterval]; if it is less than or equal to zero,
+// it will be set to 1 second. Defaults to [SpoolSyncAlways].
+func WithSpoolSync(policy SpoolSyncPolicy, interval time.Duration) SpoolOption {
+	return func(opts *spoolOptions) {
+		if interval <= 0 {
+			interval = time.Second
+		}
+
+		opts.syncPolicy = policy
+		opts.syncInterval = interval
+	}
+}
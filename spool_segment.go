@@ -0,0 +1,201 @@
+package auditrail
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+const (
+	// spoolSegmentExt is the extension of the segment files of a spool.
+	spoolSegmentExt = ".seg"
+
+	// spoolHeaderSize is the size of the header of every record, made of the
+	// length and the CRC-32C checksum of its payload.
+	spoolHeaderSize = 8
+
+	// spoolMaxRecordSize bounds the length of records, so a corrupted length
+	// is not taken for a huge record.
+	spoolMaxRecordSize = 64 << 20
+)
+
+// ErrSpoolCorrupted is reported to the drop handler of a spool for every
+// record that cannot be read back from its segment files, e.g. because of a
+// checksum mismatch or a write torn by a crash.
+var ErrSpoolCorrupted = errors.New("spool record is corrupted")
+
+// spoolCRC is the table of the CRC-32C checksums of records.
+var spoolCRC = crc32.MakeTable(crc32.Castagnoli)
+
+// spoolSegment is a file of a spool, holding a sequence of records.
+type spoolSegment struct {
+	seq  uint64
+	path string
+
+	// size is the number of bytes of complete records written.
+	size int64
+
+	// sealed segments are no longer written.
+	sealed bool
+
+	// offset is the position of the next record to read.
+	offset int64
+
+	// dispatched and acked count the entries read from the segment and the
+	// ones whose delivery finished.
+	dispatched int
+	acked      int
+}
+
+// drained reports whether every record of the segment was read and every
+// dispatched entry acknowledged, so the segment can be deleted.
+func (s *spoolSegment) drained() bool {
+	return s.sealed && s.offset >= s.size && s.acked == s.dispatched
+}
+
+// spoolSegmentPath returns the path of the segment with the given sequence
+// number in dir.
+func spoolSegmentPath(dir string, seq uint64) string {
+	return filepath.Join(dir, fmt.Sprintf("%020d%s", seq, spoolSegmentExt))
+}
+
+// spoolSegments returns the segments found in dir, sorted by sequence number.
+// They are sealed, so new records are appended to a new segment.
+func spoolSegments(dir string) ([]*spoolSegment, error) {
+	files, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	var segments []*spoolSegment
+
+	for _, file := range files {
+		name := file.Name()
+		if file.IsDir() || !strings.HasSuffix(name, spoolSegmentExt) {
+			continue
+		}
+
+		seq, err := strconv.ParseUint(strings.TrimSuffix(name, spoolSegmentExt), 10, 64)
+		if err != nil {
+			continue
+		}
+
+		info, err := file.Info()
+		if err != nil {
+			return nil, err
+		}
+
+		segments = append(segments, &spoolSegment{
+			seq:    seq,
+			path:   filepath.Join(dir, name),
+			size:   info.Size(),
+			sealed: true,
+		})
+	}
+
+	sort.Slice(segments, func(i, j int) bool { return segments[i].seq < segments[j].seq })
+
+	return segments, nil
+}
+
+// spoolRecord returns the framed record of the given payload.
+func spoolRecord(payload []byte) []byte {
+	record := make([]byte, spoolHeaderSize+len(payload))
+
+	binary.BigEndian.PutUint32(record[0:4], uint32(len(payload)))
+	binary.BigEndian.PutUint32(record[4:8], crc32.Checksum(payload, spoolCRC))
+	copy(record[spoolHeaderSize:], payload)
+
+	return record
+}
+
+// readSpoolRecord reads the record found at the given offset of r, which
+// holds size bytes, returning its payload and the offset of the next record.
+//
+// If the record is corrupted but its end is known, the offset of the next
+// record is returned along with an error wrapping [ErrSpoolCorrupted].
+// Otherwise, the rest of the segment cannot be read, so size is returned as
+// the offset of the next record.
+func readSpoolRecord(r io.ReaderAt, offset, size int64) ([]byte, int64, error) {
+	header := make([]byte, spoolHeaderSize)
+
+	if size-offset < spoolHeaderSize {
+		return nil, size, fmt.Errorf("%w: truncated header at offset %d", ErrSpoolCorrupted, offset)
+	}
+
+	if _, err := r.ReadAt(header, offset); err != nil {
+		return nil, size, fmt.Errorf("%w: %w: could not read header at offset %d", ErrSpoolCorrupted, err, offset)
+	}
+
+	length := int64(binary.BigEndian.Uint32(header[0:4]))
+	next := offset + spoolHeaderSize + length
+
+	if length > spoolMaxRecordSize || next > size {
+		return nil, size, fmt.Errorf("%w: truncated record at offset %d", ErrSpoolCorrupted, offset)
+	}
+
+	payload := make([]byte, length)
+
+	if _, err := r.ReadAt(payload, offset+spoolHeaderSize); err != nil {
+		return nil, size, fmt.Errorf("%w: %w: could not read record at offset %d", ErrSpoolCorrupted, err, offset)
+	}
+
+	if crc32.Checksum(payload, spoolCRC) != binary.BigEndian.Uint32(header[4:8]) {
+		return nil, next, fmt.Errorf("%w: checksum mismatch at offset %d", ErrSpoolCorrupted, offset)
+	}
+
+	return payload, next, nil
+}
+
+// countSpoolRecords returns the number of records found from the given offset
+// of the segment file at path, which holds size bytes, reading their headers
+// only. Corrupted records are counted as well.
+func countSpoolRecords(path string, offset, size int64) int {
+	fd, err := os.Open(path)
+	if err != nil {
+		return 0
+	}
+
+	defer fd.Close()
+
+	header := make([]byte, spoolHeaderSize)
+	n := 0
+
+	for ; offset < size; n++ {
+		if size-offset < spoolHeaderSize {
+			return n + 1
+		}
+
+		if _, err = fd.ReadAt(header, offset); err != nil {
+			return n + 1
+		}
+
+		offset += spoolHeaderSize + int64(binary.BigEndian.Uint32(header[0:4]))
+	}
+
+	return n
+}
+
+// syncDir flushes the entries of the given directory to stable storage, so
+// the files created or deleted in it survive crashes.
+func syncDir(dir string) error {
+	fd, err := os.Open(dir)
+	if err != nil {
+		return err
+	}
+
+	if err = fd.Sync(); err != nil {
+		_ = fd.Close()
+
+		return err
+	}
+
+	return fd.Close()
+}
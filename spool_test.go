@@ -0,0 +1,287 @@
+package auditrail_test
+
+import (
+	"context"
+	"encoding/binary"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/botchris/go-auditrail"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSpool(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	t.Run("GIVEN a spool WHEN logging entries THEN they are written to the destination and segments are deleted", func(t *testing.T) {
+		dir := t.TempDir()
+		dst := auditrail.NewMemoryLogger()
+
+		spool, err := auditrail.NewSpool(&delayed{Logger: dst, delay: time.Millisecond}, dir,
+			auditrail.WithSpoolSegmentSize(1024),
+			auditrail.WithSpoolThroughput(3),
+		)
+		require.NoError(t, err)
+
+		for i := 0; i < 50; i++ {
+			require.NoError(t, spool.Log(ctx, newFakeEntry()))
+		}
+
+		require.Greater(t, len(spoolSegmentFiles(t, dir)), 1)
+		require.Eventually(t, func() bool { return dst.Size() == 50 }, 5*time.Second, time.Millisecond)
+		require.Eventually(t, func() bool { return len(spoolSegmentFiles(t, dir)) == 1 }, 5*time.Second, time.Millisecond)
+
+		checkClose(t, ctx, spool)
+		require.Empty(t, spoolSegmentFiles(t, dir))
+	})
+
+	t.Run("GIVEN a spool that crashed WHEN creating a spool on its directory THEN undelivered entries are resumed", func(t *testing.T) {
+		crashed, copied := t.TempDir(), t.TempDir()
+		entries := spoolCrash(t, ctx, crashed, auditrail.SpoolSyncAlways, 5)
+
+		copySpool(t, crashed, copied)
+
+		dst := auditrail.NewMemoryLogger()
+		spool, err := auditrail.NewSpool(dst, copied)
+		require.NoError(t, err)
+
+		require.NoError(t, spool.Log(ctx, newFakeEntry()))
+		require.Eventually(t, func() bool { return dst.Size() == 6 }, 5*time.Second, time.Millisecond)
+
+		for _, entry := range entries {
+			require.True(t, dst.Has(entry.GetIdempotencyID()))
+		}
+
+		checkClose(t, ctx, spool)
+		require.Empty(t, spoolSegmentFiles(t, copied))
+	})
+
+	t.Run("GIVEN corrupted segments WHEN resuming them THEN corrupted records are dropped and the rest are written", func(t *testing.T) {
+		crashed, copied := t.TempDir(), t.TempDir()
+		entries := spoolCrash(t, ctx, crashed, auditrail.SpoolSyncNever, 5)
+
+		copySpool(t, crashed, copied)
+
+		segment := spoolSegmentFiles(t, copied)[0]
+		data, err := os.ReadFile(segment)
+		require.NoError(t, err)
+
+		// flip a byte of the payload of the second record.
+		second := 8 + int(binary.BigEndian.Uint32(data[0:4]))
+		data[second+8+10] ^= 0xff
+
+		// tear the last record, as a crash in the middle of a write does.
+		data = data[:len(data)-3]
+
+		require.NoError(t, os.WriteFile(segment, data, 0o600))
+
+		var corrupted atomic.Int64
+
+		dst := auditrail.NewMemoryLogger()
+		spool, err := auditrail.NewSpool(dst, copied, auditrail.WithSpoolDropHandler(func(e *auditrail.Entry, err error) {
+			require.Nil(t, e)
+			require.ErrorIs(t, err, auditrail.ErrSpoolCorrupted)
+			corrupted.Add(1)
+		}))
+		require.NoError(t, err)
+
+		require.Eventually(t, func() bool { return corrupted.Load() == 2 && dst.Size() == 3 }, 5*time.Second, time.Millisecond)
+		require.True(t, dst.Has(entries[0].GetIdempotencyID(), entries[2].GetIdempotencyID(), entries[3].GetIdempotencyID()))
+
+		checkClose(t, ctx, spool)
+		require.Empty(t, spoolSegmentFiles(t, copied))
+	})
+
+	t.Run("GIVEN a destination failing temporarily WHEN logging entries THEN they are retried until written", func(t *testing.T) {
+		dir := t.TempDir()
+		dst := auditrail.NewMemoryLogger()
+
+		var dropped atomic.Int64
+
+		spool, err := auditrail.NewSpool(&flakyLogger{Logger: dst, rate: 0.5}, dir,
+			auditrail.WithSpoolRetryDelay(time.Millisecond),
+			auditrail.WithSpoolDropHandler(func(*auditrail.Entry, error) { dropped.Add(1) }),
+		)
+		require.NoError(t, err)
+
+		for i := 0; i < 20; i++ {
+			require.NoError(t, spool.Log(ctx, newFakeEntry()))
+		}
+
+		require.Eventually(t, func() bool { return dst.Size() == 20 }, 5*time.Second, time.Millisecond)
+
+		checkClose(t, ctx, spool)
+		require.Zero(t, dropped.Load())
+		require.Empty(t, spoolSegmentFiles(t, dir))
+	})
+
+	t.Run("GIVEN a failing destination WHEN closing the spool THEN pending entries are kept AND resumed by the next spool", func(t *testing.T) {
+		dir := t.TempDir()
+
+		var dropped atomic.Int64
+
+		spool, err := auditrail.NewSpool(&dropper{err: os.ErrDeadlineExceeded}, dir,
+			auditrail.WithSpoolSync(auditrail.SpoolSyncInterval, time.Millisecond),
+			auditrail.WithSpoolRetryDelay(time.Millisecond),
+			auditrail.WithSpoolDropHandler(func(*auditrail.Entry, error) { dropped.Add(1) }),
+		)
+		require.NoError(t, err)
+
+		entries := make([]*auditrail.Entry, 10)
+		for i := range entries {
+			entries[i] = newFakeEntry()
+			require.NoError(t, spool.Log(ctx, entries[i]))
+		}
+
+		checkClose(t, ctx, spool)
+		require.Zero(t, dropped.Load())
+		require.NotEmpty(t, spoolSegmentFiles(t, dir))
+
+		dst := auditrail.NewMemoryLogger()
+		resumed, err := auditrail.NewSpool(dst, dir)
+		require.NoError(t, err)
+
+		require.Eventually(t, func() bool { return dst.Size() == 10 }, 5*time.Second, time.Millisecond)
+
+		for _, entry := range entries {
+			require.True(t, dst.Has(entry.GetIdempotencyID()))
+		}
+
+		checkClose(t, ctx, resumed)
+		require.Empty(t, spoolSegmentFiles(t, dir))
+	})
+
+	t.Run("GIVEN a destination rejecting entries permanently WHEN logging entries THEN they are dropped and acknowledged", func(t *testing.T) {
+		dir := t.TempDir()
+
+		var dropped atomic.Int64
+
+		spool, err := auditrail.NewSpool(&dropper{err: fmt.Errorf("%w: invalid entry", auditrail.ErrPermanent)}, dir,
+			auditrail.WithSpoolDropHandler(func(_ *auditrail.Entry, err error) {
+				require.ErrorIs(t, err, auditrail.ErrPermanent)
+				dropped.Add(1)
+			}),
+		)
+		require.NoError(t, err)
+
+		for i := 0; i < 10; i++ {
+			require.NoError(t, spool.Log(ctx, newFakeEntry()))
+		}
+
+		checkClose(t, ctx, spool)
+		require.EqualValues(t, 10, dropped.Load())
+		require.Empty(t, spoolSegmentFiles(t, dir))
+	})
+
+	t.Run("GIVEN a spool WHEN shutting it down THEN every entry is reported as flushed", func(t *testing.T) {
+		dir := t.TempDir()
+		dst := auditrail.NewMemoryLogger()
+		stuck := &blocked{Logger: dst, release: make(chan struct{})}
+
+		spool, err := auditrail.NewSpool(stuck, dir)
+		require.NoError(t, err)
+
+		for i := 0; i < 5; i++ {
+			require.NoError(t, spool.Log(ctx, newFakeEntry()))
+		}
+
+		time.AfterFunc(20*time.Millisecond, func() { close(stuck.release) })
+
+		report, err := auditrail.Shutdown(ctx, spool)
+		require.NoError(t, err)
+		require.Equal(t, 5, report.Flushed)
+		require.Zero(t, report.Abandoned)
+		require.Equal(t, 5, dst.Size())
+		require.Empty(t, spoolSegmentFiles(t, dir))
+		require.True(t, spool.IsClosed())
+	})
+
+	t.Run("GIVEN a stalled destination WHEN shutting down the spool past its deadline THEN entries are abandoned AND kept for the next spool", func(t *testing.T) {
+		dir := t.TempDir()
+
+		var dropped atomic.Int64
+
+		spool, err := auditrail.NewSpool(&stalled{Logger: auditrail.NewMemoryLogger()}, dir,
+			auditrail.WithSpoolTimeout(time.Minute),
+			auditrail.WithSpoolDropHandler(func(*auditrail.Entry, error) { dropped.Add(1) }),
+		)
+		require.NoError(t, err)
+
+		for i := 0; i < 5; i++ {
+			require.NoError(t, spool.Log(ctx, newFakeEntry()))
+		}
+
+		deadline, cancel := context.WithTimeout(ctx, 20*time.Millisecond)
+		defer cancel()
+
+		report, err := auditrail.Shutdown(deadline, spool)
+		require.ErrorIs(t, err, context.DeadlineExceeded)
+		require.Equal(t, auditrail.ShutdownReport{Abandoned: 5}, report)
+
+		select {
+		case <-spool.Closed():
+		case <-ctx.Done():
+			require.FailNow(t, "spool was not closed")
+		}
+
+		require.Zero(t, dropped.Load())
+		require.NotEmpty(t, spoolSegmentFiles(t, dir))
+
+		dst := auditrail.NewMemoryLogger()
+		resumed, err := auditrail.NewSpool(dst, dir)
+		require.NoError(t, err)
+
+		require.Eventually(t, func() bool { return dst.Size() == 5 }, 5*time.Second, time.Millisecond)
+		checkClose(t, ctx, resumed)
+	})
+}
+
+// spoolCrash logs n entries into a spool stored in dir whose destination never
+// completes, leaving them in its segments as a crash would.
+func spoolCrash(t *testing.T, ctx context.Context, dir string, policy auditrail.SpoolSyncPolicy, n int) []*auditrail.Entry {
+	t.Helper()
+
+	stuck := &blocked{Logger: auditrail.NewMemoryLogger(), release: make(chan struct{})}
+
+	spool, err := auditrail.NewSpool(stuck, dir, auditrail.WithSpoolSync(policy, 0), auditrail.WithSpoolTimeout(time.Minute))
+	require.NoError(t, err)
+
+	t.Cleanup(func() {
+		close(stuck.release)
+		_ = spool.Close()
+	})
+
+	entries := make([]*auditrail.Entry, n)
+	for i := range entries {
+		entries[i] = newFakeEntry()
+		require.NoError(t, spool.Log(ctx, entries[i]))
+	}
+
+	return entries
+}
+
+// copySpool copies the segment files of the spool stored in src into dst.
+func copySpool(t *testing.T, src, dst string) {
+	t.Helper()
+
+	for _, segment := range spoolSegmentFiles(t, src) {
+		data, err := os.ReadFile(segment)
+		require.NoError(t, err)
+		require.NoError(t, os.WriteFile(filepath.Join(dst, filepath.Base(segment)), data, 0o600))
+	}
+}
+
+// spoolSegmentFiles returns the paths of the segment files found in dir.
+func spoolSegmentFiles(t *testing.T, dir string) []string {
+	t.Helper()
+
+	files, err := filepath.Glob(filepath.Join(dir, "*.seg"))
+	require.NoError(t, err)
+
+	return files
+}